@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.coldcutz.net/autoclaude/internal/guard"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+var bashGuardCmd = &cobra.Command{
+	Use:         "_bash-guard",
+	Short:       "Internal: called by PreToolUse hook to deny heredocs, herestrings and awk in Bash calls",
+	Hidden:      true,
+	Annotations: map[string]string{skipConfigAnnotation: "true"},
+	RunE:        runBashGuard,
+}
+
+func init() {
+	rootCmd.AddCommand(bashGuardCmd)
+}
+
+func runBashGuard(cmd *cobra.Command, args []string) error {
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil
+	}
+	var hookInput PreToolUseInput
+	if err := json.Unmarshal(input, &hookInput); err != nil || hookInput.ToolName != "Bash" {
+		return nil
+	}
+
+	if reason := guard.ShellBlocked(hookInput.ToolInput.Command); reason != "" {
+		recordEvent(state.Event{Type: state.EventToolDenied, SessionID: hookInput.SessionID, Message: "Denied Bash: " + reason})
+		return outputDeny(reason)
+	}
+	return nil
+}
@@ -1,126 +1,19 @@
 package cmd
 
 import (
+	"io"
 	"os"
 	"os/exec"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/spf13/cobra"
 	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/git"
 	"go.coldcutz.net/autoclaude/internal/state"
 )
 
-func TestHasIncompleteTodos(t *testing.T) {
-	tmpDir := t.TempDir()
-	oldDir, _ := os.Getwd()
-	os.Chdir(tmpDir)
-	defer os.Chdir(oldDir)
-
-	os.MkdirAll(state.AutoclaudeDir, 0755)
-
-	// No file
-	if hasIncompleteTodos() {
-		t.Error("should return false when no TODO file")
-	}
-
-	// Empty file
-	os.WriteFile(state.TodoPath(), []byte(""), 0644)
-	if hasIncompleteTodos() {
-		t.Error("should return false for empty file")
-	}
-
-	// All complete
-	os.WriteFile(state.TodoPath(), []byte("- [x] Done\n- [x] Also done"), 0644)
-	if hasIncompleteTodos() {
-		t.Error("should return false when all complete")
-	}
-
-	// Has incomplete
-	os.WriteFile(state.TodoPath(), []byte("- [x] Done\n- [ ] Not done"), 0644)
-	if !hasIncompleteTodos() {
-		t.Error("should return true when incomplete exists")
-	}
-}
-
-func TestGetCommitHash(t *testing.T) {
-	tmpDir := t.TempDir()
-	oldDir, _ := os.Getwd()
-	os.Chdir(tmpDir)
-	defer os.Chdir(oldDir)
-
-	// Not a git repo
-	hash := getCommitHash()
-	if hash != "" {
-		t.Error("should return empty string when not a git repo")
-	}
-
-	// Initialize git repo
-	exec.Command("git", "init").Run()
-	exec.Command("git", "config", "user.email", "test@test.com").Run()
-	exec.Command("git", "config", "user.name", "Test").Run()
-
-	// Still no commits
-	hash = getCommitHash()
-	if hash != "" {
-		t.Error("should return empty string when no commits")
-	}
-
-	// Create a commit
-	os.WriteFile("test.txt", []byte("test"), 0644)
-	exec.Command("git", "add", ".").Run()
-	exec.Command("git", "commit", "-m", "initial").Run()
-
-	hash = getCommitHash()
-	if hash == "" {
-		t.Error("should return hash after commit")
-	}
-	if len(hash) < 7 {
-		t.Errorf("hash should be at least 7 chars, got %q", hash)
-	}
-}
-
-func TestForceCommit(t *testing.T) {
-	tmpDir := t.TempDir()
-	oldDir, _ := os.Getwd()
-	os.Chdir(tmpDir)
-	defer os.Chdir(oldDir)
-
-	// Initialize git repo
-	exec.Command("git", "init").Run()
-	exec.Command("git", "config", "user.email", "test@test.com").Run()
-	exec.Command("git", "config", "user.name", "Test").Run()
-
-	// Initial commit
-	os.WriteFile("initial.txt", []byte("initial"), 0644)
-	exec.Command("git", "add", ".").Run()
-	exec.Command("git", "commit", "-m", "initial").Run()
-
-	hashBefore := getCommitHash()
-
-	// No changes - should not create new commit
-	forceCommit("Test")
-	hashAfter := getCommitHash()
-	if hashBefore != hashAfter {
-		t.Error("forceCommit should not create commit when no changes")
-	}
-
-	// Create changes
-	os.WriteFile("new.txt", []byte("new file"), 0644)
-
-	forceCommit("Coder")
-	hashAfter = getCommitHash()
-	if hashBefore == hashAfter {
-		t.Error("forceCommit should create commit when changes exist")
-	}
-
-	// Check commit message
-	out, _ := exec.Command("git", "log", "-1", "--format=%s").Output()
-	msg := strings.TrimSpace(string(out))
-	if !strings.Contains(msg, "coder") {
-		t.Errorf("commit message should contain phase name, got %q", msg)
-	}
-}
-
 func TestHasUncommittedChanges(t *testing.T) {
 	tmpDir := t.TempDir()
 	oldDir, _ := os.Getwd()
@@ -203,6 +96,80 @@ func TestCleanWorkingDirectory(t *testing.T) {
 	}
 }
 
+func TestSaveAndRecoverUncommittedChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	exec.Command("git", "init").Run()
+	exec.Command("git", "config", "user.email", "test@test.com").Run()
+	exec.Command("git", "config", "user.name", "Test").Run()
+	os.WriteFile("tracked.txt", []byte("original"), 0644)
+	exec.Command("git", "add", ".").Run()
+	exec.Command("git", "commit", "-m", "initial").Run()
+
+	// Resume saves the changes before cleaning
+	os.WriteFile("tracked.txt", []byte("modified"), 0644)
+	os.WriteFile("untracked.txt", []byte("untracked"), 0644)
+	if err := saveUncommittedChanges(); err != nil {
+		t.Fatalf("saveUncommittedChanges failed: %v", err)
+	}
+	if err := cleanWorkingDirectory(); err != nil {
+		t.Fatalf("cleanWorkingDirectory failed: %v", err)
+	}
+	branches, _ := git.RecoveryBranches()
+	if len(branches) != 1 {
+		t.Fatalf("expected one recovery branch, got %v", branches)
+	}
+
+	if err := runRecover(recoverCmd, nil); err != nil {
+		t.Fatalf("recover failed: %v", err)
+	}
+	if data, _ := os.ReadFile("tracked.txt"); string(data) != "modified" {
+		t.Errorf("tracked change not recovered, got %q", data)
+	}
+	if data, _ := os.ReadFile("untracked.txt"); string(data) != "untracked" {
+		t.Errorf("untracked file not recovered, got %q", data)
+	}
+	if git.BranchExists(branches[0]) {
+		t.Error("recovery branch should be deleted after restoring")
+	}
+}
+
+func TestResumeKeepsChangesOnBadConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+	t.Setenv("AUTOCLAUDE_CLAUDE", "true")
+
+	exec.Command("git", "init").Run()
+	exec.Command("git", "config", "user.email", "test@test.com").Run()
+	exec.Command("git", "config", "user.name", "Test").Run()
+	state.InitDir("goal", "true")
+	state.NewState("goal", "true", "", 3).Save()
+	os.WriteFile("tracked.txt", []byte("original"), 0644)
+	exec.Command("git", "add", ".").Run()
+	exec.Command("git", "commit", "-m", "initial").Run()
+	os.WriteFile("tracked.txt", []byte("modified"), 0644)
+
+	resumeDiscard = true
+	resumeCmd.Flags().Set("retry-limit", "0")
+	defer func() {
+		resumeDiscard = false
+		resumeCmd.Flags().Set("retry-limit", "0")
+		resumeCmd.Flags().Lookup("retry-limit").Changed = false
+	}()
+
+	if err := runResume(resumeCmd, nil); err == nil || !strings.Contains(err.Error(), "retry_limit") {
+		t.Fatalf("expected resume to refuse the bad flag, got %v", err)
+	}
+	if data, _ := os.ReadFile("tracked.txt"); string(data) != "modified" {
+		t.Errorf("uncommitted changes should be left alone, got %q", data)
+	}
+}
+
 func TestIntegrationInitCreatesFiles(t *testing.T) {
 	tmpDir := t.TempDir()
 	oldDir, _ := os.Getwd()
@@ -260,7 +227,7 @@ func TestIntegrationSetupPermissions(t *testing.T) {
 	os.Chdir(tmpDir)
 	defer os.Chdir(oldDir)
 
-	err := config.SetupPermissions()
+	err := config.SetupPermissions("/test/autoclaude")
 	if err != nil {
 		t.Fatalf("SetupPermissions failed: %v", err)
 	}
@@ -287,6 +254,15 @@ func TestIntegrationSetupPermissions(t *testing.T) {
 	if len(settings.Hooks.PreToolUse) == 0 {
 		t.Error("settings should have PreToolUse hooks")
 	}
+	found := false
+	for _, hc := range settings.Hooks.PreToolUse {
+		if hc.Matcher == "Bash" && hc.Hooks[0].Command == "/test/autoclaude _bash-guard" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("settings should have the Bash guard hook")
+	}
 	if len(settings.Hooks.Notification) == 0 {
 		t.Error("settings should have Notification hooks")
 	}
@@ -339,3 +315,169 @@ func TestIntegrationStopHookLifecycle(t *testing.T) {
 		}
 	}
 }
+
+func TestLoopFlagsResolve(t *testing.T) {
+	old := projectConfig
+	defer func() { projectConfig = old }()
+	projectConfig = config.Default()
+	projectConfig.Budget = config.Budget{MaxCost: 5, MaxSessions: 10}
+
+	var flags loopFlags
+	cmd := &cobra.Command{Use: "test"}
+	flags.register(cmd)
+	cmd.Flags().Parse([]string{"--max-cost", "20", "--max-time", "90m", "--prune-interval", "0", "--coder-sonnet", "--parallel", "3"})
+
+	cfg, err := flags.resolve(cmd)
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+
+	// Given flags override the loaded config, others are kept
+	want := state.Budget{MaxCostUSD: 20, MaxSeconds: 5400, MaxSessions: 10}
+	if got := cfg.Budget.StateBudget(); got == nil || *got != want {
+		t.Errorf("budget = %+v, want %+v", got, want)
+	}
+	if cfg.PruneEvery() != config.DefaultPruneInterval {
+		t.Errorf("--prune-interval 0 should keep the configured interval, got %d", cfg.PruneEvery())
+	}
+	if opts := flags.engineOptions(cfg, "autoclaude"); opts.Parallel != 3 {
+		t.Errorf("--parallel should reach the engine, got %d", opts.Parallel)
+	}
+	if cfg.Phases.For(state.PhaseFixer).Model != "sonnet" || cfg.Phases.For(state.PhaseCritic).Model != "" {
+		t.Errorf("--coder-sonnet should only change coder and fixer: %+v", cfg.Phases)
+	}
+	if projectConfig.Phases.For(state.PhaseCoder).Model != "" || projectConfig.Budget.MaxCost != 5 {
+		t.Error("resolve should not modify the loaded config")
+	}
+
+	cmd.Flags().Parse([]string{"--retry-limit", "0"})
+	if _, err := flags.resolve(cmd); err == nil {
+		t.Error("expected validation error for --retry-limit 0")
+	}
+}
+
+func TestProtectedTouch(t *testing.T) {
+	patterns := []string{"migrations/**", "go.sum"}
+
+	tests := []struct {
+		name  string
+		input PreToolUseInput
+		want  string
+	}{
+		{"write protected file", PreToolUseInput{ToolName: "Write", ToolInput: ToolInput{FilePath: "/repo/go.sum"}}, "go.sum"},
+		{"edit protected dir", PreToolUseInput{ToolName: "Edit", ToolInput: ToolInput{FilePath: "/repo/migrations/001.sql"}}, "migrations/001.sql"},
+		{"edit other file", PreToolUseInput{ToolName: "Edit", ToolInput: ToolInput{FilePath: "/repo/main.go"}}, ""},
+		{"bash mentions file", PreToolUseInput{ToolName: "Bash", Cwd: "/repo", ToolInput: ToolInput{Command: "rm -rf migrations/old"}}, "migrations/old"},
+		{"bash in subdirectory", PreToolUseInput{ToolName: "Bash", Cwd: "/repo/db", ToolInput: ToolInput{Command: "echo x > ../go.sum"}}, "go.sum"},
+		{"bash other files", PreToolUseInput{ToolName: "Bash", Cwd: "/repo", ToolInput: ToolInput{Command: "go test ./..."}}, ""},
+		{"bash edits in place", PreToolUseInput{ToolName: "Bash", Cwd: "/repo", ToolInput: ToolInput{Command: "sed -i 's/v1/v2/' go.sum"}}, "go.sum"},
+		{"bash reads file", PreToolUseInput{ToolName: "Bash", Cwd: "/repo", ToolInput: ToolInput{Command: "cat go.sum"}}, ""},
+		{"bash searches dir", PreToolUseInput{ToolName: "Bash", Cwd: "/repo", ToolInput: ToolInput{Command: "grep foo migrations/x.sql"}}, ""},
+		{"bash lists dir", PreToolUseInput{ToolName: "Bash", Cwd: "/repo", ToolInput: ToolInput{Command: "ls migrations"}}, ""},
+		{"bash copies out", PreToolUseInput{ToolName: "Bash", Cwd: "/repo", ToolInput: ToolInput{Command: "cp migrations/001.sql /tmp/ && diff go.sum /tmp/go.sum"}}, ""},
+		{"outside project", PreToolUseInput{ToolName: "Write", ToolInput: ToolInput{FilePath: "/tmp/go.sum"}}, ""},
+		{"read is not checked", PreToolUseInput{ToolName: "Read", ToolInput: ToolInput{FilePath: "/repo/go.sum"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, pattern := protectedTouch(tt.input, patterns, "/repo")
+			if got != tt.want || (got != "") != (pattern != "") {
+				t.Errorf("protectedTouch = %q (%q), want %q", got, pattern, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreToolUseDeniesProtectedPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+	t.Setenv("CLAUDE_PROJECT_DIR", "")
+
+	os.MkdirAll(state.AutoclaudeDir, 0755)
+	os.WriteFile(config.ProjectConfigPath(), []byte("protected_paths: [go.sum]\n"), 0644)
+
+	out := runHook(t, runPreToolUse, `{"tool_name": "Write", "tool_input": {"file_path": "go.sum", "content": "x"}}`)
+	if !strings.Contains(out, `"permissionDecision":"deny"`) || !strings.Contains(out, "go.sum is a protected path") {
+		t.Errorf("expected a denial, got %q", out)
+	}
+	notes, _ := os.ReadFile(state.NotesPath())
+	if !strings.Contains(string(notes), "Denied Write touching protected path go.sum") {
+		t.Errorf("expected the denial in NOTES.md, got %q", notes)
+	}
+
+	if out := runHook(t, runPreToolUse, `{"tool_name": "Write", "tool_input": {"file_path": "main.go"}}`); out != "" {
+		t.Errorf("expected no output for an allowed call, got %q", out)
+	}
+}
+
+func TestBashGuard(t *testing.T) {
+	out := runHook(t, runBashGuard, `{"tool_name": "Bash", "tool_input": {"command": "cat <<'EOF' > main.go\npackage main\nEOF"}}`)
+	if !strings.Contains(out, `"permissionDecision":"deny"`) || !strings.Contains(out, "Write tool") {
+		t.Errorf("expected a denial pointing at Write, got %q", out)
+	}
+	if out := runHook(t, runBashGuard, `{"tool_name": "Bash", "tool_input": {"command": "go test ./..."}}`); out != "" {
+		t.Errorf("expected no output for an allowed command, got %q", out)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	s := state.NewState("goal", "go test", "", 10)
+	s.Save()
+	if out := runHook(t, runHistory, ""); !strings.Contains(out, "No runs yet") {
+		t.Errorf("expected no runs, got %q", out)
+	}
+
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.Local)
+	s.StartRun(start)
+	*s.Stats = state.Stats{TodosAttempted: 2, TodosCompleted: 2, CriticApprovals: 1, CriticRejections: 2, FixAttempts: 2, ElapsedMs: 60_000}
+	state.SaveRun(s.RunRecord(state.OutcomeComplete, start.Add(time.Minute)))
+	s.StartRun(start.Add(time.Hour))
+	*s.Stats = state.Stats{TodosAttempted: 2, TodosCompleted: 1, CriticApprovals: 3, CriticRejections: 1, FixAttempts: 1, ElapsedMs: 30_000}
+	state.SaveRun(s.RunRecord(state.OutcomeBudget, start.Add(2*time.Hour)))
+
+	out := runHook(t, runHistory, "")
+	for _, want := range []string{
+		"20260105-100000  2026-01-05 10:00  1m0s       complete         2/2    33%     1.00",
+		"20260105-110000  2026-01-05 11:00  30s        budget exceeded  1/2    75%     0.50",
+		"TODOs completed:        3/4",
+		"First-pass accept rate: 57%",
+		"Fixes per TODO:         0.75",
+		"Elapsed time:           1m30s",
+		"Accept rate over time:  33.3% → 75.0%",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in history, got:\n%s", want, out)
+		}
+	}
+}
+
+// runHook runs a hook command with the given stdin and returns its stdout
+func runHook(t *testing.T, run func(*cobra.Command, []string) error, input string) string {
+	t.Helper()
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	defer func() { os.Stdin, os.Stdout = oldStdin, oldStdout }()
+
+	stdin, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin.WriteString(input)
+	stdin.Seek(0, 0)
+	os.Stdin = stdin
+
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	if err := run(nil, nil); err != nil {
+		t.Fatalf("hook failed: %v", err)
+	}
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return strings.TrimSpace(string(out))
+}
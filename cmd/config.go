@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.coldcutz.net/autoclaude/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get, set and validate project settings",
+	Long: `Manage autoclaude settings in .autoclaude/config.yaml.
+
+Settings resolve as flag > environment > config.yaml > defaults. Every key can
+be overridden with an AUTOCLAUDE_* environment variable, e.g.
+AUTOCLAUDE_BUDGET_MAX_COST for budget.max_cost.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Show the effective value of a key, or of all keys",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runConfigGet,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a key in config.yaml",
+	Long: `Set a key in config.yaml. The file is validated before it is written.
+
+List keys (lint_commands, phases.<phase>.extra_args) take a single item or a
+list like '["go vet ./...", "golangci-lint run"]'. An empty value resets the
+key to its default.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConfigSet,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check config.yaml and the environment for errors",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	for _, sub := range []*cobra.Command{configGetCmd, configSetCmd, configValidateCmd} {
+		// These report config errors themselves
+		sub.Annotations = map[string]string{skipConfigAnnotation: "true"}
+		configCmd.AddCommand(sub)
+	}
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		value, err := cfg.Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	}
+
+	fmt.Printf("%-32s %d\n", "version", cfg.Version)
+	for _, key := range config.Keys() {
+		value, _ := cfg.Get(key)
+		fmt.Printf("%-32s %s\n", key, strings.ReplaceAll(value, "\n", "; "))
+	}
+	return nil
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	key, value := args[0], args[1]
+
+	cfg, err := config.LoadFile()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Set(key, value); err != nil {
+		return err
+	}
+
+	merged, err := cfg.Merged()
+	if err != nil {
+		return err
+	}
+	if err := merged.Validate(); err != nil {
+		return fmt.Errorf("not saved, %s would be invalid: %w", config.ProjectConfigPath(), err)
+	}
+
+	if err := cfg.SaveFile(); err != nil {
+		return err
+	}
+	fmt.Printf("Set %s in %s\n", key, config.ProjectConfigPath())
+
+	if _, ok := os.LookupEnv(config.EnvVar(key)); ok {
+		fmt.Printf("Note: %s is set and overrides this value\n", config.EnvVar(key))
+	}
+	return nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	if _, err := config.Load(); err != nil {
+		return err
+	}
+	fmt.Println("Configuration is valid.")
+	return nil
+}
@@ -26,10 +26,11 @@ type StopHookOutput struct {
 }
 
 var continueCmd = &cobra.Command{
-	Use:    "_continue",
-	Short:  "Internal: called by stop hook when Claude stops",
-	Hidden: true,
-	RunE:   runContinue,
+	Use:         "_continue",
+	Short:       "Internal: called by stop hook when Claude stops",
+	Hidden:      true,
+	Annotations: map[string]string{skipConfigAnnotation: "true"},
+	RunE:        runContinue,
 }
 
 func init() {
@@ -60,10 +61,8 @@ func runContinue(cmd *cobra.Command, args []string) error {
 		return outputAllow()
 	}
 
-	// Save transcript path for potential debugging
-	if hookInput.TranscriptPath != "" {
-		appendToNotes(fmt.Sprintf("Session ended, transcript: %s", hookInput.TranscriptPath))
-	}
+	// Record the session so the loop can read token usage from its transcript
+	recordSession(hookInput.SessionID, hookInput.TranscriptPath)
 
 	// Kill Claude process to ensure it exits and returns control to autoclaude
 	claude.KillClaude()
@@ -80,6 +79,24 @@ func outputAllow() error {
 	return nil
 }
 
+// recordSession journals the end of a session and saves its transcript
+// location for usage accounting
+func recordSession(sessionID, transcriptPath string) {
+	if transcriptPath == "" {
+		return
+	}
+	recordEvent(state.Event{Type: state.EventSessionEnded, SessionID: sessionID, Message: transcriptPath})
+	state.SaveLastSession(state.SessionInfo{SessionID: sessionID, TranscriptPath: transcriptPath})
+}
+
+// recordEvent journals an event from a hook. Hooks also run in sessions
+// outside autoclaude, where there is no journal to write to.
+func recordEvent(e state.Event) {
+	if state.Exists() {
+		state.RecordEvent(e)
+	}
+}
+
 // appendToNotes appends a note to NOTES.md
 func appendToNotes(note string) error {
 	f, err := os.OpenFile(state.NotesPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
@@ -22,7 +22,7 @@ func init() {
 }
 
 func runDevEval(cmd *cobra.Command, args []string) error {
-	if err := claude.CheckInstalled(); err != nil {
+	if err := checkInstalled(); err != nil {
 		return err
 	}
 
@@ -69,7 +69,7 @@ func runDevEval(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Prompt file: %s\n", promptPath)
 	fmt.Println()
 
-	if err := claude.RunInteractiveWithPromptFile(promptPath, "acceptEdits", ""); err != nil {
+	if _, err := claude.RunWithPromptFile(cmd.Context(), sessionRunner(), promptPath, phaseSessionOptions(state.PhaseEvaluator)); err != nil {
 		config.RemoveEvaluatorStopHook(autoclaudePath)
 		config.RemoveEvaluationComplete()
 		return fmt.Errorf("evaluator failed: %w", err)
@@ -83,7 +83,7 @@ func runDevEval(cmd *cobra.Command, args []string) error {
 		fmt.Println("\n=== EVALUATION COMPLETE ===")
 		fmt.Println("User confirmed done.")
 		config.RemoveEvaluationComplete()
-	} else if hasIncompleteTodos() {
+	} else if state.HasIncompleteTodos() {
 		fmt.Println("\n=== MORE WORK NEEDED ===")
 		fmt.Println("Evaluator added TODOs or user requested changes.")
 	} else {
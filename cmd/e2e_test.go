@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/fakeclaude"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+func TestMain(m *testing.M) {
+	fakeclaude.RunIfInvoked()
+	// The fake's Stop hooks run this test binary as autoclaude
+	if len(os.Args) > 1 && strings.HasPrefix(os.Args[1], "_") {
+		Execute()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+const e2eTodos = `# TODOs
+
+## Pending
+- [ ] **Add greeting** - Completion: main prints hello
+  - Priority: high
+`
+
+var (
+	plannerSession = fakeclaude.Session{
+		Match: "collaborative design partner",
+		Write: map[string]string{
+			state.TodoPath():              e2eTodos,
+			config.PlanningCompletePath(): "done",
+		},
+	}
+	evaluatorSession = fakeclaude.Session{
+		Match:  "picky evaluator",
+		Write:  map[string]string{config.EvaluationCompletePath(): "done"},
+		Tokens: 1000,
+	}
+)
+
+func TestEndToEndInitAndRun(t *testing.T) {
+	setupE2EProject(t)
+	sc := fakeclaude.Scenario{Sessions: []fakeclaude.Session{
+		plannerSession,
+		{Match: "You are working on", CheckTodo: true, Write: map[string]string{"main.go": "package main\n"}, Commit: "add main", Tokens: 1000},
+		{Match: "code reviewer", Verdict: "NEEDS_FIXES\n\nmain prints nothing", Tokens: 1000},
+		{Match: "You are fixing issues", Write: map[string]string{"main.go": "package main\n\nfunc main() { println(\"hello\") }\n"}, Commit: "print hello", Tokens: 1000},
+		{Match: "code reviewer", Verdict: "APPROVED", Tokens: 1000},
+		evaluatorSession,
+	}}
+	h := fakeclaude.Install(t, sc)
+
+	execute(t, "init", "print hello", "--test-cmd", "true")
+	execute(t, "run")
+	h.Played(sc)
+
+	s, err := state.Load()
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	if s.Step != state.StepDone {
+		t.Errorf("expected step done, got %q", s.Step)
+	}
+	st := s.Stats
+	if st.TodosCompleted != 1 || st.CriticRejections != 1 || st.CriticApprovals != 1 || st.FixSuccesses != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	// Interactive sessions report usage through the transcript the stop hook records
+	if st.Usage.Sessions != 5 || st.Usage.InputTokens != 5000 || !st.Usage.Estimated {
+		t.Errorf("expected usage of 5 sessions read from transcripts, got %+v", st.Usage)
+	}
+
+	log, _ := exec.Command("git", "log", "--format=%s").Output()
+	for _, msg := range []string{"add main", "print hello"} {
+		if !strings.Contains(string(log), msg) {
+			t.Errorf("expected commit %q, got log:\n%s", msg, log)
+		}
+	}
+	if settings, _ := config.LoadExisting(); settings.Hooks != nil && len(settings.Hooks.Stop) > 0 {
+		t.Errorf("expected stop hooks to be removed after the run, got %+v", settings.Hooks.Stop)
+	}
+}
+
+func TestEndToEndHeadlessRun(t *testing.T) {
+	setupE2EProject(t)
+	sc := fakeclaude.Scenario{Sessions: []fakeclaude.Session{
+		plannerSession,
+		{Match: "You are working on", CheckTodo: true, Write: map[string]string{"main.go": "package main\n"}, CostUSD: 0.5},
+		{Match: "code reviewer", Verdict: "APPROVED", CostUSD: 0.25},
+		evaluatorSession,
+	}}
+	h := fakeclaude.Install(t, sc)
+
+	execute(t, "init", "print hello", "--test-cmd", "true")
+	execute(t, "run", "--headless")
+	h.Played(sc)
+
+	for _, c := range h.Calls()[1:] {
+		if !c.Headless {
+			t.Errorf("expected session %d to run headless, got args %v", c.Session, c.Args)
+		}
+	}
+	s, _ := state.Load()
+	if s.Step != state.StepDone || s.Stats.TodosCompleted != 1 || s.Stats.Usage.CostUSD != 0.75 {
+		t.Errorf("unexpected state %q with stats %+v", s.Step, s.Stats)
+	}
+	// The coder left main.go uncommitted, so the loop committed it
+	if out, _ := exec.Command("git", "log", "--format=%s").Output(); !strings.Contains(string(out), "coder changes (auto-committed)") {
+		t.Errorf("expected a forced commit, got log:\n%s", out)
+	}
+}
+
+func TestEndToEndTmuxRun(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	setupE2EProject(t)
+	// Keep the test's tmux server apart from any the user is running
+	cwd, _ := os.Getwd()
+	t.Setenv("TMUX_TMPDIR", cwd)
+	t.Setenv("TMUX", "")
+	defer exec.Command("tmux", "kill-server").Run()
+
+	sc := fakeclaude.Scenario{Sessions: []fakeclaude.Session{
+		plannerSession,
+		{Match: "You are working on", CheckTodo: true, Write: map[string]string{"main.go": "package main\n"}, Commit: "add main", Tokens: 1000},
+		{Match: "code reviewer", Verdict: "APPROVED", Tokens: 1000},
+		evaluatorSession,
+	}}
+	h := fakeclaude.Install(t, sc)
+
+	execute(t, "init", "print hello", "--test-cmd", "true")
+	execute(t, "config", "set", "runner.backend", "tmux")
+	execute(t, "run")
+	h.Played(sc)
+
+	s, _ := state.Load()
+	if s.Step != state.StepDone || s.Stats.TodosCompleted != 1 || s.Stats.Usage.Sessions != 3 {
+		t.Errorf("unexpected state %q with stats %+v", s.Step, s.Stats)
+	}
+}
+
+// setupE2EProject creates an empty Go project in a temp dir
+func setupE2EProject(t *testing.T) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	t.Cleanup(func() { os.Chdir(oldDir) })
+
+	exec.Command("git", "init").Run()
+	exec.Command("git", "config", "user.email", "test@test.com").Run()
+	exec.Command("git", "config", "user.name", "Test").Run()
+	os.WriteFile("go.mod", []byte("module example.com/hello\n"), 0644)
+	exec.Command("git", "add", "-A").Run()
+	exec.Command("git", "commit", "-m", "initial").Run()
+}
+
+// execute runs autoclaude with the given arguments, with every flag back at
+// its default first
+func execute(t *testing.T, args ...string) {
+	t.Helper()
+	var reset func(*cobra.Command)
+	reset = func(c *cobra.Command) {
+		c.Flags().VisitAll(func(f *pflag.Flag) {
+			f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+		for _, sub := range c.Commands() {
+			reset(sub)
+		}
+	}
+	reset(rootCmd)
+
+	rootCmd.SetArgs(args)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("autoclaude %s failed: %v", strings.Join(args, " "), err)
+	}
+}
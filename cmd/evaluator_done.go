@@ -21,10 +21,11 @@ type EvaluatorHookInput struct {
 }
 
 var evaluatorDoneCmd = &cobra.Command{
-	Use:    "_evaluator-done",
-	Short:  "Internal: called by stop hook when evaluator stops",
-	Hidden: true,
-	RunE:   runEvaluatorDone,
+	Use:         "_evaluator-done",
+	Short:       "Internal: called by stop hook when evaluator stops",
+	Hidden:      true,
+	Annotations: map[string]string{skipConfigAnnotation: "true"},
+	RunE:        runEvaluatorDone,
 }
 
 func init() {
@@ -50,6 +51,8 @@ func runEvaluatorDone(cmd *cobra.Command, args []string) error {
 		return outputEvaluatorAllow()
 	}
 
+	recordSession(hookInput.SessionID, hookInput.TranscriptPath)
+
 	// Only kill Claude if evaluation is complete (user confirmed)
 	// If the file doesn't exist, let Claude continue working with the user
 	if config.IsEvaluationComplete() {
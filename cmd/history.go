@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+var historyLast int
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past runs and their statistics",
+	Long: `List every run of the loop with its outcome, TODOs, critic accept rate,
+fixes per TODO and cost, followed by totals across the runs.
+
+A run lasts from 'autoclaude run' through any resumes, so its stats add up
+every session spent on it.`,
+	RunE: runHistory,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().IntVarP(&historyLast, "last", "n", 0, "Only show and total the last N runs")
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	if !state.Exists() {
+		return fmt.Errorf("autoclaude not initialized. Run 'autoclaude init' first")
+	}
+
+	runs, err := state.LoadHistory()
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Println("No runs yet. Start one with 'autoclaude run'.")
+		return nil
+	}
+	if historyLast > 0 && len(runs) > historyLast {
+		runs = runs[len(runs)-historyLast:]
+	}
+
+	fmt.Println("=== Run History ===")
+	fmt.Println()
+	fmt.Printf("  %-15s  %-16s  %-9s  %-15s  %-5s  %-6s  %-10s  %s\n", "RUN", "STARTED", "TIME", "OUTCOME", "TODOS", "ACCEPT", "FIXES/TODO", "COST")
+	for _, r := range runs {
+		st := r.Stats
+		if st == nil {
+			st = &state.Stats{}
+		}
+		fmt.Printf("  %-15s  %-16s  %-9s  %-15s  %-5s  %-6s  %-10s  %s\n",
+			r.ID,
+			r.StartedAt.Local().Format("2006-01-02 15:04"),
+			st.Elapsed(),
+			r.Outcome,
+			fmt.Sprintf("%d/%d", st.TodosCompleted, st.TodosAttempted),
+			formatRate(st.AcceptRate()),
+			formatRatio(st.FixesPerTodo()),
+			st.Usage.FormatCost())
+	}
+
+	totals := historyTotals(runs)
+	fmt.Println()
+	fmt.Printf("=== Totals (%d runs) ===\n", len(runs))
+	fmt.Println()
+	fmt.Printf("  TODOs completed:        %d/%d\n", totals.TodosCompleted, totals.TodosAttempted)
+	fmt.Printf("  First-pass accept rate: %s\n", formatRate(totals.AcceptRate()))
+	fmt.Printf("  Fixes per TODO:         %s\n", formatRatio(totals.FixesPerTodo()))
+	fmt.Printf("  Claude invocations:     %d\n", totals.ClaudeRuns)
+	if totals.SessionRetries > 0 {
+		fmt.Printf("  Session retries:        %d\n", totals.SessionRetries)
+	}
+	fmt.Printf("  Cost:                   %s\n", totals.Usage.FormatCost())
+	fmt.Printf("  Elapsed time:           %s\n", totals.Elapsed())
+
+	// Compare the older half of the runs with the newer half to show the trend
+	if len(runs) >= 2 {
+		half := len(runs) / 2
+		before, beforeOK := historyTotals(runs[:half]).AcceptRate()
+		after, afterOK := historyTotals(runs[half:]).AcceptRate()
+		if beforeOK && afterOK {
+			fmt.Printf("  Accept rate over time:  %.1f%% → %.1f%% (older half of runs → newer half)\n", before*100, after*100)
+		}
+	}
+	return nil
+}
+
+// historyTotals adds up the stats of runs, including the time spent in them
+func historyTotals(runs []state.RunRecord) *state.Stats {
+	totals := &state.Stats{}
+	for _, r := range runs {
+		if r.Stats == nil {
+			continue
+		}
+		totals.Merge(r.Stats)
+		totals.ElapsedMs += r.Stats.ElapsedMs
+	}
+	return totals
+}
+
+// formatRate formats a share as a percentage, or "-" if there is none
+func formatRate(rate float64, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
+
+// formatRatio formats an average to two decimals, or "-" if there is none
+func formatRatio(ratio float64, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", ratio)
+}
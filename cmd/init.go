@@ -50,7 +50,7 @@ func init() {
 
 func runInit(cmd *cobra.Command, args []string) error {
 	// Check if claude is installed
-	if err := claude.CheckInstalled(); err != nil {
+	if err := checkInstalled(); err != nil {
 		return err
 	}
 
@@ -142,7 +142,12 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 	// Step 3: Set up permissions (but NOT stop hook - that's only for run)
 	fmt.Println("  Configuring Claude settings...")
-	if err := config.SetupPermissions(); err != nil {
+	// The autoclaude path is needed for the hooks
+	autoclaudePath, err := GetExecutablePath()
+	if err != nil {
+		return fmt.Errorf("failed to get autoclaude path: %w", err)
+	}
+	if err := config.SetupPermissions(autoclaudePath); err != nil {
 		return fmt.Errorf("failed to setup settings: %w", err)
 	}
 
@@ -168,19 +173,13 @@ func runInit(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to save planner prompt: %w", err)
 		}
 
-		// Get autoclaude path for stop hook
-		autoclaudePath, err := GetExecutablePath()
-		if err != nil {
-			return fmt.Errorf("failed to get autoclaude path: %w", err)
-		}
-
 		// Set up planner stop hook (only kills Claude when planning_complete file exists)
 		if err := config.SetupPlannerStopHook(autoclaudePath); err != nil {
 			return fmt.Errorf("failed to setup planner stop hook: %w", err)
 		}
 
-		// Run Claude inline with acceptEdits permission mode
-		if err := claude.RunInteractiveWithPromptFile(plannerPath, "acceptEdits", ""); err != nil {
+		// Run Claude inline with the planner's settings (acceptEdits by default)
+		if _, err := claude.RunWithPromptFile(cmd.Context(), sessionRunner(), plannerPath, phaseSessionOptions(state.PhasePlanner)); err != nil {
 			// Clean up hook even on error
 			config.RemovePlannerStopHook(autoclaudePath)
 			config.RemovePlanningComplete()
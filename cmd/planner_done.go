@@ -21,10 +21,11 @@ type PlannerHookInput struct {
 }
 
 var plannerDoneCmd = &cobra.Command{
-	Use:    "_planner-done",
-	Short:  "Internal: called by stop hook when planner stops",
-	Hidden: true,
-	RunE:   runPlannerDone,
+	Use:         "_planner-done",
+	Short:       "Internal: called by stop hook when planner stops",
+	Hidden:      true,
+	Annotations: map[string]string{skipConfigAnnotation: "true"},
+	RunE:        runPlannerDone,
 }
 
 func init() {
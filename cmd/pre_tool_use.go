@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/guard"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+// PreToolUseInput represents the input from Claude Code PreToolUse hook
+type PreToolUseInput struct {
+	SessionID string    `json:"session_id"`
+	Cwd       string    `json:"cwd"`
+	ToolName  string    `json:"tool_name"`
+	ToolInput ToolInput `json:"tool_input"`
+}
+
+// ToolInput holds the tool arguments the hook checks
+type ToolInput struct {
+	FilePath     string `json:"file_path"`     // Write, Edit, MultiEdit
+	NotebookPath string `json:"notebook_path"` // NotebookEdit
+	Command      string `json:"command"`       // Bash
+}
+
+// PreToolUseOutput represents the output for Claude Code PreToolUse hook
+type PreToolUseOutput struct {
+	HookSpecificOutput PreToolUseDecision `json:"hookSpecificOutput"`
+}
+
+// PreToolUseDecision denies or allows a tool call
+type PreToolUseDecision struct {
+	HookEventName            string `json:"hookEventName"`
+	PermissionDecision       string `json:"permissionDecision"`
+	PermissionDecisionReason string `json:"permissionDecisionReason,omitempty"`
+}
+
+var preToolUseCmd = &cobra.Command{
+	Use:         "_pre-tool-use",
+	Short:       "Internal: called by PreToolUse hook to deny tool calls touching protected paths",
+	Hidden:      true,
+	Annotations: map[string]string{skipConfigAnnotation: "true"},
+	RunE:        runPreToolUse,
+}
+
+func init() {
+	rootCmd.AddCommand(preToolUseCmd)
+}
+
+func runPreToolUse(cmd *cobra.Command, args []string) error {
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil
+	}
+	var hookInput PreToolUseInput
+	if err := json.Unmarshal(input, &hookInput); err != nil {
+		return nil // Not a tool call we can check; let Claude's permissions decide
+	}
+
+	// Hooks run from the project directory, but be sure config.yaml is found
+	if dir := os.Getenv("CLAUDE_PROJECT_DIR"); dir != "" {
+		os.Chdir(dir)
+	}
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	cfg, err := config.Load()
+	if err != nil || len(cfg.Protected) == 0 {
+		return nil
+	}
+
+	name, pattern := protectedTouch(hookInput, cfg.Protected, projectDir)
+	if pattern == "" {
+		return nil
+	}
+	denial := fmt.Sprintf("Denied %s touching protected path %s (matches %s)", hookInput.ToolName, name, pattern)
+	appendToNotes(denial)
+	recordEvent(state.Event{Type: state.EventToolDenied, SessionID: hookInput.SessionID, Message: denial})
+	return outputDeny(fmt.Sprintf("%s is a protected path (matches %q in protected_paths) and must not be changed. Leave it as it is and work around it; if the TODO can't be done without changing it, say so in .autoclaude/NOTES.md.", name, pattern))
+}
+
+// protectedTouch returns the first protected path a tool call writes and the
+// pattern protecting it, or empty strings if it writes none. Bash commands
+// count as writing the paths they redirect output to or hand to commands
+// that change files, like rm, mv, cp, tee or sed -i; reading a protected path
+// is fine. Commands that change files without naming them, such as go mod
+// tidy, git checkout . or a script, can't be caught here.
+func protectedTouch(input PreToolUseInput, patterns []string, projectDir string) (string, string) {
+	var paths []string
+	switch input.ToolName {
+	case "Write", "Edit", "MultiEdit":
+		paths = []string{input.ToolInput.FilePath}
+	case "NotebookEdit":
+		paths = []string{input.ToolInput.NotebookPath}
+	case "Bash":
+		paths = guard.CommandWriteTargets(input.ToolInput.Command)
+	}
+
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) && input.Cwd != "" {
+			p = filepath.Join(input.Cwd, p)
+		}
+		name, ok := guard.ProjectPath(projectDir, p)
+		if !ok {
+			continue
+		}
+		if pattern := guard.Protected(patterns, name); pattern != "" {
+			return name, pattern
+		}
+	}
+	return "", ""
+}
+
+// outputDeny outputs JSON to deny a tool call with the reason shown to Claude
+func outputDeny(reason string) error {
+	data, _ := json.Marshal(PreToolUseOutput{HookSpecificOutput: PreToolUseDecision{
+		HookEventName:            "PreToolUse",
+		PermissionDecision:       "deny",
+		PermissionDecisionReason: reason,
+	}})
+	fmt.Println(string(data))
+	return nil
+}
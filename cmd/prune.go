@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"go.coldcutz.net/autoclaude/internal/claude"
@@ -40,7 +41,7 @@ func init() {
 
 func runPrune(cmd *cobra.Command, args []string) error {
 	// Check if claude is installed
-	if err := claude.CheckInstalled(); err != nil {
+	if err := checkInstalled(); err != nil {
 		return err
 	}
 
@@ -97,8 +98,8 @@ func runPrune(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to write pruner prompt: %w", err)
 	}
 
-	// Run Claude in acceptEdits mode
-	if err := claude.RunInteractiveWithPromptFile(promptPath, "acceptEdits", ""); err != nil {
+	// Run Claude with the pruner's settings (acceptEdits by default)
+	if _, err := claude.RunWithPromptFile(cmd.Context(), sessionRunner(), promptPath, phaseSessionOptions(state.PhasePruner)); err != nil {
 		return fmt.Errorf("pruner phase failed: %w", err)
 	}
 
@@ -108,7 +109,7 @@ func runPrune(cmd *cobra.Command, args []string) error {
 	}
 
 	// Update state with last prune time
-	s.LastPruneAt = 0 // Will be set to current time by the caller for inline pruning
+	s.LastPruneAt = time.Now().Unix()
 	s.TodosSincePrune = 0
 	if err := s.Save(); err != nil {
 		fmt.Printf("Warning: failed to save state: %v\n", err)
@@ -116,35 +117,3 @@ func runPrune(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
-
-// runInlinePruner runs the pruner programmatically (not via CLI command)
-// Used by the main run loop for periodic pruning
-func runInlinePruner(s *state.State, aggressive bool) error {
-	fmt.Println("\n=== Running Periodic TODO Pruning ===")
-
-	// Build prompt params
-	params := prompt.PromptParams{
-		Goal:    s.Goal,
-		TestCmd: s.TestCmd,
-	}
-	if aggressive {
-		params.PrunerMode = "aggressive"
-	}
-
-	// Generate pruner prompt
-	prunerPrompt := prompt.GeneratePruner(params)
-
-	// Write prompt to file
-	promptPath, err := prompt.WriteCurrentPrompt(prunerPrompt)
-	if err != nil {
-		return fmt.Errorf("failed to write pruner prompt: %w", err)
-	}
-
-	// Run Claude in acceptEdits mode
-	if err := claude.RunInteractiveWithPromptFile(promptPath, "acceptEdits", ""); err != nil {
-		return fmt.Errorf("pruner phase failed: %w", err)
-	}
-
-	fmt.Println("  ✓ Pruning complete")
-	return nil
-}
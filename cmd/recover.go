@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"go.coldcutz.net/autoclaude/internal/git"
+)
+
+var recoverList bool
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover [branch]",
+	Short: "Restore uncommitted changes saved by resume",
+	Long: `Restore the uncommitted changes that 'autoclaude resume' saved to an
+autoclaude/recovery-<timestamp> branch before cleaning the working directory.
+
+Without an argument the most recent save is restored. The changes come back
+as uncommitted edits and the recovery branch is deleted. The working directory
+must be clean.`,
+	Args: cobra.MaximumNArgs(1),
+	// Recovery must work even with a broken config.yaml
+	Annotations: map[string]string{skipConfigAnnotation: "true"},
+	RunE:        runRecover,
+}
+
+func init() {
+	rootCmd.AddCommand(recoverCmd)
+	recoverCmd.Flags().BoolVarP(&recoverList, "list", "l", false, "List saved changes instead of restoring them")
+}
+
+func runRecover(cmd *cobra.Command, args []string) error {
+	branches, err := git.RecoveryBranches()
+	if err != nil {
+		return fmt.Errorf("failed to list recovery branches: %w", err)
+	}
+	if recoverList {
+		return listRecoveryBranches(branches)
+	}
+
+	var branch string
+	switch {
+	case len(args) == 1:
+		branch = args[0]
+		if !git.BranchExists(branch) {
+			return fmt.Errorf("no branch named %s (run 'autoclaude recover --list')", branch)
+		}
+	case len(branches) == 0:
+		fmt.Println("No saved changes to recover.")
+		return nil
+	default:
+		branch = branches[0]
+	}
+
+	files, err := git.SnapshotFiles(branch)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", branch, err)
+	}
+	if err := git.RestoreSnapshot(branch); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", branch, err)
+	}
+	fmt.Printf("Restored from %s:\n", branch)
+	for _, file := range files {
+		fmt.Printf("  %s\n", file)
+	}
+	if err := git.DeleteBranch(branch); err != nil {
+		fmt.Printf("Warning: failed to delete %s: %v\n", branch, err)
+	}
+	return nil
+}
+
+// listRecoveryBranches prints each recovery branch with the files it holds
+func listRecoveryBranches(branches []string) error {
+	if len(branches) == 0 {
+		fmt.Println("No saved changes to recover.")
+		return nil
+	}
+	for _, branch := range branches {
+		files, err := git.SnapshotFiles(branch)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", branch, err)
+		}
+		fmt.Printf("%s (%d files)\n", branch, len(files))
+		for _, file := range files {
+			fmt.Printf("  %s\n", file)
+		}
+	}
+	return nil
+}
@@ -5,13 +5,15 @@ import (
 	"os/exec"
 
 	"github.com/spf13/cobra"
-	"go.coldcutz.net/autoclaude/internal/claude"
-	"go.coldcutz.net/autoclaude/internal/config"
-	"go.coldcutz.net/autoclaude/internal/prompt"
+	"go.coldcutz.net/autoclaude/internal/engine"
+	"go.coldcutz.net/autoclaude/internal/git"
 	"go.coldcutz.net/autoclaude/internal/state"
 )
 
-var resumeCoderSonnet bool
+var (
+	resumeFlags   loopFlags
+	resumeDiscard bool
+)
 
 var resumeCmd = &cobra.Command{
 	Use:   "resume",
@@ -21,18 +23,23 @@ var resumeCmd = &cobra.Command{
 Use this after:
 - An error or interruption
 - Manual intervention
-- To continue after reviewing changes`,
+- To continue after reviewing changes
+
+Uncommitted changes are saved to an autoclaude/recovery-<timestamp> branch
+before the working directory is cleaned. Restore them with 'autoclaude recover',
+or pass --discard to throw them away instead.`,
 	RunE: runResume,
 }
 
 func init() {
 	rootCmd.AddCommand(resumeCmd)
-	resumeCmd.Flags().BoolVar(&resumeCoderSonnet, "coder-sonnet", false, "Use Sonnet model for coder/fixer phases")
+	resumeFlags.register(resumeCmd)
+	resumeCmd.Flags().BoolVar(&resumeDiscard, "discard", false, "Discard uncommitted changes instead of saving them to a recovery branch")
 }
 
 func runResume(cmd *cobra.Command, args []string) error {
 	// Check if claude is installed
-	if err := claude.CheckInstalled(); err != nil {
+	if err := checkInstalled(); err != nil {
 		return err
 	}
 
@@ -53,270 +60,47 @@ func runResume(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	// Initialize stats if not present
-	if s.Stats == nil {
-		s.Stats = &state.Stats{}
+	// Settle the configuration before touching the working directory, so a
+	// bad flag or config.yaml doesn't cost the uncommitted changes
+	cfg, err := resumeFlags.resolve(cmd)
+	if err != nil {
+		return err
+	}
+	autoclaudePath, err := GetExecutablePath()
+	if err != nil {
+		return fmt.Errorf("failed to get autoclaude path: %w", err)
 	}
 
 	// Clean up working directory
 	if hasUncommittedChanges() {
+		if !resumeDiscard {
+			if err := saveUncommittedChanges(); err != nil {
+				return fmt.Errorf("failed to save uncommitted changes (use --discard to throw them away): %w", err)
+			}
+		}
 		fmt.Println("Cleaning up uncommitted changes...")
 		if err := cleanWorkingDirectory(); err != nil {
 			return fmt.Errorf("failed to clean working directory: %w", err)
 		}
 	}
 
-	// Determine coder model
-	coderModel := ""
-	if resumeCoderSonnet {
-		coderModel = "sonnet"
-	}
-
 	fmt.Println("Resuming autoclaude loop...")
 	fmt.Printf("  Current step: %s\n", s.Step)
 	fmt.Printf("  TODO iteration: %d\n", s.Iteration)
 	if currentTodo := state.GetCurrentTodo(); currentTodo != "(unknown)" {
 		fmt.Printf("  Current TODO: %s\n", currentTodo)
 	}
-	if coderModel != "" {
-		fmt.Printf("  Coder model: %s\n", coderModel)
-	}
+	printLoopConfig(cfg)
 	fmt.Println()
 
-	autoclaudePath, err := GetExecutablePath()
-	if err != nil {
-		return fmt.Errorf("failed to get autoclaude path: %w", err)
-	}
-
-	// Build prompt params
-	params := prompt.PromptParams{
-		Goal:    s.Goal,
-		TestCmd: s.TestCmd,
+	// The engine picks up from the persisted step
+	ctx, stop := loopContext(cmd)
+	defer stop()
+	e := engine.New(s, resumeFlags.engineOptions(cfg, autoclaudePath))
+	if err := e.Run(ctx); err != nil {
+		return handleLoopStop(err, s.Stats)
 	}
 
-	// Enable stop hook
-	if err := config.SetupStopHook(autoclaudePath); err != nil {
-		return fmt.Errorf("failed to setup stop hook: %w", err)
-	}
-	defer config.RemoveStopHook(autoclaudePath)
-
-	// Resume from current step - run ONE phase then continue into main loop
-	switch s.Step {
-	case state.StepCoder:
-		// Re-run coder for current TODO
-		fmt.Printf("=== RESUMING CODER ===\n")
-		s.UpdateStatus(fmt.Sprintf("Resuming: %s", state.GetCurrentTodo()))
-
-		commitBefore := getCommitHash()
-		coderPrompt, _ := prompt.LoadCoder()
-		promptPath, _ := prompt.WriteCurrentPrompt(coderPrompt)
-		if err := runClaudePhase(promptPath, s.Stats, coderModel); err != nil {
-			return fmt.Errorf("coder phase failed: %w", err)
-		}
-		checkCommitCreated(commitBefore, "Coder")
-
-	case state.StepCritic:
-		// Run critic for current TODO, then continue
-		fmt.Printf("=== RESUMING CRITIC ===\n")
-		s.UpdateStatus("Resuming critic review...")
-
-		state.ClearCriticVerdict()
-		criticPrompt, _ := prompt.LoadCritic()
-		promptPath, _ := prompt.WriteCurrentPrompt(criticPrompt)
-		if err := runClaudePhase(promptPath, s.Stats, ""); err != nil {
-			return fmt.Errorf("critic phase failed: %w", err)
-		}
-
-		verdict, content := state.GetCriticVerdict()
-		if verdict == state.VerdictNeedsFixes {
-			// Run fixer
-			fmt.Println("=== FIXER ===")
-			s.Step = state.StepCoder
-			s.Save()
-			s.Stats.FixAttempts++
-
-			fixerCommitBefore := getCommitHash()
-			fixerPrompt := prompt.GenerateFixer(params, content, state.GetCurrentTodo())
-			promptPath, _ = prompt.WriteCurrentPrompt(fixerPrompt)
-			if err := runClaudePhase(promptPath, s.Stats, coderModel); err != nil {
-				return fmt.Errorf("fixer phase failed: %w", err)
-			}
-			checkCommitCreated(fixerCommitBefore, "Fixer")
-		} else if verdict == state.VerdictApproved || verdict == state.VerdictMinorIssues {
-			s.Stats.TodosCompleted++
-			state.ClearCurrentTodo()
-		}
-
-	case state.StepEvaluator:
-		// Run evaluator
-		fmt.Println("=== RESUMING EVALUATOR ===")
-		s.UpdateStatus("Resuming evaluator...")
-
-		// Clean up any stale evaluation_complete file
-		config.RemoveEvaluationComplete()
-
-		// Set up evaluator stop hook
-		if err := config.SetupEvaluatorStopHook(autoclaudePath); err != nil {
-			return fmt.Errorf("failed to setup evaluator stop hook: %w", err)
-		}
-
-		evalPrompt, _ := prompt.LoadEvaluator()
-		promptPath, _ := prompt.WriteCurrentPrompt(evalPrompt)
-		if err := runClaudePhase(promptPath, s.Stats, ""); err != nil {
-			config.RemoveEvaluatorStopHook(autoclaudePath)
-			config.RemoveEvaluationComplete()
-			return fmt.Errorf("evaluator phase failed: %w", err)
-		}
-
-		// Clean up
-		config.RemoveEvaluatorStopHook(autoclaudePath)
-		config.RemoveEvaluationComplete()
-	}
-
-	// Now continue with the normal run loop for remaining TODOs
-	return continueRunLoop(s, params, autoclaudePath, coderModel)
-}
-
-// continueRunLoop continues the main loop after resuming
-func continueRunLoop(s *state.State, params prompt.PromptParams, autoclaudePath string, coderModel string) error {
-	// Process remaining TODOs
-	for hasIncompleteTodos() {
-		s.Iteration++
-		s.RetryCount = 0
-		s.Save()
-
-		currentTodo := state.GetNextTodo()
-		state.SetCurrentTodo(currentTodo)
-		s.Stats.TodosAttempted++
-
-		// === CODER PHASE ===
-		fmt.Printf("\n=== TODO %d: CODER ===\n", s.Iteration)
-		fmt.Printf("  Working on: %s\n", currentTodo)
-		s.Step = state.StepCoder
-		s.Save()
-		s.UpdateStatus(fmt.Sprintf("Working on: %s", currentTodo))
-
-		commitBefore := getCommitHash()
-		coderPrompt, _ := prompt.LoadCoder()
-		promptPath, _ := prompt.WriteCurrentPrompt(coderPrompt)
-		if err := runClaudePhase(promptPath, s.Stats, coderModel); err != nil {
-			return fmt.Errorf("coder phase failed: %w", err)
-		}
-		checkCommitCreated(commitBefore, "Coder")
-
-		// Inner loop: critic review with fix retries
-		wasApproved := false
-		for retry := 0; retry < maxFixRetries; retry++ {
-			fmt.Printf("=== TODO %d: CRITIC (attempt %d/%d) ===\n", s.Iteration, retry+1, maxFixRetries)
-			s.Step = state.StepCritic
-			s.RetryCount = retry
-			s.Save()
-			s.UpdateStatus("Running critic review...")
-
-			state.ClearCriticVerdict()
-
-			criticPrompt, _ := prompt.LoadCritic()
-			promptPath, _ = prompt.WriteCurrentPrompt(criticPrompt)
-			if err := runClaudePhase(promptPath, s.Stats, ""); err != nil {
-				return fmt.Errorf("critic phase failed: %w", err)
-			}
-
-			verdict, content := state.GetCriticVerdict()
-
-			switch verdict {
-			case state.VerdictApproved:
-				fmt.Println("  ✓ Critic: APPROVED")
-				s.Stats.CriticApprovals++
-				s.Stats.TodosCompleted++
-				if retry > 0 {
-					s.Stats.FixSuccesses++
-				}
-				wasApproved = true
-				goto nextTodo
-
-			case state.VerdictMinorIssues:
-				fmt.Println("  ✓ Critic: MINOR_ISSUES (added to TODOs for later)")
-				s.Stats.CriticMinor++
-				s.Stats.TodosCompleted++
-				if retry > 0 {
-					s.Stats.FixSuccesses++
-				}
-				wasApproved = true
-				goto nextTodo
-
-			case state.VerdictNeedsFixes:
-				fmt.Printf("  ✗ Critic: NEEDS_FIXES (retry %d/%d)\n", retry+1, maxFixRetries)
-				s.Stats.CriticRejections++
-				if retry < maxFixRetries-1 {
-					fmt.Println("=== FIXER ===")
-					s.Step = state.StepCoder
-					s.Save()
-					s.UpdateStatus(fmt.Sprintf("Fixing: %s", currentTodo))
-					s.Stats.FixAttempts++
-
-					fixerCommitBefore := getCommitHash()
-					fixerPrompt := prompt.GenerateFixer(params, content, state.GetCurrentTodo())
-					promptPath, _ = prompt.WriteCurrentPrompt(fixerPrompt)
-					if err := runClaudePhase(promptPath, s.Stats, coderModel); err != nil {
-						return fmt.Errorf("fixer phase failed: %w", err)
-					}
-					checkCommitCreated(fixerCommitBefore, "Fixer")
-				}
-
-			default:
-				fmt.Println("  ? Critic: No clear verdict, assuming needs review")
-				if retry < maxFixRetries-1 {
-					continue
-				}
-			}
-		}
-
-		if !wasApproved {
-			fmt.Printf("  ⚠ Max retries (%d) reached for TODO %d, moving on\n", maxFixRetries, s.Iteration)
-		}
-
-	nextTodo:
-		state.ClearCurrentTodo()
-		s.Save()
-	}
-
-	// === EVALUATOR PHASE ===
-	fmt.Println("\n=== EVALUATOR ===")
-	s.Step = state.StepEvaluator
-	s.Save()
-	s.UpdateStatus("Running evaluator...")
-
-	// Clean up any stale evaluation_complete file
-	config.RemoveEvaluationComplete()
-
-	// Set up evaluator stop hook (only kills Claude when evaluation_complete file exists)
-	if err := config.SetupEvaluatorStopHook(autoclaudePath); err != nil {
-		return fmt.Errorf("failed to setup evaluator stop hook: %w", err)
-	}
-
-	evalPrompt, _ := prompt.LoadEvaluator()
-	promptPath, _ := prompt.WriteCurrentPrompt(evalPrompt)
-	if err := runClaudePhase(promptPath, s.Stats, ""); err != nil {
-		config.RemoveEvaluatorStopHook(autoclaudePath)
-		config.RemoveEvaluationComplete()
-		return fmt.Errorf("evaluator phase failed: %w", err)
-	}
-
-	// Clean up hook and marker
-	config.RemoveEvaluatorStopHook(autoclaudePath)
-	config.RemoveEvaluationComplete()
-
-	// Check if evaluator added more TODOs (user requested more work)
-	if hasIncompleteTodos() {
-		fmt.Println("User requested more work. Continuing...")
-		return continueRunLoop(s, params, autoclaudePath, coderModel)
-	}
-
-	s.Step = state.StepDone
-	s.Save()
-	s.UpdateStatus("Complete!")
-	fmt.Println("\n=== COMPLETE ===")
-
 	printStats(s.Stats)
 
 	return nil
@@ -332,6 +116,25 @@ func hasUncommittedChanges() bool {
 	return len(output) > 0
 }
 
+// saveUncommittedChanges snapshots uncommitted and untracked changes to a
+// recovery branch and lists what was saved
+func saveUncommittedChanges() error {
+	branch, err := git.Snapshot("autoclaude: uncommitted changes saved by resume")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved uncommitted changes to %s:\n", branch)
+	files, err := git.SnapshotFiles(branch)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Printf("  %s\n", file)
+	}
+	fmt.Println("Restore them with 'autoclaude recover'.")
+	return nil
+}
+
 // cleanWorkingDirectory resets the working directory to the last commit
 func cleanWorkingDirectory() error {
 	// Reset tracked files
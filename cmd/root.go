@@ -18,7 +18,9 @@ Commands:
   resume   Resume from last saved state after interruption
   status   Display current progress and state
   prune    Clean up and organize the TODO list
-  watch    Watch progress with auto-refresh`,
+  watch    Watch progress with auto-refresh
+  config   Get, set and validate settings in .autoclaude/config.yaml`,
+	PersistentPreRunE: loadProjectConfig,
 }
 
 func Execute() {
@@ -2,24 +2,15 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/spf13/cobra"
-	"go.coldcutz.net/autoclaude/internal/claude"
-	"go.coldcutz.net/autoclaude/internal/config"
-	"go.coldcutz.net/autoclaude/internal/prompt"
+	"go.coldcutz.net/autoclaude/internal/engine"
 	"go.coldcutz.net/autoclaude/internal/state"
 )
 
-const maxFixRetries = 3
-
-var (
-	runCoderSonnet bool
-	runPruneInterval int // 0 means use default
-)
+var runFlags loopFlags
 
 var runCmd = &cobra.Command{
 	Use:   "run",
@@ -27,10 +18,10 @@ var runCmd = &cobra.Command{
 	Long: `Start the autoclaude coder-critic loop.
 
 The loop proceeds as follows:
-1. Coder: Works on highest priority TODO
+1. Coder: Works on the highest priority TODO whose dependencies are done
 2. Critic: Reviews changes
    - APPROVED or MINOR_ISSUES: Move to next TODO
-   - NEEDS_FIXES: Coder retries (up to 3 times)
+   - NEEDS_FIXES: Fixer retries (up to the retry limit, 3 by default)
 3. Repeat until all TODOs complete
 4. Evaluator: Final check that goal is met
 
@@ -40,13 +31,12 @@ Each phase runs in a fresh Claude session to maintain quality.`,
 
 func init() {
 	rootCmd.AddCommand(runCmd)
-	runCmd.Flags().BoolVar(&runCoderSonnet, "coder-sonnet", false, "Use Sonnet model for coder/fixer phases")
-	runCmd.Flags().IntVar(&runPruneInterval, "prune-interval", 0, "Number of TODOs between auto-pruning (0 for default 5, -1 to disable)")
+	runFlags.register(runCmd)
 }
 
 func runRun(cmd *cobra.Command, args []string) error {
 	// Check if claude is installed
-	if err := claude.CheckInstalled(); err != nil {
+	if err := checkInstalled(); err != nil {
 		return err
 	}
 
@@ -61,279 +51,49 @@ func runRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
+	cfg, err := runFlags.resolve(cmd)
+	if err != nil {
+		return err
+	}
+
 	// Reset state for new run
 	s.Step = state.StepCoder
 	s.Iteration = 0
 	s.RetryCount = 0
 	s.LastError = ""
-	s.Stats = &state.Stats{} // Initialize fresh stats
+	s.FixInstructions = ""
+	s.StartRun(time.Now()) // New run ID and fresh stats; earlier runs are kept in the history
 	if err := s.Save(); err != nil {
 		return fmt.Errorf("failed to save state: %w", err)
 	}
 
+	// Start from a fresh TODO selection
+	state.ClearCurrentTodo()
+
 	autoclaudePath, err := GetExecutablePath()
 	if err != nil {
 		return fmt.Errorf("failed to get autoclaude path: %w", err)
 	}
 
-	// Build prompt params for generating fix prompts
-	params := prompt.PromptParams{
-		Goal:    s.Goal,
-		TestCmd: s.TestCmd,
-	}
-
-	// Determine coder model
-	coderModel := ""
-	if runCoderSonnet {
-		coderModel = "sonnet"
-	}
-
-	// Determine prune interval
-	pruneInterval := state.DefaultPruneInterval
-	if runPruneInterval > 0 {
-		pruneInterval = runPruneInterval
-	} else if runPruneInterval == -1 {
-		pruneInterval = 0 // Disabled
-	}
-
 	fmt.Println("Starting autoclaude loop...")
 	fmt.Printf("  Goal: %s\n", s.Goal)
 	fmt.Printf("  Test command: %s\n", s.TestCmd)
-	if coderModel != "" {
-		fmt.Printf("  Coder model: %s\n", coderModel)
-	}
+	printLoopConfig(cfg)
 	fmt.Println()
 
-	// Enable stop hook for all phases (kills Claude when it stops to return control)
-	if err := config.SetupStopHook(autoclaudePath); err != nil {
-		return fmt.Errorf("failed to setup stop hook: %w", err)
-	}
-	defer config.RemoveStopHook(autoclaudePath)
-
-	// Outer loop: process TODOs until all complete (no limit)
-	for hasIncompleteTodos() {
-		s.Iteration++
-		s.RetryCount = 0
-		s.Save()
-
-		// Get next TODO and save it as current (before coder checks it off)
-		currentTodo := state.GetNextTodo()
-		state.SetCurrentTodo(currentTodo)
-		s.Stats.TodosAttempted++
-
-		// === CODER PHASE ===
-		fmt.Printf("\n=== TODO %d: CODER ===\n", s.Iteration)
-		fmt.Printf("  Working on: %s\n", currentTodo)
-		s.Step = state.StepCoder
-		s.Save()
-		s.UpdateStatus(fmt.Sprintf("Working on: %s", currentTodo))
-
-		commitBefore := getCommitHash()
-		coderPrompt, _ := prompt.LoadCoder()
-		promptPath, _ := prompt.WriteCurrentPrompt(coderPrompt)
-		if err := runClaudePhase(promptPath, s.Stats, coderModel); err != nil {
-			return fmt.Errorf("coder phase failed: %w", err)
-		}
-		checkCommitCreated(commitBefore, "Coder")
-
-		// Inner loop: critic review with fix retries (max 3)
-		wasApproved := false
-		for retry := 0; retry < maxFixRetries; retry++ {
-			// === CRITIC PHASE ===
-			fmt.Printf("=== TODO %d: CRITIC (attempt %d/%d) ===\n", s.Iteration, retry+1, maxFixRetries)
-			s.Step = state.StepCritic
-			s.RetryCount = retry
-			s.Save()
-			s.UpdateStatus("Running critic review...")
-
-			state.ClearCriticVerdict()
-
-			criticPrompt, _ := prompt.LoadCritic()
-			promptPath, _ = prompt.WriteCurrentPrompt(criticPrompt)
-			if err := runClaudePhase(promptPath, s.Stats, ""); err != nil {
-				return fmt.Errorf("critic phase failed: %w", err)
-			}
-
-			verdict, content := state.GetCriticVerdict()
-
-			switch verdict {
-			case state.VerdictApproved:
-				fmt.Println("  ✓ Critic: APPROVED")
-				s.Stats.CriticApprovals++
-				s.Stats.TodosCompleted++
-				if retry > 0 {
-					s.Stats.FixSuccesses++
-				}
-				wasApproved = true
-				goto nextTodo
-
-			case state.VerdictMinorIssues:
-				fmt.Println("  ✓ Critic: MINOR_ISSUES (added to TODOs for later)")
-				s.Stats.CriticMinor++
-				s.Stats.TodosCompleted++
-				if retry > 0 {
-					s.Stats.FixSuccesses++
-				}
-				wasApproved = true
-				goto nextTodo
-
-			case state.VerdictNeedsFixes:
-				fmt.Printf("  ✗ Critic: NEEDS_FIXES (retry %d/%d)\n", retry+1, maxFixRetries)
-				s.Stats.CriticRejections++
-				if retry < maxFixRetries-1 {
-					// Run fixer with critic's feedback
-					fmt.Println("=== FIXER ===")
-					s.Step = state.StepCoder
-					s.Save()
-					s.UpdateStatus(fmt.Sprintf("Fixing: %s", currentTodo))
-					s.Stats.FixAttempts++
-
-					fixerCommitBefore := getCommitHash()
-					// Use state.GetCurrentTodo() to read from file (robust across restarts)
-					fixerPrompt := prompt.GenerateFixer(params, content, state.GetCurrentTodo())
-					promptPath, _ = prompt.WriteCurrentPrompt(fixerPrompt)
-					if err := runClaudePhase(promptPath, s.Stats, coderModel); err != nil {
-						return fmt.Errorf("fixer phase failed: %w", err)
-					}
-					checkCommitCreated(fixerCommitBefore, "Fixer")
-				}
-
-			default:
-				fmt.Println("  ? Critic: No clear verdict, assuming needs review")
-				if retry < maxFixRetries-1 {
-					continue
-				}
-			}
-		}
-
-		// Exhausted retries
-		if !wasApproved {
-			fmt.Printf("  ⚠ Max retries (%d) reached for TODO %d, moving on\n", maxFixRetries, s.Iteration)
-		}
-
-	nextTodo:
-		state.ClearCurrentTodo()
-		s.Save()
-
-		// Check if we need to run periodic pruning
-		if pruneInterval > 0 && s.Stats.TodosCompleted > 0 && s.Stats.TodosCompleted%pruneInterval == 0 {
-			s.TodosSincePrune = 0
-			s.LastPruneAt = time.Now().Unix()
-			s.Save()
-			if err := runInlinePruner(s, false); err != nil {
-				fmt.Printf("  ⚠ Pruning failed: %v\n", err)
-			}
-		}
+	ctx, stop := loopContext(cmd)
+	defer stop()
+	e := engine.New(s, runFlags.engineOptions(cfg, autoclaudePath))
+	if err := e.Run(ctx); err != nil {
+		return handleLoopStop(err, s.Stats)
 	}
 
-	// === EVALUATOR PHASE ===
-	fmt.Println("\n=== EVALUATOR ===")
-	s.Step = state.StepEvaluator
-	s.Save()
-	s.UpdateStatus("Running evaluator...")
-
-	// Clean up any stale evaluation_complete file
-	config.RemoveEvaluationComplete()
-
-	// Set up evaluator stop hook (only kills Claude when evaluation_complete file exists)
-	if err := config.SetupEvaluatorStopHook(autoclaudePath); err != nil {
-		return fmt.Errorf("failed to setup evaluator stop hook: %w", err)
-	}
-
-	evalPrompt, _ := prompt.LoadEvaluator()
-	promptPath, _ := prompt.WriteCurrentPrompt(evalPrompt)
-	if err := runClaudePhase(promptPath, s.Stats, ""); err != nil {
-		config.RemoveEvaluatorStopHook(autoclaudePath)
-		config.RemoveEvaluationComplete()
-		return fmt.Errorf("evaluator phase failed: %w", err)
-	}
-
-	// Clean up hook and marker
-	config.RemoveEvaluatorStopHook(autoclaudePath)
-	config.RemoveEvaluationComplete()
-
-	// Check if evaluator added more TODOs (user requested more work)
-	if hasIncompleteTodos() {
-		fmt.Println("User requested more work. Continuing...")
-		return runRun(cmd, args) // Recursive call to process new TODOs
-	}
-
-	s.Step = state.StepDone
-	s.Save()
-	s.UpdateStatus("Complete!")
-	fmt.Println("\n=== COMPLETE ===")
-
 	// Print stats summary
 	printStats(s.Stats)
 
 	return nil
 }
 
-// runClaudePhase runs a Claude session in the foreground and waits for it to complete
-// model can be "sonnet", "opus", or empty for default
-func runClaudePhase(promptFile string, stats *state.Stats, model string) error {
-	if stats != nil {
-		stats.ClaudeRuns++
-	}
-	return claude.RunInteractiveWithPromptFile(promptFile, "acceptEdits", model)
-}
-
-// hasIncompleteTodos checks if there are incomplete TODOs
-func hasIncompleteTodos() bool {
-	data, err := os.ReadFile(state.TodoPath())
-	if err != nil {
-		return false
-	}
-	return strings.Contains(string(data), "- [ ]")
-}
-
-// getCommitHash returns the current HEAD commit hash (short form)
-func getCommitHash() string {
-	cmd := exec.Command("git", "rev-parse", "--short", "HEAD")
-	output, err := cmd.Output()
-	if err != nil {
-		return ""
-	}
-	return strings.TrimSpace(string(output))
-}
-
-// checkCommitCreated verifies a new commit was made; if not, forces one
-func checkCommitCreated(beforeHash, phase string) {
-	afterHash := getCommitHash()
-	if afterHash == beforeHash {
-		fmt.Printf("  ⚠ %s did not commit, forcing commit...\n", phase)
-		forceCommit(phase)
-	}
-}
-
-// forceCommit stages all changes and commits them
-func forceCommit(phase string) {
-	// Check if there are any changes to commit
-	statusCmd := exec.Command("git", "status", "--porcelain")
-	output, err := statusCmd.Output()
-	if err != nil || len(strings.TrimSpace(string(output))) == 0 {
-		fmt.Println("  (no changes to commit)")
-		return
-	}
-
-	// Stage all changes
-	addCmd := exec.Command("git", "add", "-A")
-	if err := addCmd.Run(); err != nil {
-		fmt.Printf("  ✗ Failed to stage changes: %v\n", err)
-		return
-	}
-
-	// Commit
-	msg := fmt.Sprintf("autoclaude: %s changes (auto-committed)", strings.ToLower(phase))
-	commitCmd := exec.Command("git", "commit", "-m", msg)
-	if err := commitCmd.Run(); err != nil {
-		fmt.Printf("  ✗ Failed to commit: %v\n", err)
-		return
-	}
-
-	fmt.Printf("  ✓ Auto-committed as: %s\n", getCommitHash())
-}
-
 // printStats displays run statistics
 func printStats(stats *state.Stats) {
 	if stats == nil {
@@ -352,16 +112,92 @@ func printStats(stats *state.Stats) {
 	fmt.Println()
 	fmt.Printf("  Fix attempts:        %d\n", stats.FixAttempts)
 	fmt.Printf("  Fix successes:       %d\n", stats.FixSuccesses)
+	fmt.Println()
+	fmt.Printf("  Test runs:           %d\n", stats.TestRuns)
+	fmt.Printf("  Test failures:       %d\n", stats.TestFailures)
+	if stats.LintRuns > 0 {
+		fmt.Printf("  Lint runs:           %d\n", stats.LintRuns)
+		fmt.Printf("  Lint failures:       %d\n", stats.LintFailures)
+	}
+	if stats.CommitsRefused > 0 {
+		fmt.Printf("  Commits refused:     %d\n", stats.CommitsRefused)
+	}
+	if stats.SessionRetries > 0 {
+		fmt.Printf("  Session retries:     %d\n", stats.SessionRetries)
+	}
+	fmt.Printf("  Elapsed time:        %s\n", stats.Elapsed())
 
 	// Calculate rates
-	totalReviews := stats.CriticApprovals + stats.CriticMinor + stats.CriticRejections
-	if totalReviews > 0 {
-		acceptRate := float64(stats.CriticApprovals+stats.CriticMinor) / float64(totalReviews) * 100
-		fmt.Printf("\n  First-pass accept rate: %.1f%%\n", acceptRate)
+	if acceptRate, ok := stats.AcceptRate(); ok {
+		fmt.Printf("\n  First-pass accept rate: %.1f%%\n", acceptRate*100)
 	}
 	if stats.FixAttempts > 0 {
 		fixRate := float64(stats.FixSuccesses) / float64(stats.FixAttempts) * 100
 		fmt.Printf("  Fix success rate:       %.1f%%\n", fixRate)
 	}
+	if stats.Usage.Sessions > 0 {
+		fmt.Println()
+		printUsage(stats, 5)
+	}
 	fmt.Println("──────────────────────")
 }
+
+// printUsage displays token and cost totals by phase and for the n most
+// expensive TODOs
+func printUsage(stats *state.Stats, n int) {
+	u := stats.Usage
+	fmt.Printf("  Tokens:              %s in, %s out, %s cache read, %s cache write\n",
+		formatTokens(u.InputTokens), formatTokens(u.OutputTokens), formatTokens(u.CacheReadTokens), formatTokens(u.CacheCreationTokens))
+	fmt.Printf("  Cost:                %s\n", u.FormatCost())
+
+	fmt.Printf("  By phase:\n")
+	for _, phase := range state.Phases {
+		if pu := stats.PhaseUsage[phase]; pu != nil {
+			fmt.Printf("    %-10s %3d sessions  %8s tokens  %s\n", phase, pu.Sessions, formatTokens(pu.TotalTokens()), pu.FormatCost())
+		}
+	}
+
+	if len(stats.TodoUsage) == 0 {
+		return
+	}
+	titles := make([]string, 0, len(stats.TodoUsage))
+	for title := range stats.TodoUsage {
+		titles = append(titles, title)
+	}
+	sort.Slice(titles, func(i, j int) bool {
+		ci, cj := stats.TodoUsage[titles[i]].CostUSD, stats.TodoUsage[titles[j]].CostUSD
+		if ci != cj {
+			return ci > cj
+		}
+		return titles[i] < titles[j]
+	})
+	if len(titles) > n {
+		titles = titles[:n]
+	}
+	fmt.Printf("  Most expensive TODOs:\n")
+	for _, title := range titles {
+		tu := stats.TodoUsage[title]
+		fmt.Printf("    %-40s %8s tokens  %s\n", truncateTitle(title, 40), formatTokens(tu.TotalTokens()), tu.FormatCost())
+	}
+}
+
+// formatTokens formats a token count compactly, e.g. 1.2M or 45.3k
+func formatTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// truncateTitle shortens a TODO title to fit in width runes
+func truncateTitle(title string, width int) string {
+	runes := []rune(title)
+	if len(runes) <= width {
+		return title
+	}
+	return string(runes[:width-1]) + "…"
+}
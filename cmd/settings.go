@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.coldcutz.net/autoclaude/internal/claude"
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/engine"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+// skipConfigAnnotation marks commands that must not fail on a broken
+// config.yaml, such as hooks called by Claude
+const skipConfigAnnotation = "autoclaude/skip-config"
+
+// projectConfig is the resolved configuration, loaded before every command
+var projectConfig *config.ProjectConfig
+
+// loadProjectConfig resolves config.yaml and the environment for the command
+// about to run, so invalid settings are reported before any work starts
+func loadProjectConfig(cmd *cobra.Command, args []string) error {
+	if cmd.Annotations[skipConfigAnnotation] == "true" {
+		return nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	projectConfig = cfg
+	return nil
+}
+
+// currentConfig returns the loaded configuration, or the defaults if none was loaded
+func currentConfig() *config.ProjectConfig {
+	if projectConfig == nil {
+		return config.Default()
+	}
+	return projectConfig
+}
+
+// phaseSessionOptions returns the session options for a phase run outside the loop
+func phaseSessionOptions(phase state.Phase) claude.SessionOptions {
+	return currentConfig().Phases.For(phase).SessionOptions()
+}
+
+// checkInstalled checks that the configured agent CLI is installed
+func checkInstalled() error {
+	return claude.CheckInstalled(currentConfig().Runner.Command)
+}
+
+// sessionRunner returns the runner for sessions run outside the loop, such as
+// planning and manual pruning, which always run attached to the terminal
+func sessionRunner() claude.Runner {
+	return claude.Interactive{Command: currentConfig().Runner.Command}
+}
+
+// loopContext returns a context cancelled by Ctrl-C or SIGTERM, so that the
+// loop stops the session in progress instead of leaving it running
+func loopContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+}
+
+// loopFlags holds the flags shared by run and resume. Flags that are given
+// override the environment and config.yaml for this invocation only.
+type loopFlags struct {
+	coderSonnet   bool
+	headless      bool
+	pruneInterval int
+	retryLimit    int
+	parallel      int
+	branchPerTodo bool
+	onExhausted   string
+	maxCost       float64
+	maxTokens     int
+	maxTime       time.Duration
+	maxSessions   int
+}
+
+// loopFlagKeys maps flags to the config keys they override
+var loopFlagKeys = map[string]string{
+	"prune-interval":  "prune_interval",
+	"retry-limit":     "retry_limit",
+	"parallel":        "parallel",
+	"branch-per-todo": "branch_per_todo",
+	"on-exhausted":    "on_exhausted",
+	"max-cost":        "budget.max_cost",
+	"max-tokens":      "budget.max_tokens",
+	"max-time":        "budget.max_time",
+	"max-sessions":    "budget.max_sessions",
+}
+
+// register adds the loop flags to a command
+func (f *loopFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.coderSonnet, "coder-sonnet", false, "Use Sonnet model for coder/fixer phases")
+	cmd.Flags().BoolVar(&f.headless, "headless", false, "Run Claude non-interactively with a streamed log (for unattended runs)")
+	cmd.Flags().IntVar(&f.pruneInterval, "prune-interval", 0, "Number of TODOs between auto-pruning (-1 to disable)")
+	cmd.Flags().IntVar(&f.retryLimit, "retry-limit", 0, "Review attempts (test gate or critic) per TODO")
+	cmd.Flags().IntVar(&f.parallel, "parallel", 0, "Work on up to N independent TODOs at once, each in its own git worktree")
+	cmd.Flags().BoolVar(&f.branchPerTodo, "branch-per-todo", false, "Work on each TODO in its own branch and squash-merge it once approved")
+	cmd.Flags().StringVar(&f.onExhausted, "on-exhausted", "", "What to do with the code of a TODO that runs out of review attempts: keep, revert or stash")
+	cmd.Flags().Float64Var(&f.maxCost, "max-cost", 0, "Stop once Claude sessions have cost this many USD (0 for no limit)")
+	cmd.Flags().IntVar(&f.maxTokens, "max-tokens", 0, "Stop once Claude sessions have used this many tokens, including cache (0 for no limit)")
+	cmd.Flags().DurationVar(&f.maxTime, "max-time", 0, "Stop once the loop has run this long, e.g. 2h (0 for no limit)")
+	cmd.Flags().IntVar(&f.maxSessions, "max-sessions", 0, "Stop after this many Claude sessions (0 for no limit)")
+}
+
+// resolve applies the flags that were given on top of the loaded configuration
+func (f *loopFlags) resolve(cmd *cobra.Command) (*config.ProjectConfig, error) {
+	cfg := *currentConfig()
+	cfg.Phases = cfg.Phases.Clone()
+
+	for flag, key := range loopFlagKeys {
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+		value := cmd.Flags().Lookup(flag).Value.String()
+		if flag == "prune-interval" && value == "0" {
+			continue // 0 keeps the configured interval
+		}
+		if err := cfg.Set(key, value); err != nil {
+			return nil, fmt.Errorf("--%s: %w", flag, err)
+		}
+	}
+	if f.headless {
+		cfg.Runner.Backend = config.BackendHeadless
+	}
+	if f.coderSonnet {
+		cfg.Phases.SetModel("sonnet", state.PhaseCoder, state.PhaseFixer)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return &cfg, nil
+}
+
+// engineOptions builds the engine options for a resolved configuration
+func (f *loopFlags) engineOptions(cfg *config.ProjectConfig, autoclaudePath string) engine.Options {
+	return engine.Options{
+		AutoclaudePath: autoclaudePath,
+		Phases:         cfg.Phases,
+		PruneInterval:  cfg.PruneEvery(),
+		RetryLimit:     cfg.RetryLimit,
+		Budget:         cfg.Budget.StateBudget(),
+		LintCommands:   cfg.LintCommands,
+		TestTimeout:    cfg.CheckTimeout(),
+		Backend:        cfg.Runner.Backend,
+		Command:        cfg.Runner.Command,
+		Parallel:       cfg.Parallel,
+		BranchPerTodo:  cfg.BranchPerTodo,
+		OnExhausted:    cfg.OnExhausted,
+		CommitGuard:    cfg.CommitGuard.Policy(),
+		MaxRetries:     cfg.Runner.Retries(),
+		RetryBackoff:   cfg.Runner.Backoff(),
+	}
+}
+
+// printLoopConfig prints the settings in effect for the loop
+func printLoopConfig(cfg *config.ProjectConfig) {
+	if cfg.Runner.Backend != config.BackendInteractive {
+		fmt.Printf("  Mode: %s\n", cfg.Runner.Backend)
+	}
+	if cfg.Runner.Command != "" {
+		fmt.Printf("  Agent CLI: %s\n", cfg.Runner.Command)
+	}
+	fmt.Printf("  Retry limit: %d (then %s the attempt)\n", cfg.RetryLimit, cfg.OnExhausted)
+	if cfg.BranchPerTodo {
+		fmt.Println("  Branch per TODO: squash-merged on approval")
+	}
+	if cfg.Parallel > 1 {
+		fmt.Printf("  Parallel: up to %d TODOs (workers run headless)\n", cfg.Parallel)
+	}
+	if budget := cfg.Budget.StateBudget(); budget != nil {
+		fmt.Printf("  Budget: %s\n", budget)
+	}
+	if len(cfg.Protected) > 0 {
+		fmt.Printf("  Protected paths: %s\n", strings.Join(cfg.Protected, ", "))
+	}
+	if len(cfg.CommitGuard.AllowedPaths) > 0 {
+		fmt.Printf("  Commit allowed in: %s\n", strings.Join(cfg.CommitGuard.AllowedPaths, ", "))
+	}
+	if len(cfg.LintCommands) > 0 {
+		fmt.Printf("  Lint commands: %s\n", strings.Join(cfg.LintCommands, "; "))
+	}
+	fmt.Println("  Phases:")
+	idle := false
+	for _, phase := range state.Phases {
+		if phase == state.PhasePlanner {
+			continue
+		}
+		fmt.Printf("    %-10s %s\n", phase, cfg.Phases.For(phase))
+		idle = idle || cfg.Phases.For(phase).IdleTimeout != ""
+	}
+	// Claude Code waits at its prompt after an API error it gave up on, so a
+	// session attached to a terminal only ends, and is retried, if it idles out
+	if cfg.Runner.Backend != config.BackendHeadless && cfg.Runner.Retries() > 0 && !idle {
+		fmt.Println("  ⚠ Sessions that stop on an API error wait at Claude's prompt until you end them;")
+		fmt.Println("    set phases.<phase>.idle_timeout, or use --headless, to have them retried")
+	}
+}
+
+// handleLoopStop turns a budget stop or an escalation into a clean exit, and
+// an interruption or a failed login into a hint to resume
+func handleLoopStop(err error, stats *state.Stats) error {
+	switch {
+	case errors.Is(err, engine.ErrBudgetExceeded):
+		printStats(stats)
+		fmt.Println("Raise the budget with --max-cost, --max-tokens, --max-time or --max-sessions (or 'autoclaude config set budget.<limit>') and run 'autoclaude resume' to continue.")
+		return nil
+	case errors.Is(err, engine.ErrEscalated):
+		printStats(stats)
+		fmt.Printf("Some TODOs are blocked and need you. See %s, update their Status: lines in TODO.md, and run 'autoclaude resume' to continue.\n", state.EscalationPath())
+		return nil
+	case errors.Is(err, engine.ErrAuth):
+		printStats(stats)
+		fmt.Println("Claude could not authenticate. Run 'claude' and log in with /login (or set ANTHROPIC_API_KEY), then run 'autoclaude resume' to continue.")
+		return err
+	case errors.Is(err, context.Canceled):
+		printStats(stats)
+		return fmt.Errorf("interrupted; run 'autoclaude resume' to continue")
+	}
+	return err
+}
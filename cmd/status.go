@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"go.coldcutz.net/autoclaude/internal/state"
@@ -49,6 +50,24 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	fmt.Println("=== TODOs ===")
 	printTodoProgress()
 
+	// Print token usage and cost
+	if s.Stats != nil && s.Stats.Usage.Sessions > 0 {
+		fmt.Println()
+		fmt.Println("=== Usage ===")
+		printUsage(s.Stats, 5)
+	}
+
+	// Print recent events from the journal
+	events, err := state.LoadEvents()
+	if err != nil {
+		fmt.Printf("  ⚠ Failed to read %s: %v\n", state.EventsPath(), err)
+	}
+	if len(events) > 0 {
+		fmt.Println()
+		fmt.Println("=== Recent Events ===")
+		printRecentEvents(events, 10)
+	}
+
 	// Print recent notes
 	fmt.Println()
 	fmt.Println("=== Recent Notes ===")
@@ -58,26 +77,16 @@ func runStatus(cmd *cobra.Command, args []string) error {
 }
 
 func printTodoProgress() {
-	data, err := os.ReadFile(state.TodoPath())
+	list, err := state.LoadTodos()
 	if err != nil {
 		fmt.Println("  (no TODO.md found)")
 		return
 	}
 
-	content := string(data)
-	lines := strings.Split(content, "\n")
-
-	var completed, incomplete int
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "- [x]") || strings.HasPrefix(trimmed, "- [X]") {
-			completed++
-		} else if strings.HasPrefix(trimmed, "- [ ]") {
-			incomplete++
-		}
-	}
+	completed := len(list.Completed())
+	open := list.WithStatus(state.StatusOpen)
 
-	total := completed + incomplete
+	total := len(list.Todos)
 	if total == 0 {
 		fmt.Println("  No TODOs found")
 		return
@@ -87,20 +96,61 @@ func printTodoProgress() {
 	fmt.Println()
 
 	// Print incomplete TODOs
-	if incomplete > 0 {
+	if len(open) > 0 {
 		fmt.Println("  Remaining:")
-		for _, line := range lines {
-			trimmed := strings.TrimSpace(line)
-			if strings.HasPrefix(trimmed, "- [ ]") {
-				// Extract task name
-				taskName := strings.TrimPrefix(trimmed, "- [ ] ")
-				if idx := strings.Index(taskName, " - "); idx > 0 {
-					taskName = taskName[:idx]
-				}
-				fmt.Printf("    • %s\n", taskName)
+		for _, todo := range open {
+			if held := list.WaitingOn(todo); held != nil {
+				fmt.Printf("    • %s (waiting on %s)\n", todo.Title, held.Title)
+			} else {
+				fmt.Printf("    • %s\n", todo.Title)
 			}
 		}
 	}
+	printTodosWithStatus(list, state.StatusBlocked, "Blocked")
+	printTodosWithStatus(list, state.StatusDeferred, "Deferred")
+	printTodosWithStatus(list, state.StatusSkipped, "Skipped")
+
+	if _, err := os.Stat(state.EscalationPath()); err == nil {
+		fmt.Println()
+		fmt.Printf("  Needs attention: see %s\n", state.EscalationPath())
+	}
+}
+
+// printTodosWithStatus lists the TODOs with a status, with the reason they were blocked
+func printTodosWithStatus(list *state.TodoList, status state.TodoStatus, heading string) {
+	todos := list.WithStatus(status)
+	if len(todos) == 0 {
+		return
+	}
+	fmt.Printf("  %s:\n", heading)
+	for _, todo := range todos {
+		fmt.Printf("    • %s\n", todo.Title)
+		for _, sub := range todo.SubBullets {
+			if strings.HasPrefix(sub, "Blocked:") {
+				fmt.Printf("      %s\n", sub)
+			}
+		}
+	}
+}
+
+// printRecentEvents prints the last n journal events and the time spent in
+// each phase across the whole journal
+func printRecentEvents(events []state.Event, n int) {
+	for _, e := range events[max(0, len(events)-n):] {
+		fmt.Printf("  %s\n", e)
+	}
+
+	durations := state.PhaseDurations(events)
+	if len(durations) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("  Time by phase:")
+	for _, phase := range state.Phases {
+		if d, ok := durations[phase]; ok {
+			fmt.Printf("    %-10s %s\n", phase, d.Round(time.Second))
+		}
+	}
 }
 
 func printRecentNotes(n int) {
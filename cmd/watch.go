@@ -110,38 +110,19 @@ func displayWatch(_ context.Context) error {
 	}
 
 	// Load and parse TODOs
-	todoData, err := os.ReadFile(state.TodoPath())
+	list, err := state.LoadTodos()
 	if err != nil {
 		fmt.Println("\n  (no TODO.md found)")
 		return nil
 	}
 
-	lines := strings.Split(string(todoData), "\n")
+	completed := list.Completed()
+	open := list.WithStatus(state.StatusOpen)
+	blocked := list.WithStatus(state.StatusBlocked)
+	deferred := list.WithStatus(state.StatusDeferred)
+	skipped := list.WithStatus(state.StatusSkipped)
 
-	var completed []string
-	var incomplete []string
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "- [x]") || strings.HasPrefix(trimmed, "- [X]") {
-			taskName := strings.TrimSpace(strings.TrimPrefix(trimmed, "- [x]"))
-			taskName = strings.TrimSpace(strings.TrimPrefix(taskName, "- [X]"))
-			// Extract just the task name before any description
-			if idx := strings.Index(taskName, " - "); idx > 0 {
-				taskName = taskName[:idx]
-			}
-			completed = append(completed, taskName)
-		} else if strings.HasPrefix(trimmed, "- [ ]") {
-			taskName := strings.TrimSpace(strings.TrimPrefix(trimmed, "- [ ]"))
-			// Extract just the task name before any description
-			if idx := strings.Index(taskName, " - "); idx > 0 {
-				taskName = taskName[:idx]
-			}
-			incomplete = append(incomplete, taskName)
-		}
-	}
-
-	total := len(completed) + len(incomplete)
+	total := len(list.Todos)
 
 	// Progress bar
 	fmt.Println()
@@ -160,15 +141,39 @@ func displayWatch(_ context.Context) error {
 	if len(completed) > 0 {
 		fmt.Printf("\n\033[1;32m✓ Completed (%d):\033[0m\n", len(completed))
 		for _, todo := range completed {
-			fmt.Printf("  ✓ %s\n", todo)
+			fmt.Printf("  ✓ %s\n", todo.Title)
 		}
 	}
 
 	// Remaining TODOs
-	if len(incomplete) > 0 {
-		fmt.Printf("\n\033[1;31m✗ Remaining (%d):\033[0m\n", len(incomplete))
-		for _, todo := range incomplete {
-			fmt.Printf("  ✗ %s\n", todo)
+	if len(open) > 0 {
+		fmt.Printf("\n\033[1;31m✗ Remaining (%d):\033[0m\n", len(open))
+		for _, todo := range open {
+			if held := list.WaitingOn(todo); held != nil {
+				fmt.Printf("  ✗ %s \033[90m(waiting on %s)\033[0m\n", todo.Title, held.Title)
+			} else {
+				fmt.Printf("  ✗ %s\n", todo.Title)
+			}
+		}
+	}
+
+	// TODOs the loop won't select
+	if len(blocked) > 0 {
+		fmt.Printf("\n\033[1;33m⚠ Blocked (%d):\033[0m\n", len(blocked))
+		for _, todo := range blocked {
+			fmt.Printf("  ⚠ %s\n", todo.Title)
+		}
+	}
+	if len(deferred) > 0 {
+		fmt.Printf("\n\033[1;34m… Deferred (%d):\033[0m\n", len(deferred))
+		for _, todo := range deferred {
+			fmt.Printf("  … %s\n", todo.Title)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Printf("\n\033[90m- Skipped (%d):\033[0m\n", len(skipped))
+		for _, todo := range skipped {
+			fmt.Printf("  - %s\n", todo.Title)
 		}
 	}
 
@@ -196,8 +201,12 @@ func stepColor(step state.Step) string {
 	switch step {
 	case state.StepCoder:
 		return "\033[1;36m" // cyan
+	case state.StepTest:
+		return "\033[1;34m" // blue
 	case state.StepCritic:
 		return "\033[1;35m" // magenta
+	case state.StepFixer:
+		return "\033[1;31m" // red
 	case state.StepEvaluator:
 		return "\033[1;33m" // yellow
 	case state.StepDone:
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"go.coldcutz.net/autoclaude/internal/engine"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "_worker",
+	Short: "Internal: work on one TODO in a parallel worktree",
+	// Options come from the loop in worker.json, not from config.yaml
+	Hidden:      true,
+	Annotations: map[string]string{skipConfigAnnotation: "true"},
+	RunE:        runWorker,
+}
+
+func init() {
+	rootCmd.AddCommand(workerCmd)
+}
+
+func runWorker(cmd *cobra.Command, args []string) error {
+	s, err := state.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	opts, err := engine.LoadWorkerOptions()
+	if err != nil {
+		return err
+	}
+	ctx, stop := loopContext(cmd)
+	defer stop()
+	return engine.New(s, opts).Run(ctx)
+}
@@ -0,0 +1,125 @@
+package claude
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+// WorkActivity returns when a session working in the current directory last
+// did something visible: changed a file in the working tree or wrote to its
+// transcript. Sessions that report no events, like interactive ones, are
+// watched this way. Nothing older than since is returned.
+//
+// Only files git would track are looked at, and the directories holding them
+// so that deletions count, which keeps dependencies, build output, git's own
+// files and autoclaude's state out of it. Outside a git repository the whole
+// tree but .git and .autoclaude is walked.
+func WorkActivity(since time.Time) time.Time {
+	latest := since
+	touch := func(info fs.FileInfo) {
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	if paths, ok := workTreePaths(); ok {
+		for _, path := range paths {
+			if info, err := os.Lstat(path); err == nil {
+				touch(info)
+			}
+		}
+	} else {
+		walk(".", touch, skipWorkDirs)
+	}
+	if dir, err := TranscriptDir(); err == nil {
+		walk(dir, touch, nil)
+	}
+	return latest
+}
+
+// skipWorkDirs are left out of the working tree when git can't list it
+var skipWorkDirs = []string{".git", state.AutoclaudeDir}
+
+// walk calls touch for everything under root but the directories named skip
+func walk(root string, touch func(fs.FileInfo), skip []string) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && slices.Contains(skip, d.Name()) {
+			return filepath.SkipDir
+		}
+		if info, err := d.Info(); err == nil {
+			touch(info)
+		}
+		return nil
+	})
+}
+
+// workTreePaths lists the files git tracks or would track in the current
+// directory, and the directories holding them, leaving out autoclaude's
+// state. It reports false if git can't list them.
+func workTreePaths() ([]string, bool) {
+	out, err := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard").Output()
+	if err != nil {
+		return nil, false
+	}
+	paths := []string{"."}
+	dirs := map[string]bool{".": true}
+	for _, path := range strings.Split(string(out), "\x00") {
+		path = strings.TrimSuffix(path, "/") // Nested repositories, like worktrees
+		if path == "" || path == state.AutoclaudeDir || strings.HasPrefix(path, state.AutoclaudeDir+"/") {
+			continue
+		}
+		paths = append(paths, path)
+		for dir := filepath.Dir(path); !dirs[dir]; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+			paths = append(paths, dir)
+		}
+	}
+	return paths, true
+}
+
+// unsafePathChars are replaced with '-' in the names of transcript directories
+var unsafePathChars = regexp.MustCompile(`[^a-zA-Z0-9-]`)
+
+// TranscriptDir returns where Claude Code keeps the transcripts of sessions
+// run in the current directory
+func TranscriptDir() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	configDir := os.Getenv("CLAUDE_CONFIG_DIR")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configDir = filepath.Join(home, ".claude")
+	}
+	return filepath.Join(configDir, "projects", unsafePathChars.ReplaceAllString(cwd, "-")), nil
+}
+
+// activityReader records when data was last read, e.g. a stream event
+type activityReader struct {
+	r    io.Reader
+	last *atomic.Int64 // Unix nanoseconds
+}
+
+func (a activityReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.last.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
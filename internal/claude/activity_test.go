@@ -0,0 +1,55 @@
+package claude
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWorkActivity(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+	t.Setenv("CLAUDE_CONFIG_DIR", t.TempDir())
+
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+	os.WriteFile(".gitignore", []byte("node_modules/\n"), 0644)
+	for _, file := range []string{"src/main.go", "src/old.go", "node_modules/dep/index.js", ".autoclaude/state.json"} {
+		os.MkdirAll(filepath.Dir(file), 0755)
+		os.WriteFile(file, nil, 0644)
+	}
+	// Backdate everything, including the directories
+	old := time.Now().Add(-time.Hour)
+	filepath.WalkDir(".", func(path string, _ os.DirEntry, _ error) error {
+		return os.Chtimes(path, old, old)
+	})
+	since := time.Now().Add(-time.Minute)
+
+	if got := WorkActivity(since); !got.Equal(since) {
+		t.Errorf("expected no activity, got %v", got)
+	}
+
+	// Ignored files and autoclaude's state are not the session's work
+	now := time.Now()
+	os.Chtimes("node_modules/dep/index.js", now, now)
+	os.Chtimes(".autoclaude/state.json", now, now)
+	if got := WorkActivity(since); !got.Equal(since) {
+		t.Errorf("ignored files should not count as activity, got %v", got)
+	}
+
+	os.Remove("src/old.go")
+	if got := WorkActivity(since); !got.After(since) {
+		t.Error("deleting a file should count as activity")
+	}
+	os.Chtimes("src", old, old)
+
+	os.Chtimes("src/main.go", now, now)
+	if got := WorkActivity(since); !got.Equal(now) {
+		t.Errorf("expected activity at %v, got %v", now, got)
+	}
+}
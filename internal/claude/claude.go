@@ -5,6 +5,8 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
+	"time"
 )
 
 // BuildCommand builds the command string to run Claude with a prompt
@@ -27,9 +29,21 @@ func shellEscape(s string) string {
 	return fmt.Sprintf("'%s'", escaped)
 }
 
+// BinaryEnv names the environment variable that overrides which claude
+// executable is run, e.g. a fake one in end-to-end tests
+const BinaryEnv = "AUTOCLAUDE_CLAUDE"
+
+// Binary returns the claude executable to run
+func Binary() string {
+	if bin := os.Getenv(BinaryEnv); bin != "" {
+		return bin
+	}
+	return "claude"
+}
+
 // RunPrint runs Claude in print mode (non-interactive) and returns output
 func RunPrint(prompt string) (string, error) {
-	cmd := exec.Command("claude", "-p", prompt)
+	cmd := exec.Command(Binary(), "-p", prompt)
 	cmd.Stdin = os.Stdin
 	output, err := cmd.Output()
 	if err != nil {
@@ -41,11 +55,18 @@ func RunPrint(prompt string) (string, error) {
 	return string(output), nil
 }
 
-// CheckInstalled checks if the claude CLI is installed
-func CheckInstalled() error {
-	_, err := exec.LookPath("claude")
+// CheckInstalled checks if the agent CLI is installed, claude unless command
+// names another
+func CheckInstalled(command string) error {
+	if command != "" {
+		if _, err := exec.LookPath(command); err != nil {
+			return fmt.Errorf("agent CLI %q not found: %w", command, err)
+		}
+		return nil
+	}
+	_, err := exec.LookPath(Binary())
 	if err != nil {
-		return fmt.Errorf("claude CLI not found in PATH. Please install Claude Code first")
+		return fmt.Errorf("claude CLI not found in PATH. Please install Claude Code first, or point %s at it", BinaryEnv)
 	}
 	return nil
 }
@@ -53,69 +74,52 @@ func CheckInstalled() error {
 // PidFile is where we store the current Claude process PID
 const PidFile = ".autoclaude/claude.pid"
 
-// buildInteractiveArgs builds the argument list for running Claude interactively
-func buildInteractiveArgs(prompt string, permissionMode string, model string) []string {
-	args := []string{}
-	if permissionMode != "" {
-		args = append(args, "--permission-mode", permissionMode)
-	}
-	if model != "" {
-		args = append(args, "--model", model)
-	}
-	args = append(args, "--", prompt)
-	return args
-}
-
-// RunInteractive runs Claude interactively with the given prompt and permission mode
-// permissionMode can be "acceptEdits", "plan", or empty for default
-// model can be "sonnet", "opus", or empty for default
-func RunInteractive(prompt string, permissionMode string, model string) error {
-	args := buildInteractiveArgs(prompt, permissionMode, model)
-	cmd := exec.Command("claude", args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Start(); err != nil {
-		return err
-	}
+// SessionOptions configures a Claude session
+type SessionOptions struct {
+	PermissionMode string   // "acceptEdits", "plan", etc., or empty for default
+	Model          string   // "sonnet", "opus", a full model name, or empty for default
+	MaxTurns       int      // 0 for no limit
+	ExtraArgs      []string // Passed to claude before the prompt
 
-	// Save PID so hooks can kill the process if needed
-	os.WriteFile(PidFile, []byte(fmt.Sprintf("%d", cmd.Process.Pid)), 0644)
-	defer os.Remove(PidFile)
-
-	return cmd.Wait()
+	Timeout     time.Duration // Stop the session once it has run this long, 0 for no limit
+	IdleTimeout time.Duration // Stop the session once it has shown no activity for this long, 0 for no limit
 }
 
-// KillClaude kills the currently running Claude process using the saved PID
-func KillClaude() error {
-	data, err := os.ReadFile(PidFile)
-	if err != nil {
-		return fmt.Errorf("no Claude process found: %w", err)
+// Args returns the CLI flags for the options
+func (o SessionOptions) Args() []string {
+	args := []string{}
+	if o.PermissionMode != "" {
+		args = append(args, "--permission-mode", o.PermissionMode)
 	}
-
-	var pid int
-	if _, err := fmt.Sscanf(string(data), "%d", &pid); err != nil {
-		return fmt.Errorf("invalid PID: %w", err)
+	if o.Model != "" {
+		args = append(args, "--model", o.Model)
 	}
-
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return fmt.Errorf("process not found: %w", err)
+	if o.MaxTurns > 0 {
+		args = append(args, "--max-turns", fmt.Sprintf("%d", o.MaxTurns))
 	}
+	return append(args, o.ExtraArgs...)
+}
 
-	// Send SIGTERM for graceful shutdown
-	return process.Signal(os.Interrupt)
+// buildInteractiveArgs builds the argument list for running Claude interactively
+func buildInteractiveArgs(prompt string, opts SessionOptions) []string {
+	args := opts.Args()
+	args = append(args, "--", prompt)
+	return args
 }
 
-// RunInteractiveWithPromptFile runs Claude interactively reading prompt from a file
-// model can be "sonnet", "opus", or empty for default
-func RunInteractiveWithPromptFile(promptFile string, permissionMode string, model string) error {
-	promptData, err := os.ReadFile(promptFile)
+// KillClaude asks the running Claude session to exit. It is called from the
+// stop hooks, which run in Claude's process group and are waited for, so it
+// only sends SIGINT to Claude itself; the loop forces the session to end if
+// that isn't enough and stops whatever is left in the group.
+func KillClaude() error {
+	rec, err := ReadPidFile()
 	if err != nil {
-		return fmt.Errorf("failed to read prompt file: %w", err)
+		return err
+	}
+	if !rec.Running() {
+		return fmt.Errorf("claude process %d is no longer running", rec.PID)
 	}
-	return RunInteractive(string(promptData), permissionMode, model)
+	return syscall.Kill(rec.PID, syscall.SIGINT)
 }
 
 // ParseCriticOutput parses critic output to determine if approved
@@ -7,52 +7,52 @@ import (
 
 func TestBuildInteractiveArgs(t *testing.T) {
 	tests := []struct {
-		name           string
-		prompt         string
-		permissionMode string
-		model          string
-		want           []string
+		name   string
+		prompt string
+		opts   SessionOptions
+		want   []string
 	}{
 		{
-			name:           "no options",
-			prompt:         "test prompt",
-			permissionMode: "",
-			model:          "",
-			want:           []string{"--", "test prompt"},
+			name:   "no options",
+			prompt: "test prompt",
+			opts:   SessionOptions{},
+			want:   []string{"--", "test prompt"},
+		},
+		{
+			name:   "with permission mode only",
+			prompt: "test prompt",
+			opts:   SessionOptions{PermissionMode: "acceptEdits"},
+			want:   []string{"--permission-mode", "acceptEdits", "--", "test prompt"},
 		},
 		{
-			name:           "with permission mode only",
-			prompt:         "test prompt",
-			permissionMode: "acceptEdits",
-			model:          "",
-			want:           []string{"--permission-mode", "acceptEdits", "--", "test prompt"},
+			name:   "with model only",
+			prompt: "test prompt",
+			opts:   SessionOptions{Model: "sonnet"},
+			want:   []string{"--model", "sonnet", "--", "test prompt"},
 		},
 		{
-			name:           "with model only",
-			prompt:         "test prompt",
-			permissionMode: "",
-			model:          "sonnet",
-			want:           []string{"--model", "sonnet", "--", "test prompt"},
+			name:   "with both permission mode and model",
+			prompt: "test prompt",
+			opts:   SessionOptions{PermissionMode: "acceptEdits", Model: "sonnet"},
+			want:   []string{"--permission-mode", "acceptEdits", "--model", "sonnet", "--", "test prompt"},
 		},
 		{
-			name:           "with both permission mode and model",
-			prompt:         "test prompt",
-			permissionMode: "acceptEdits",
-			model:          "sonnet",
-			want:           []string{"--permission-mode", "acceptEdits", "--model", "sonnet", "--", "test prompt"},
+			name:   "with opus model",
+			prompt: "do something",
+			opts:   SessionOptions{PermissionMode: "plan", Model: "opus"},
+			want:   []string{"--permission-mode", "plan", "--model", "opus", "--", "do something"},
 		},
 		{
-			name:           "with opus model",
-			prompt:         "do something",
-			permissionMode: "plan",
-			model:          "opus",
-			want:           []string{"--permission-mode", "plan", "--model", "opus", "--", "do something"},
+			name:   "with max turns and extra args",
+			prompt: "test prompt",
+			opts:   SessionOptions{Model: "opus", MaxTurns: 40, ExtraArgs: []string{"--add-dir", "../shared"}},
+			want:   []string{"--model", "opus", "--max-turns", "40", "--add-dir", "../shared", "--", "test prompt"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := buildInteractiveArgs(tt.prompt, tt.permissionMode, tt.model)
+			got := buildInteractiveArgs(tt.prompt, tt.opts)
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("buildInteractiveArgs() = %v, want %v", got, tt.want)
 			}
@@ -149,3 +149,14 @@ func TestParseEvaluatorOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestBinary(t *testing.T) {
+	t.Setenv(BinaryEnv, "")
+	if got := Binary(); got != "claude" {
+		t.Errorf("Binary() = %q, want claude", got)
+	}
+	t.Setenv(BinaryEnv, "/opt/fake/claude")
+	if got := Binary(); got != "/opt/fake/claude" {
+		t.Errorf("Binary() = %q, want the override", got)
+	}
+}
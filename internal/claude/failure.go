@@ -0,0 +1,71 @@
+package claude
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Failure is what kind of trouble made a session fail
+type Failure string
+
+const (
+	FailureNone      Failure = ""          // The session succeeded
+	FailureTransient Failure = "transient" // The API was overloaded, rate limited or unreachable; worth retrying
+	FailureAuth      Failure = "auth"      // Claude is not logged in or its credentials were rejected
+	FailureFatal     Failure = "fatal"     // Anything else; retrying the same session won't help
+)
+
+// Patterns of the errors Claude reports, matched against the lower-cased
+// error message of a session rather than everything it printed, so that
+// test output or file names can't be mistaken for them
+var (
+	authFailure = regexp.MustCompile(`^api error: 401\b|authentication_error|invalid api key|invalid x-api-key|` +
+		`oauth token has expired|please run /login|not logged in`)
+	transientFailure = regexp.MustCompile(`^api error: (429|500|502|503|504|529)\b|overloaded_error|rate_limit_error|` +
+		`"type":"api_error"|^api error \((connection error|request timed out)|` +
+		`\b(econnreset|econnrefused|etimedout|enotfound|eai_again)\b|socket hang up`)
+)
+
+// Classify tells what kind of failure a session's error is, from the error
+// message the session reported. Sessions the loop stopped itself, because it
+// was cancelled or the session timed out, and sessions that reported no
+// error message are fatal. A session that timed out waiting after an API
+// error that ended its last reply, as interactive ones do, is classified by
+// that error.
+func Classify(result *SessionResult, err error) Failure {
+	switch {
+	case err == nil:
+		return FailureNone
+	case errors.Is(err, context.Canceled):
+		return FailureFatal
+	case errors.Is(err, ErrTimeout) && (result == nil || !result.IsError):
+		return FailureFatal
+	}
+	msg := strings.ToLower(strings.TrimSpace(result.ErrorMessage()))
+	switch {
+	case msg == "":
+		return FailureFatal
+	case authFailure.MatchString(msg):
+		return FailureAuth
+	case transientFailure.MatchString(msg):
+		return FailureTransient
+	}
+	return FailureFatal
+}
+
+// lastAPIError returns the last line of the CLI's stderr that reports an
+// error, "" if there is none
+func lastAPIError(stderr string) string {
+	var last string
+	scanner := bufio.NewScanner(strings.NewReader(stderr))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "API Error") || strings.HasPrefix(line, "Error:") {
+			last = line
+		}
+	}
+	return last
+}
@@ -0,0 +1,54 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	failed := errors.New("claude exited with error: exit status 1")
+	tests := []struct {
+		name   string
+		result *SessionResult
+		err    error
+		want   Failure
+	}{
+		{"success", &SessionResult{}, nil, FailureNone},
+		{"overloaded", &SessionResult{APIError: `API Error: 529 {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`}, failed, FailureTransient},
+		{"rate limited", &SessionResult{IsError: true, Result: "API Error: 429 {\"type\":\"error\",\"error\":{\"type\":\"rate_limit_error\"}}"}, failed, FailureTransient},
+		{"server error", &SessionResult{APIError: "API Error: 500 Internal server error"}, failed, FailureTransient},
+		{"connection", &SessionResult{APIError: "API Error (Connection error.) · Retrying in 1 seconds… (attempt 1/10)"}, failed, FailureTransient},
+		{"invalid key", &SessionResult{IsError: true, Result: "Invalid API key · Please run /login"}, failed, FailureAuth},
+		{"unauthorized", &SessionResult{APIError: `API Error: 401 {"type":"error","error":{"type":"authentication_error"}}`}, failed, FailureAuth},
+		{"expired token", &SessionResult{APIError: "Error: OAuth token has expired"}, failed, FailureAuth},
+		{"max turns", &SessionResult{IsError: true, Result: "Max turns reached"}, failed, FailureFatal},
+		{"no message", nil, failed, FailureFatal},
+		// Numbers and words in the output of a failed session aren't API errors
+		{"status in result", &SessionResult{IsError: true, Result: "Tests failed: 500 of 502 passed, see /login/handler_test.go"}, failed, FailureFatal},
+		{"unauthorized in result", &SessionResult{IsError: true, Result: "TestUnauthorized failed with 401 instead of 403"}, failed, FailureFatal},
+		{"status in other error", &SessionResult{APIError: "Error: file has 429 lines, too long to read"}, failed, FailureFatal},
+		// Sessions the loop stopped itself are never retried here
+		{"cancelled", &SessionResult{APIError: "API Error: 529 Overloaded"}, fmt.Errorf("claude session stopped: %w", context.Canceled), FailureFatal},
+		{"timed out", &SessionResult{APIError: "API Error: 529 Overloaded"}, fmt.Errorf("claude session stopped: %w: no activity for 10m0s", ErrTimeout), FailureFatal},
+		// unless it was stuck on an API error its last reply ended with
+		{"stuck on error", &SessionResult{IsError: true, Result: "API Error: 529 Overloaded"}, fmt.Errorf("claude session stopped: %w: no activity for 10m0s", ErrTimeout), FailureTransient},
+		{"stuck on other error", &SessionResult{IsError: true, Result: "Max turns reached"}, fmt.Errorf("claude session stopped: %w: no activity for 10m0s", ErrTimeout), FailureFatal},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.result, tt.err); got != tt.want {
+			t.Errorf("%s: Classify = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLastAPIError(t *testing.T) {
+	stderr := "running tests\nFAIL: expected 500, got 401 (unauthorized)\nAPI Error: 529 Overloaded\nnot an error\n"
+	if got := lastAPIError(stderr); got != "API Error: 529 Overloaded" {
+		t.Errorf("lastAPIError = %q", got)
+	}
+	if got := lastAPIError("FAIL: expected 500, got 401 (unauthorized)\n"); got != "" {
+		t.Errorf("expected no API error in test output, got %q", got)
+	}
+}
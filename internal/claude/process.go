@@ -0,0 +1,315 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// How long a session gets to exit after SIGINT, and then after SIGTERM,
+// before it is killed
+var (
+	InterruptGrace = 10 * time.Second
+	TerminateGrace = 5 * time.Second
+)
+
+// PidRecord identifies the running Claude process. The start time guards
+// against signalling an unrelated process that was given the same PID after
+// Claude exited.
+type PidRecord struct {
+	PID   int    `json:"pid"`
+	Start string `json:"start"` // As reported by the OS; only compared for equality
+}
+
+// WritePidFile records a running process in PidFile, so the stop hooks can end it
+func WritePidFile(pid int) error {
+	start, err := processStart(pid)
+	if err != nil {
+		return fmt.Errorf("failed to read start time of process %d: %w", pid, err)
+	}
+	data, err := json.Marshal(PidRecord{PID: pid, Start: start})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(PidFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", PidFile, err)
+	}
+	return nil
+}
+
+// ReadPidFile reads the process recorded in PidFile
+func ReadPidFile() (PidRecord, error) {
+	var rec PidRecord
+	data, err := os.ReadFile(PidFile)
+	if err != nil {
+		return rec, fmt.Errorf("no Claude process found: %w", err)
+	}
+	if err := json.Unmarshal(data, &rec); err != nil || rec.PID <= 0 {
+		return rec, fmt.Errorf("invalid %s: %q", PidFile, strings.TrimSpace(string(data)))
+	}
+	return rec, nil
+}
+
+// Running reports whether the recorded process is still the one running
+func (r PidRecord) Running() bool {
+	start, err := processStart(r.PID)
+	return err == nil && r.Start != "" && start == r.Start
+}
+
+// Stop ends the recorded process and the processes it started, escalating
+// from SIGINT to SIGTERM to SIGKILL. It is for processes that aren't children
+// of this one, which are polled until they have gone.
+func (r PidRecord) Stop() {
+	exited := make(chan struct{})
+	go func() {
+		for r.Running() {
+			time.Sleep(50 * time.Millisecond)
+		}
+		close(exited)
+	}()
+	stopProcess(r.PID, exited)
+	killGroup(r.PID)
+}
+
+// StopOrphan stops a session left running by a loop that exited without
+// ending it, e.g. because it crashed, and removes the stale PidFile
+func StopOrphan() {
+	rec, err := ReadPidFile()
+	if err != nil {
+		os.Remove(PidFile)
+		return
+	}
+	if rec.Running() {
+		fmt.Printf("  ⚠ Stopping claude (PID %d) left running by an earlier session\n", rec.PID)
+		rec.Stop()
+	}
+	os.Remove(PidFile)
+}
+
+// processStart returns when a process started, in a form that is only
+// meaningful to compare. Zombies count as gone.
+func processStart(pid int) (string, error) {
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid)); err == nil {
+		// The command name in parentheses may contain spaces; the state is the
+		// first field after it and the start time the 20th
+		i := strings.LastIndexByte(string(data), ')')
+		fields := strings.Fields(string(data[i+1:]))
+		if i < 0 || len(fields) < 20 {
+			return "", fmt.Errorf("unexpected /proc/%d/stat format", pid)
+		}
+		if fields[0] == "Z" {
+			return "", fmt.Errorf("process %d has exited", pid)
+		}
+		return fields[19], nil
+	} else if !errors.Is(err, os.ErrNotExist) || dirExists("/proc/self") {
+		return "", err
+	}
+
+	// No procfs, e.g. on macOS
+	out, err := exec.Command("ps", "-o", "stat=,lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", fmt.Errorf("process %d not found", pid)
+	}
+	stat, start, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+	if strings.HasPrefix(stat, "Z") {
+		return "", fmt.Errorf("process %d has exited", pid)
+	}
+	return strings.TrimSpace(start), nil
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// signalProcess signals a process, and every process in its group if it
+// leads one. Sessions run in a group of their own, so this reaches the
+// commands Claude started too.
+func signalProcess(pid int, sig syscall.Signal) error {
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		return syscall.Kill(-pid, sig)
+	}
+	return syscall.Kill(pid, sig)
+}
+
+// stopProcess asks a process to exit with SIGINT, which Claude takes as a
+// request to exit cleanly, then sends SIGTERM and finally SIGKILL if it hasn't
+// exited within the grace periods
+func stopProcess(pid int, exited <-chan struct{}) {
+	for _, step := range []struct {
+		sig   syscall.Signal
+		grace time.Duration
+	}{{syscall.SIGINT, InterruptGrace}, {syscall.SIGTERM, TerminateGrace}} {
+		select {
+		case <-exited:
+			return
+		default:
+		}
+		if signalProcess(pid, step.sig) != nil {
+			return
+		}
+		select {
+		case <-exited:
+			return
+		case <-time.After(step.grace):
+		}
+	}
+	signalProcess(pid, syscall.SIGKILL)
+}
+
+// killGroup ends what is left of a process group once its leader has exited,
+// such as test binaries or servers Claude started and didn't stop. They get
+// SIGTERM, then SIGKILL if they are still there after TerminateGrace.
+func killGroup(pgid int) {
+	if syscall.Kill(-pgid, 0) != nil {
+		return
+	}
+	syscall.Kill(-pgid, syscall.SIGTERM)
+	deadline := time.Now().Add(TerminateGrace)
+	for syscall.Kill(-pgid, 0) == nil {
+		if time.Now().After(deadline) {
+			syscall.Kill(-pgid, syscall.SIGKILL)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// process is a session running as a child process in its own process group
+type process struct {
+	cmd      *exec.Cmd
+	exited   chan struct{} // Closed once the CLI has exited
+	err      error         // From cmd.Wait, set before exited is closed
+	terminal int           // Process group to give the terminal back to, 0 if not taken
+	stopping sync.Once
+	leaving  sync.Once
+}
+
+// startProcess starts cmd in a new process group, stopping it if ctx is done
+// first. With attach, the group is given the terminal, so an interactive
+// session can read from it. We join the group while it has the terminal, so
+// that Ctrl-C reaches the loop as well as the CLI and stops the loop as it
+// would without a group of its own. A session leader can't change groups, so
+// it shares its own group with the CLI instead.
+func startProcess(ctx context.Context, cmd *exec.Cmd, attach bool) (*process, error) {
+	p := &process{cmd: cmd, exited: make(chan struct{})}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if attach {
+		if pgrp, ok := foregroundGroup(); ok {
+			if sessionLeader() {
+				cmd.SysProcAttr.Setpgid = false
+			} else {
+				// Handing the terminal over and taking it back from a background
+				// group raises SIGTTOU, which would stop us
+				signal.Ignore(syscall.SIGTTOU)
+				cmd.SysProcAttr.Foreground = true
+				cmd.SysProcAttr.Ctty = int(os.Stdin.Fd())
+				p.terminal = pgrp
+			}
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		p.err = cmd.Wait()
+		close(p.exited)
+	}()
+	if p.terminal != 0 {
+		if err := syscall.Setpgid(0, cmd.Process.Pid); err != nil {
+			p.Cancel()
+			return nil, fmt.Errorf("failed to join the session's process group: %w", err)
+		}
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.Cancel()
+		case <-p.exited:
+		}
+	}()
+	return p, nil
+}
+
+// wait waits for the CLI to exit, takes the terminal back and ends whatever
+// the CLI left running in its group
+func (p *process) wait() error {
+	<-p.exited
+	p.leave()
+	killGroup(p.cmd.Process.Pid)
+	return p.err
+}
+
+// leave moves us back to our own process group and takes the terminal back,
+// so that signals meant for the session's group don't reach us
+func (p *process) leave() {
+	p.leaving.Do(func() {
+		if p.terminal == 0 {
+			return
+		}
+		pgrp := p.terminal
+		if syscall.Getpgrp() == p.cmd.Process.Pid {
+			pgrp = rejoinGroup(p.terminal)
+		}
+		setForegroundGroup(pgrp)
+	})
+}
+
+// Cancel stops the CLI and the commands it started, escalating from SIGINT
+// to SIGKILL, and returns once it has exited
+func (p *process) Cancel() error {
+	p.leave()
+	p.stopping.Do(func() { stopProcess(p.cmd.Process.Pid, p.exited) })
+	<-p.exited
+	return nil
+}
+
+// rejoinGroup moves us back into the process group we were started in and
+// returns the group we ended up in. If we led the group and were its last
+// member it no longer exists, and we lead a new one.
+func rejoinGroup(pgrp int) int {
+	if syscall.Setpgid(0, pgrp) != nil {
+		syscall.Setpgid(0, 0)
+	}
+	return syscall.Getpgrp()
+}
+
+// sessionLeader reports whether we lead our session
+func sessionLeader() bool {
+	sid, _, errno := syscall.RawSyscall(syscall.SYS_GETSID, 0, 0, 0)
+	return errno == 0 && int(sid) == os.Getpid()
+}
+
+// foregroundGroup returns our process group if it owns the terminal on stdin
+func foregroundGroup() (int, bool) {
+	var pgrp int32
+	if err := ioctl(os.Stdin.Fd(), syscall.TIOCGPGRP, unsafe.Pointer(&pgrp)); err != nil {
+		return 0, false
+	}
+	own := syscall.Getpgrp()
+	return own, int(pgrp) == own
+}
+
+// setForegroundGroup gives the terminal on stdin to a process group
+func setForegroundGroup(pgrp int) error {
+	id := int32(pgrp)
+	return ioctl(os.Stdin.Fd(), syscall.TIOCSPGRP, unsafe.Pointer(&id))
+}
+
+func ioctl(fd uintptr, req uint, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(req), uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupProcessTest runs the test in a temp dir with a .autoclaude dir for
+// the PID file, and writes a stand-in CLI running the given script
+func setupProcessTest(t *testing.T, script string) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	t.Cleanup(func() { os.Chdir(oldDir) })
+	os.MkdirAll(".autoclaude", 0755)
+
+	path := tmpDir + "/agent"
+	os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755)
+	return path
+}
+
+func TestPidRecord(t *testing.T) {
+	setupProcessTest(t, "")
+
+	if err := WritePidFile(os.Getpid()); err != nil {
+		t.Fatalf("WritePidFile failed: %v", err)
+	}
+	rec, err := ReadPidFile()
+	if err != nil || rec.PID != os.Getpid() || !rec.Running() {
+		t.Fatalf("expected a running record of this process, got %+v (%v)", rec, err)
+	}
+
+	// A PID that was reused by another process has a different start time
+	rec.Start += "0"
+	if rec.Running() {
+		t.Error("expected a record with another start time not to be running")
+	}
+	os.WriteFile(PidFile, []byte(`{"pid":`+strconv.Itoa(os.Getpid())+`,"start":"0"}`), 0644)
+	if err := KillClaude(); err == nil || !strings.Contains(err.Error(), "no longer running") {
+		t.Errorf("expected KillClaude to refuse a reused PID, got %v", err)
+	}
+}
+
+func TestInteractiveStopsLeftoverProcesses(t *testing.T) {
+	// The CLI starts a command in the background and exits without stopping it
+	agent := setupProcessTest(t, "sleep 30 &\necho $! > child.pid\ncat .autoclaude/claude.pid > recorded.pid\n")
+
+	if _, err := Run(t.Context(), Interactive{Command: agent}, "prompt", SessionOptions{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if data, _ := os.ReadFile("recorded.pid"); !strings.Contains(string(data), `"start"`) {
+		t.Errorf("expected the session to be recorded with its start time, got %q", data)
+	}
+	if _, err := os.Stat(PidFile); !os.IsNotExist(err) {
+		t.Error("expected the PID file to be removed once the session ended")
+	}
+	data, _ := os.ReadFile("child.pid")
+	child, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	if _, err := processStart(child); err == nil {
+		t.Errorf("expected the leftover process %d to be stopped", child)
+	}
+}
+
+// ptyHelperEnv makes the test binary run an interactive session instead of
+// the test, passing it the agent CLI to run
+const ptyHelperEnv = "AUTOCLAUDE_TEST_PTY_AGENT"
+
+func TestInteractiveInterruptReachesLoop(t *testing.T) {
+	if agent := os.Getenv(ptyHelperEnv); agent != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		_, err := Run(ctx, Interactive{Command: agent}, "prompt", SessionOptions{})
+		fmt.Printf("result: %v\n", err)
+		return
+	}
+	if exec.Command("script", "-qec", "true", "/dev/null").Run() != nil {
+		t.Skip("needs script from util-linux to run a session in a terminal")
+	}
+
+	// Ctrl-C makes the terminal interrupt its foreground group, which is the
+	// session's once the loop joined it. The CLI handles the interrupt, winds
+	// down and exits cleanly.
+	agent := setupProcessTest(t, "trap 'sleep 1; exit 0' INT\n"+
+		"until [ $(ps -o pgid= -p $PPID) = $(ps -o pgid= -p $$) ]; do sleep 0.01; done\n"+
+		"kill -INT 0\nsleep 5\n")
+	helper := shellEscape(os.Args[0]) + " -test.run='^TestInteractiveInterruptReachesLoop$'"
+	for name, command := range map[string]string{
+		"own group":      helper + "; true",
+		"session leader": "exec " + helper,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cmd := exec.Command("script", "-qec", command, "/dev/null")
+			cmd.Env = append(os.Environ(), ptyHelperEnv+"="+agent)
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("session in a terminal failed: %v\n%s", err, out)
+			}
+			if !strings.Contains(string(out), "result: claude session stopped: interrupt signal received") {
+				t.Errorf("expected the interrupt to cancel the loop's context, got:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCancelEscalates(t *testing.T) {
+	oldInterrupt, oldTerminate := InterruptGrace, TerminateGrace
+	InterruptGrace, TerminateGrace = 100*time.Millisecond, 100*time.Millisecond
+	defer func() { InterruptGrace, TerminateGrace = oldInterrupt, oldTerminate }()
+
+	// The CLI ignores SIGINT and SIGTERM, so only SIGKILL ends it
+	agent := setupProcessTest(t, "trap '' INT TERM\ntouch started\nwhile :; do sleep 0.05; done\n")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	go func() {
+		for {
+			if _, err := os.Stat("started"); err == nil {
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	_, err := Run(ctx, Interactive{Command: agent}, "prompt", SessionOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the session to be stopped by the cancelled context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("expected the session to be killed after both grace periods, took %v", elapsed)
+	}
+}
+
+func TestRunTimeouts(t *testing.T) {
+	const event = `{"type":"system","subtype":"init","session_id":"s"}`
+	tests := []struct {
+		name    string
+		runner  func(agent string) Runner
+		script  string
+		opts    SessionOptions
+		wantErr string
+	}{
+		{"wall clock", func(a string) Runner { return Interactive{Command: a} }, "sleep 30\n",
+			SessionOptions{Timeout: 200 * time.Millisecond}, "session timed out after 200ms"},
+		{"no events", func(a string) Runner { return Headless{Command: a, Log: io.Discard} }, "echo '" + event + "'\nsleep 30\n",
+			SessionOptions{IdleTimeout: 300 * time.Millisecond}, "session timed out: no activity for 300ms"},
+		{"no file changes", func(a string) Runner { return Interactive{Command: a} }, "touch work.go\nsleep 30\n",
+			SessionOptions{IdleTimeout: 300 * time.Millisecond}, "no activity for 300ms"},
+		// A session that keeps printing events is not idle
+		{"steady events", func(a string) Runner { return Headless{Command: a, Log: io.Discard} },
+			"for i in 1 2 3 4 5 6; do echo '" + event + "'; sleep 0.1; done\necho '{\"type\":\"result\",\"subtype\":\"success\"}'\n",
+			SessionOptions{IdleTimeout: 300 * time.Millisecond}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CLAUDE_CONFIG_DIR", t.TempDir())
+			agent := setupProcessTest(t, tt.script)
+
+			start := time.Now()
+			_, err := Run(t.Context(), tt.runner(agent), "prompt", tt.opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Run failed: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrTimeout) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if time.Since(start) > 10*time.Second {
+				t.Errorf("expected the session to be stopped promptly, took %v", time.Since(start))
+			}
+		})
+	}
+}
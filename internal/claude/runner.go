@@ -0,0 +1,256 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Runner starts Claude sessions. The loop depends only on this interface, so
+// sessions can run attached to the terminal, headless, in tmux, or against a
+// fake, and any agent CLI that takes claude's flags, hooks and stream-json
+// output can stand in for claude.
+type Runner interface {
+	// Start starts a session with the given prompt. The session is stopped
+	// if ctx is done before it ends.
+	Start(ctx context.Context, prompt string, opts SessionOptions) (Session, error)
+}
+
+// Session is a running Claude session
+type Session interface {
+	// Wait blocks until the session ends and returns what it reported.
+	// Backends that get no structured result from the CLI return an empty one.
+	Wait() (*SessionResult, error)
+	// Cancel stops the session, forcibly if it doesn't exit in time
+	Cancel() error
+}
+
+// ErrTimeout is wrapped by the error of a session stopped for running longer
+// than its timeout or for showing no activity for longer than its idle timeout
+var ErrTimeout = errors.New("session timed out")
+
+// ActivityReporter is implemented by sessions that can tell when they last
+// showed signs of progress, so that one that has stalled can be stopped
+type ActivityReporter interface {
+	LastActivity() time.Time
+}
+
+// Run starts a session and waits for it to end, stopping it if it runs into
+// one of the timeouts in opts. A session stopped because ctx is done or it
+// timed out returns an error wrapping the cause.
+func Run(ctx context.Context, r Runner, prompt string, opts SessionOptions) (*SessionResult, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if opts.Timeout > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeoutCause(ctx, opts.Timeout, fmt.Errorf("%w after %s", ErrTimeout, opts.Timeout))
+		defer stop()
+	}
+
+	session, err := r.Start(ctx, prompt, opts)
+	if err != nil {
+		return nil, err
+	}
+	if activity, ok := session.(ActivityReporter); ok && opts.IdleTimeout > 0 {
+		go watchIdle(ctx, activity, opts.IdleTimeout, cancel)
+	}
+	result, err := session.Wait()
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("claude session stopped: %w", context.Cause(ctx))
+	}
+	return result, err
+}
+
+// watchIdle cancels the session's context once it has shown no activity for
+// the idle timeout
+func watchIdle(ctx context.Context, session ActivityReporter, idle time.Duration, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(min(max(idle/10, 10*time.Millisecond), 30*time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(session.LastActivity()) >= idle {
+				cancel(fmt.Errorf("%w: no activity for %s", ErrTimeout, idle))
+				return
+			}
+		}
+	}
+}
+
+// RunWithPromptFile runs a session with the prompt read from a file
+func RunWithPromptFile(ctx context.Context, r Runner, promptFile string, opts SessionOptions) (*SessionResult, error) {
+	promptData, err := os.ReadFile(promptFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+	}
+	return Run(ctx, r, string(promptData), opts)
+}
+
+// RunnerFunc runs sessions by calling a function, e.g. a fake in tests. Its
+// sessions have ended by the time Start returns, so they can't be cancelled.
+type RunnerFunc func(ctx context.Context, prompt string, opts SessionOptions) (*SessionResult, error)
+
+// Start runs the function
+func (f RunnerFunc) Start(ctx context.Context, prompt string, opts SessionOptions) (Session, error) {
+	result, err := f(ctx, prompt, opts)
+	return doneSession{result, err}, nil
+}
+
+type doneSession struct {
+	result *SessionResult
+	err    error
+}
+
+func (s doneSession) Wait() (*SessionResult, error) { return s.result, s.err }
+func (s doneSession) Cancel() error                 { return nil }
+
+// commandOrDefault returns the agent CLI to run
+func commandOrDefault(command string) string {
+	if command != "" {
+		return command
+	}
+	return Binary()
+}
+
+// Interactive runs sessions attached to the terminal. The running session is
+// recorded in PidFile, so the stop hooks can end it.
+type Interactive struct {
+	Command string // Agent CLI to run instead of claude
+}
+
+// Start starts the CLI in the foreground
+func (r Interactive) Start(ctx context.Context, prompt string, opts SessionOptions) (Session, error) {
+	cmd := exec.Command(commandOrDefault(r.Command), buildInteractiveArgs(prompt, opts)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	p, err := startProcess(ctx, cmd, true)
+	if err != nil {
+		return nil, err
+	}
+	// Without the PID file the stop hooks can't return control to the loop
+	if err := WritePidFile(cmd.Process.Pid); err != nil {
+		p.Cancel()
+		return nil, err
+	}
+	return &interactiveSession{process: p, started: time.Now()}, nil
+}
+
+type interactiveSession struct {
+	*process
+	started time.Time
+}
+
+// LastActivity returns when a file in the working tree or the transcript last changed
+func (s *interactiveSession) LastActivity() time.Time {
+	return WorkActivity(s.started)
+}
+
+func (s *interactiveSession) Wait() (*SessionResult, error) {
+	defer os.Remove(PidFile)
+	return &SessionResult{}, s.wait()
+}
+
+// Headless runs sessions non-interactively, mirroring a compact log of the
+// stream-json output and returning the parsed result. No PID file is written:
+// the CLI exits by itself when done, so the stop hooks must not kill it before
+// it reports its result.
+type Headless struct {
+	Command string    // Agent CLI to run instead of claude
+	Log     io.Writer // Where the live log goes; stdout if nil
+}
+
+// Start starts the CLI with its output piped back to the loop
+func (r Headless) Start(ctx context.Context, prompt string, opts SessionOptions) (Session, error) {
+	cmd := exec.Command(commandOrDefault(r.Command), buildHeadlessArgs(prompt, opts)...)
+
+	// The CLI writes straight to pipes rather than through exec's copying,
+	// so that its exit is seen even while commands it left running still
+	// hold them open
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open claude output: %w", err)
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutW.Close()
+		return nil, fmt.Errorf("failed to open claude output: %w", err)
+	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	p, err := startProcess(ctx, cmd, false)
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdout.Close()
+		stderrR.Close()
+		return nil, fmt.Errorf("failed to start claude: %w", err)
+	}
+
+	log := r.Log
+	if log == nil {
+		log = os.Stdout
+	}
+	s := &headlessSession{process: p}
+	s.lastEvent.Store(time.Now().UnixNano())
+	s.output.Add(2)
+	go func() {
+		defer s.output.Done()
+		defer stdout.Close()
+		s.result, s.parseErr = ParseStream(activityReader{stdout, &s.lastEvent}, log)
+		// Drain anything left so Claude doesn't block on a full pipe
+		io.Copy(io.Discard, stdout)
+	}()
+	go func() {
+		defer s.output.Done()
+		defer stderrR.Close()
+		io.Copy(io.MultiWriter(os.Stderr, &s.stderr), stderrR)
+	}()
+	return s, nil
+}
+
+type headlessSession struct {
+	*process
+	output    sync.WaitGroup // Done once both outputs are read to the end
+	stderr    bytes.Buffer
+	result    *SessionResult
+	parseErr  error
+	lastEvent atomic.Int64 // When output was last read, in Unix nanoseconds
+}
+
+// LastActivity returns when the CLI last printed an event
+func (s *headlessSession) LastActivity() time.Time {
+	return time.Unix(0, s.lastEvent.Load())
+}
+
+func (s *headlessSession) Wait() (*SessionResult, error) {
+	err := s.wait()
+	s.output.Wait()
+	if err != nil {
+		stderr := strings.TrimSpace(s.stderr.String())
+		if s.result.APIError == "" {
+			s.result.APIError = lastAPIError(stderr)
+		}
+		// API errors may only have been reported in the stream
+		if msg := s.result.ErrorMessage(); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return s.result, fmt.Errorf("claude exited with error: %w\nstderr: %s", err, stderr)
+	}
+	if s.parseErr != nil {
+		return s.result, s.parseErr
+	}
+	return s.result, nil
+}
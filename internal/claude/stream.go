@@ -0,0 +1,209 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// logLineWidth is the maximum width of a line in the compact live log
+const logLineWidth = 120
+
+// StreamEvent is one line of `claude -p --output-format stream-json` output
+type StreamEvent struct {
+	Type      string         `json:"type"`    // system, assistant, user, result
+	Subtype   string         `json:"subtype"` // e.g. init, success, error_max_turns
+	SessionID string         `json:"session_id"`
+	Model     string         `json:"model,omitempty"` // system init only
+	Message   *StreamMessage `json:"message,omitempty"`
+
+	// Result event fields
+	Result       string  `json:"result,omitempty"`
+	IsError      bool    `json:"is_error,omitempty"`
+	NumTurns     int     `json:"num_turns,omitempty"`
+	DurationMs   int64   `json:"duration_ms,omitempty"`
+	TotalCostUSD float64 `json:"total_cost_usd,omitempty"`
+	Usage        *Usage  `json:"usage,omitempty"`
+}
+
+// StreamMessage is an assistant or user message inside a stream event
+type StreamMessage struct {
+	Model   string         `json:"model,omitempty"`
+	Content []ContentBlock `json:"content"`
+	Usage   *Usage         `json:"usage,omitempty"`
+}
+
+// ContentBlock is one block of message content
+type ContentBlock struct {
+	Type  string          `json:"type"` // text, tool_use, tool_result, thinking
+	Text  string          `json:"text,omitempty"`
+	Name  string          `json:"name,omitempty"` // tool_use only
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// Usage holds token counts reported by Claude
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// SessionResult is the structured outcome of a Claude session
+type SessionResult struct {
+	SessionID  string
+	Model      string
+	Result     string // Final assistant text
+	IsError    bool
+	NumTurns   int
+	DurationMs int64
+	CostUSD    float64
+	Estimated  bool // CostUSD was estimated from list prices rather than reported
+	Usage      Usage
+	ToolUses   int
+	APIError   string // Last API error Claude reported in the stream or on stderr, e.g. "API Error: 529 ..."
+}
+
+// ErrorMessage returns the first line of the error the session reported as its
+// result, or else the last API error it ran into, if any
+func (r *SessionResult) ErrorMessage() string {
+	if r == nil {
+		return ""
+	}
+	if msg, _, _ := strings.Cut(strings.TrimSpace(r.Result), "\n"); r.IsError && msg != "" {
+		return msg
+	}
+	return r.APIError
+}
+
+// buildHeadlessArgs builds the argument list for running Claude headless with stream-json output
+func buildHeadlessArgs(prompt string, opts SessionOptions) []string {
+	// stream-json output in print mode requires --verbose
+	args := []string{"-p", "--output-format", "stream-json", "--verbose"}
+	args = append(args, opts.Args()...)
+	args = append(args, "--", prompt)
+	return args
+}
+
+// ParseStream reads stream-json events, writing a compact live log to log,
+// and returns the session result. Lines that aren't JSON are passed through.
+func ParseStream(r io.Reader, log io.Writer) (*SessionResult, error) {
+	result := &SessionResult{}
+	gotResult := false
+	reader := bufio.NewReader(r)
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+
+		if len(line) > 0 {
+			var event StreamEvent
+			if err := json.Unmarshal(line, &event); err != nil {
+				fmt.Fprintf(log, "  %s\n", truncate(string(line), logLineWidth))
+			} else {
+				if event.Type == "result" {
+					gotResult = true
+				}
+				applyEvent(result, &event, log)
+			}
+		}
+
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+			return result, fmt.Errorf("failed to read claude output: %w", readErr)
+		}
+	}
+
+	if !gotResult {
+		return result, fmt.Errorf("claude output ended without a result event")
+	}
+	return result, nil
+}
+
+// applyEvent folds one event into the result and logs it
+func applyEvent(result *SessionResult, event *StreamEvent, log io.Writer) {
+	if event.SessionID != "" {
+		result.SessionID = event.SessionID
+	}
+
+	switch event.Type {
+	case "system":
+		if event.Subtype == "init" && event.Model != "" {
+			result.Model = event.Model
+			fmt.Fprintf(log, "  ▸ session started (%s)\n", event.Model)
+		}
+
+	case "assistant":
+		if event.Message == nil {
+			return
+		}
+		if event.Message.Model != "" {
+			result.Model = event.Message.Model
+		}
+		for _, block := range event.Message.Content {
+			switch block.Type {
+			case "text":
+				if text := strings.TrimSpace(block.Text); strings.HasPrefix(text, "API Error") {
+					result.APIError, _, _ = strings.Cut(text, "\n")
+				}
+				if text := firstLine(block.Text); text != "" {
+					fmt.Fprintf(log, "  │ %s\n", truncate(text, logLineWidth))
+				}
+			case "tool_use":
+				result.ToolUses++
+				fmt.Fprintf(log, "  ⚙ %s\n", truncate(describeToolUse(block), logLineWidth))
+			}
+		}
+
+	case "result":
+		result.Result = event.Result
+		result.IsError = event.IsError
+		result.NumTurns = event.NumTurns
+		result.DurationMs = event.DurationMs
+		result.CostUSD = event.TotalCostUSD
+		if event.Usage != nil {
+			result.Usage = *event.Usage
+		}
+		status := "✓"
+		if event.IsError {
+			status = "✗"
+		}
+		fmt.Fprintf(log, "  %s %s: %d turns, %.1fs, $%.4f\n", status, event.Subtype, event.NumTurns, float64(event.DurationMs)/1000, event.TotalCostUSD)
+	}
+}
+
+// describeToolUse summarizes a tool call as "Tool: main argument"
+func describeToolUse(block ContentBlock) string {
+	var input map[string]any
+	if err := json.Unmarshal(block.Input, &input); err != nil {
+		return block.Name
+	}
+	for _, key := range []string{"command", "file_path", "path", "pattern", "url", "description"} {
+		if v, ok := input[key].(string); ok && v != "" {
+			return fmt.Sprintf("%s: %s", block.Name, firstLine(v))
+		}
+	}
+	return block.Name
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		return strings.TrimSpace(s[:idx]) + " …"
+	}
+	return s
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleStream = `{"type":"system","subtype":"init","session_id":"abc","model":"claude-sonnet"}
+{"type":"assistant","session_id":"abc","message":{"model":"claude-sonnet","content":[{"type":"text","text":"Let me look at the code.\nMore detail here."}]}}
+{"type":"assistant","session_id":"abc","message":{"content":[{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"user","session_id":"abc","message":{"content":[{"type":"tool_result"}]}}
+not json at all
+{"type":"assistant","session_id":"abc","message":{"content":[{"type":"tool_use","name":"Edit","input":{"file_path":"main.go","old_string":"a"}},{"type":"tool_use","name":"TodoWrite","input":{"todos":[]}}]}}
+{"type":"result","subtype":"success","session_id":"abc","result":"Done.","is_error":false,"num_turns":4,"duration_ms":12500,"total_cost_usd":0.0421,"usage":{"input_tokens":100,"output_tokens":50,"cache_creation_input_tokens":10,"cache_read_input_tokens":2000}}
+`
+
+func TestParseStream(t *testing.T) {
+	var log bytes.Buffer
+	result, err := ParseStream(strings.NewReader(sampleStream), &log)
+	if err != nil {
+		t.Fatalf("ParseStream failed: %v", err)
+	}
+
+	want := &SessionResult{
+		SessionID:  "abc",
+		Model:      "claude-sonnet",
+		Result:     "Done.",
+		NumTurns:   4,
+		DurationMs: 12500,
+		CostUSD:    0.0421,
+		Usage: Usage{
+			InputTokens:              100,
+			OutputTokens:             50,
+			CacheCreationInputTokens: 10,
+			CacheReadInputTokens:     2000,
+		},
+		ToolUses: 3,
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("ParseStream() = %+v, want %+v", result, want)
+	}
+
+	out := log.String()
+	for _, line := range []string{
+		"session started (claude-sonnet)",
+		"│ Let me look at the code. …",
+		"⚙ Bash: go test ./...",
+		"⚙ Edit: main.go",
+		"⚙ TodoWrite",
+		"not json at all",
+		"✓ success: 4 turns, 12.5s, $0.0421",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("log should contain %q, got:\n%s", line, out)
+		}
+	}
+}
+
+func TestParseStreamErrorResult(t *testing.T) {
+	stream := `{"type":"result","subtype":"error_max_turns","is_error":true,"result":"Max turns reached"}`
+	var log bytes.Buffer
+	result, err := ParseStream(strings.NewReader(stream), &log)
+	if err != nil {
+		t.Fatalf("ParseStream failed: %v", err)
+	}
+	if !result.IsError || result.Result != "Max turns reached" {
+		t.Errorf("expected error result, got %+v", result)
+	}
+	if !strings.Contains(log.String(), "✗ error_max_turns") {
+		t.Errorf("log should mark the error, got %q", log.String())
+	}
+}
+
+func TestParseStreamAPIError(t *testing.T) {
+	stream := `{"type":"assistant","message":{"content":[{"type":"text","text":"API Error: 529 {\"type\":\"overloaded_error\"}\nRetrying..."}]}}
+{"type":"result","subtype":"success","is_error":true,"result":""}`
+	result, err := ParseStream(strings.NewReader(stream), io.Discard)
+	if err != nil {
+		t.Fatalf("ParseStream failed: %v", err)
+	}
+	if want := `API Error: 529 {"type":"overloaded_error"}`; result.APIError != want || result.ErrorMessage() != want {
+		t.Errorf("expected the API error to be reported, got %+v", result)
+	}
+}
+
+func TestParseStreamMissingResult(t *testing.T) {
+	stream := `{"type":"system","subtype":"init","session_id":"abc"}`
+	var log bytes.Buffer
+	result, err := ParseStream(strings.NewReader(stream), &log)
+	if err == nil {
+		t.Error("expected error when stream has no result event")
+	}
+	if result.SessionID != "abc" {
+		t.Errorf("partial result should still be returned, got %+v", result)
+	}
+}
+
+func TestBuildHeadlessArgs(t *testing.T) {
+	got := buildHeadlessArgs("do it", SessionOptions{PermissionMode: "acceptEdits", Model: "opus", MaxTurns: 30})
+	want := []string{"-p", "--output-format", "stream-json", "--verbose", "--permission-mode", "acceptEdits", "--model", "opus", "--max-turns", "30", "--", "do it"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildHeadlessArgs() = %v, want %v", got, want)
+	}
+
+	got = buildHeadlessArgs("do it", SessionOptions{})
+	want = []string{"-p", "--output-format", "stream-json", "--verbose", "--", "do it"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildHeadlessArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("short", 10); got != "short" {
+		t.Errorf("truncate should leave short strings alone, got %q", got)
+	}
+	if got := truncate("héllo world", 5); got != "héll…" {
+		t.Errorf("truncate should cut on runes, got %q", got)
+	}
+}
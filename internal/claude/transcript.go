@@ -0,0 +1,162 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// transcriptEntry is one line of a Claude Code transcript (JSONL)
+type transcriptEntry struct {
+	Type      string `json:"type"`
+	SessionID string `json:"sessionId"`
+	// Set on the replies Claude Code writes itself when an API call fails
+	IsAPIErrorMessage bool `json:"isApiErrorMessage"`
+	Message           *struct {
+		ID      string         `json:"id"`
+		Model   string         `json:"model"`
+		Content []ContentBlock `json:"content"`
+		Usage   *Usage         `json:"usage"`
+	} `json:"message"`
+}
+
+// modelPricing is USD per million tokens
+type modelPricing struct {
+	input, output, cacheWrite, cacheRead float64
+}
+
+// pricing is matched against model names in order; the first match wins
+var pricing = []struct {
+	match string
+	price modelPricing
+}{
+	{"opus-4-5", modelPricing{5, 25, 6.25, 0.50}},
+	{"opus-4-6", modelPricing{5, 25, 6.25, 0.50}},
+	{"opus", modelPricing{15, 75, 18.75, 1.50}},
+	{"sonnet", modelPricing{3, 15, 3.75, 0.30}},
+	{"haiku-4", modelPricing{1, 5, 1.25, 0.10}},
+	{"haiku", modelPricing{0.80, 4, 1, 0.08}},
+}
+
+// EstimateCost estimates the USD cost of the given usage from list prices.
+// Unknown models are priced as Sonnet.
+func EstimateCost(model string, u Usage) float64 {
+	p := modelPricing{3, 15, 3.75, 0.30}
+	for _, entry := range pricing {
+		if strings.Contains(model, entry.match) {
+			p = entry.price
+			break
+		}
+	}
+	return (float64(u.InputTokens)*p.input +
+		float64(u.OutputTokens)*p.output +
+		float64(u.CacheCreationInputTokens)*p.cacheWrite +
+		float64(u.CacheReadInputTokens)*p.cacheRead) / 1_000_000
+}
+
+// LatestTranscript returns the transcript of the session in the current
+// directory that was written to last, if that was after since. It finds the
+// transcript of a session that ended before its stop hook could record it.
+func LatestTranscript(since time.Time) (string, error) {
+	dir, err := TranscriptDir()
+	if err != nil {
+		return "", err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return "", err
+	}
+	var latest string
+	latestTime := since
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latestTime) {
+			latest, latestTime = path, info.ModTime()
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no transcript written since %s in %s", since.Format(time.TimeOnly), dir)
+	}
+	return latest, nil
+}
+
+// ParseTranscript sums token usage from a Claude Code transcript file.
+// Transcripts don't record cost, so CostUSD is estimated from list prices.
+// If the session's last reply is an API error, the result is an error with
+// that message, as a headless session would report it.
+func ParseTranscript(path string) (*SessionResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer f.Close()
+
+	result := &SessionResult{Estimated: true}
+	seen := make(map[string]bool)
+	reader := bufio.NewReader(f)
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var entry transcriptEntry
+			if err := json.Unmarshal(line, &entry); err == nil {
+				addTranscriptEntry(result, &entry, seen)
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+			return result, fmt.Errorf("failed to read transcript: %w", readErr)
+		}
+	}
+
+	return result, nil
+}
+
+// addTranscriptEntry folds an assistant entry into the result. Claude Code
+// writes one entry per content block, each repeating the message's usage,
+// so usage is only counted once per message ID.
+func addTranscriptEntry(result *SessionResult, entry *transcriptEntry, seen map[string]bool) {
+	if entry.SessionID != "" {
+		result.SessionID = entry.SessionID
+	}
+	if entry.Type != "assistant" || entry.Message == nil {
+		return
+	}
+	msg := entry.Message
+
+	// Only an error the session didn't get past counts
+	result.IsError, result.Result, result.APIError = false, "", ""
+	for _, block := range msg.Content {
+		if block.Type == "tool_use" {
+			result.ToolUses++
+		}
+		if entry.IsAPIErrorMessage && block.Type == "text" && !result.IsError {
+			result.IsError, result.Result = true, block.Text
+			result.APIError, _, _ = strings.Cut(block.Text, "\n")
+		}
+	}
+
+	if msg.ID != "" && seen[msg.ID] {
+		return
+	}
+	seen[msg.ID] = true
+
+	if msg.Model != "" && msg.Model != "<synthetic>" {
+		result.Model = msg.Model
+	}
+	result.NumTurns++
+	if msg.Usage != nil {
+		result.Usage.InputTokens += msg.Usage.InputTokens
+		result.Usage.OutputTokens += msg.Usage.OutputTokens
+		result.Usage.CacheCreationInputTokens += msg.Usage.CacheCreationInputTokens
+		result.Usage.CacheReadInputTokens += msg.Usage.CacheReadInputTokens
+		result.CostUSD += EstimateCost(msg.Model, *msg.Usage)
+	}
+}
@@ -0,0 +1,92 @@
+package claude
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTranscript(t *testing.T) {
+	transcript := `{"type":"user","sessionId":"s1","message":{"role":"user","content":"hi"}}
+{"type":"assistant","sessionId":"s1","message":{"id":"msg_1","model":"claude-sonnet-4-5","content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":1000,"output_tokens":100,"cache_creation_input_tokens":0,"cache_read_input_tokens":0}}}
+{"type":"assistant","sessionId":"s1","message":{"id":"msg_1","model":"claude-sonnet-4-5","content":[{"type":"tool_use","name":"Bash"}],"usage":{"input_tokens":1000,"output_tokens":100,"cache_creation_input_tokens":0,"cache_read_input_tokens":0}}}
+garbage line
+{"type":"assistant","sessionId":"s1","message":{"id":"msg_2","model":"claude-sonnet-4-5","content":[{"type":"text","text":"done"}],"usage":{"input_tokens":500,"output_tokens":50,"cache_creation_input_tokens":200,"cache_read_input_tokens":10000}}}
+`
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	os.WriteFile(path, []byte(transcript), 0644)
+
+	result, err := ParseTranscript(path)
+	if err != nil {
+		t.Fatalf("ParseTranscript failed: %v", err)
+	}
+
+	// msg_1 is split across two entries but counted once
+	want := Usage{InputTokens: 1500, OutputTokens: 150, CacheCreationInputTokens: 200, CacheReadInputTokens: 10000}
+	if result.Usage != want {
+		t.Errorf("usage = %+v, want %+v", result.Usage, want)
+	}
+	if result.NumTurns != 2 || result.ToolUses != 1 {
+		t.Errorf("expected 2 turns and 1 tool use, got %d/%d", result.NumTurns, result.ToolUses)
+	}
+	if result.SessionID != "s1" || result.Model != "claude-sonnet-4-5" {
+		t.Errorf("unexpected session/model %q/%q", result.SessionID, result.Model)
+	}
+
+	wantCost := EstimateCost("claude-sonnet-4-5", want)
+	if math.Abs(result.CostUSD-wantCost) > 1e-9 {
+		t.Errorf("cost = %f, want %f", result.CostUSD, wantCost)
+	}
+}
+
+func TestParseTranscriptAPIError(t *testing.T) {
+	apiError := `{"type":"assistant","sessionId":"s1","isApiErrorMessage":true,"message":{"id":"e1","model":"<synthetic>","content":[{"type":"text","text":"API Error: 529 {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\"}}"}]}}` + "\n"
+	reply := `{"type":"assistant","sessionId":"s1","message":{"id":"msg_1","model":"claude-sonnet-4-5","content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":10,"output_tokens":1}}}` + "\n"
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+
+	// The session ended on the error
+	os.WriteFile(path, []byte(reply+apiError), 0644)
+	result, err := ParseTranscript(path)
+	if err != nil {
+		t.Fatalf("ParseTranscript failed: %v", err)
+	}
+	if !result.IsError || Classify(result, errors.New("session ended")) != FailureTransient {
+		t.Errorf("expected a transient error result, got %+v", result)
+	}
+	if result.Model != "claude-sonnet-4-5" || result.Usage.InputTokens != 10 {
+		t.Errorf("the error should not change the usage, got %+v", result)
+	}
+
+	// The session got past the error
+	os.WriteFile(path, []byte(apiError+reply), 0644)
+	if result, _ := ParseTranscript(path); result.IsError || result.ErrorMessage() != "" {
+		t.Errorf("an error the session got past should not count, got %+v", result)
+	}
+}
+
+func TestParseTranscriptMissing(t *testing.T) {
+	if _, err := ParseTranscript(filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Error("expected error for missing transcript")
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	u := Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000}
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		{"claude-opus-4-1", 90},
+		{"claude-opus-4-5-20251101", 30},
+		{"claude-sonnet-4-5", 18},
+		{"claude-haiku-4-5", 6},
+		{"unknown-model", 18},
+	}
+	for _, tt := range tests {
+		if got := EstimateCost(tt.model, u); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("EstimateCost(%q) = %f, want %f", tt.model, got, tt.want)
+		}
+	}
+}
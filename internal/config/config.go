@@ -262,8 +262,9 @@ func Save(settings *ClaudeSettings) error {
 	return nil
 }
 
-// SetupPermissions merges baseline permissions with existing settings (no stop hook)
-func SetupPermissions() error {
+// SetupPermissions merges baseline permissions with existing settings and
+// adds the notification and Bash guard hooks (no stop hook)
+func SetupPermissions(autoclaudePath string) error {
 	baseline, err := LoadBaseline()
 	if err != nil {
 		return err
@@ -276,9 +277,33 @@ func SetupPermissions() error {
 
 	merged := MergeSettings(baseline, existing)
 	AddNotificationHooks(merged)
+	AddBashGuardHook(merged, autoclaudePath)
 	return Save(merged)
 }
 
+// AddBashGuardHook adds the hook that denies heredocs, herestrings and awk in Bash calls
+func AddBashGuardHook(settings *ClaudeSettings, autoclaudePath string) {
+	expectedCmd := fmt.Sprintf("%s _bash-guard", autoclaudePath)
+
+	if settings.Hooks == nil {
+		settings.Hooks = &Hooks{}
+	}
+
+	// Check if we already have our hook
+	for _, hc := range settings.Hooks.PreToolUse {
+		for _, h := range hc.Hooks {
+			if h.Command == expectedCmd {
+				return // Already configured
+			}
+		}
+	}
+
+	settings.Hooks.PreToolUse = append(settings.Hooks.PreToolUse, HookConfig{
+		Matcher: "Bash",
+		Hooks:   []Hook{{Type: "command", Command: expectedCmd}},
+	})
+}
+
 // AddNotificationHooks adds hooks to ring terminal bell on permission requests and user questions
 func AddNotificationHooks(settings *ClaudeSettings) {
 	if settings.Hooks == nil {
@@ -360,6 +385,49 @@ func RemoveStopHook(autoclaudePath string) error {
 	return Save(existing)
 }
 
+// PreToolUseMatcher selects the tool calls the _pre-tool-use hook checks
+const PreToolUseMatcher = "Write|Edit|MultiEdit|NotebookEdit|Bash"
+
+// SetupPreToolUseHook adds the hook that denies tool calls touching protected paths
+func SetupPreToolUseHook(autoclaudePath string) error {
+	// Remove any existing hook first
+	RemovePreToolUseHook(autoclaudePath)
+
+	existing, err := LoadExisting()
+	if err != nil {
+		return err
+	}
+
+	if existing.Hooks == nil {
+		existing.Hooks = &Hooks{}
+	}
+
+	expectedCmd := fmt.Sprintf("%s _pre-tool-use", autoclaudePath)
+	hookConfig := HookConfig{
+		Matcher: PreToolUseMatcher,
+		Hooks:   []Hook{{Type: "command", Command: expectedCmd}},
+	}
+	existing.Hooks.PreToolUse = append(existing.Hooks.PreToolUse, hookConfig)
+	return Save(existing)
+}
+
+// RemovePreToolUseHook removes the protected paths hook
+func RemovePreToolUseHook(autoclaudePath string) error {
+	existing, err := LoadExisting()
+	if err != nil {
+		return err
+	}
+
+	if existing.Hooks == nil || len(existing.Hooks.PreToolUse) == 0 {
+		return nil
+	}
+
+	expectedCmd := fmt.Sprintf("%s _pre-tool-use", autoclaudePath)
+	existing.Hooks.PreToolUse = filterOutCommand(existing.Hooks.PreToolUse, expectedCmd)
+
+	return Save(existing)
+}
+
 // SetupPlannerStopHook adds a stop hook that exits when planner asks for confirmation
 func SetupPlannerStopHook(autoclaudePath string) error {
 	// Remove any existing planner hook first
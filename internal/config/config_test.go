@@ -221,6 +221,21 @@ func TestAddNotificationHooks(t *testing.T) {
 	}
 }
 
+func TestAddBashGuardHook(t *testing.T) {
+	settings := &ClaudeSettings{}
+	AddNotificationHooks(settings)
+	AddBashGuardHook(settings, "/path/to/autoclaude")
+	AddBashGuardHook(settings, "/path/to/autoclaude") // Should not duplicate
+
+	if len(settings.Hooks.PreToolUse) != 2 {
+		t.Fatalf("expected the bell hook and the Bash guard hook, got %+v", settings.Hooks.PreToolUse)
+	}
+	hc := settings.Hooks.PreToolUse[1]
+	if hc.Matcher != "Bash" || hc.Hooks[0].Command != "/path/to/autoclaude _bash-guard" {
+		t.Errorf("unexpected hook %+v", hc)
+	}
+}
+
 func TestSaveAndLoad(t *testing.T) {
 	tmpDir := t.TempDir()
 	oldDir, _ := os.Getwd()
@@ -256,6 +271,40 @@ func TestSaveAndLoad(t *testing.T) {
 	}
 }
 
+func TestPreToolUseHook(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	settings := &ClaudeSettings{}
+	AddNotificationHooks(settings)
+	Save(settings)
+
+	// Setting up twice must not duplicate the hook
+	for range 2 {
+		if err := SetupPreToolUseHook("/path/to/autoclaude"); err != nil {
+			t.Fatalf("SetupPreToolUseHook failed: %v", err)
+		}
+	}
+	loaded, _ := LoadExisting()
+	if len(loaded.Hooks.PreToolUse) != 2 {
+		t.Fatalf("expected the bell hook and the guard hook, got %+v", loaded.Hooks.PreToolUse)
+	}
+	hc := loaded.Hooks.PreToolUse[1]
+	if hc.Matcher != PreToolUseMatcher || hc.Hooks[0].Command != "/path/to/autoclaude _pre-tool-use" {
+		t.Errorf("unexpected hook %+v", hc)
+	}
+
+	if err := RemovePreToolUseHook("/path/to/autoclaude"); err != nil {
+		t.Fatalf("RemovePreToolUseHook failed: %v", err)
+	}
+	loaded, _ = LoadExisting()
+	if len(loaded.Hooks.PreToolUse) != 1 || loaded.Hooks.PreToolUse[0].Matcher != "AskUserQuestion" {
+		t.Errorf("expected only the bell hook left, got %+v", loaded.Hooks.PreToolUse)
+	}
+}
+
 func TestFilterOutCommand(t *testing.T) {
 	hookConfigs := []HookConfig{
 		{Hooks: []Hook{{Type: "command", Command: "keep1"}, {Type: "command", Command: "remove"}}},
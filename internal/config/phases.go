@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+// DefaultPermissionMode is used for phases that don't set one
+const DefaultPermissionMode = "acceptEdits"
+
+// PermissionModes are the permission modes Claude accepts
+var PermissionModes = []string{"acceptEdits", "bypassPermissions", "default", "dontAsk", "plan"}
+
+// managedFlags are set by autoclaude and can't be passed as extra args
+var managedFlags = []string{"-p", "--print", "--model", "--permission-mode", "--max-turns", "--output-format"}
+
+// PhaseConfig configures the Claude session for one phase
+type PhaseConfig struct {
+	Model          string   `yaml:"model,omitempty"`
+	PermissionMode string   `yaml:"permission_mode,omitempty"`
+	ExtraArgs      []string `yaml:"extra_args,omitempty"`
+	MaxTurns       int      `yaml:"max_turns,omitempty"`
+	Timeout        string   `yaml:"timeout,omitempty"`      // Longest a session may run, e.g. "45m"
+	IdleTimeout    string   `yaml:"idle_timeout,omitempty"` // Longest a session may go without activity
+}
+
+// Phases maps each phase to its session configuration
+type Phases map[state.Phase]PhaseConfig
+
+// DefaultPhases returns the configuration used when config.yaml sets nothing
+func DefaultPhases() Phases {
+	phases := make(Phases)
+	for _, phase := range state.Phases {
+		phases[phase] = PhaseConfig{PermissionMode: DefaultPermissionMode}
+	}
+	return phases
+}
+
+// Validate checks phase names, permission modes, max turns, timeouts and extra args
+func (p Phases) Validate() error {
+	var errs []error
+	for _, phase := range slices.Sorted(maps.Keys(p)) {
+		pc := p[phase]
+		if !slices.Contains(state.Phases, phase) {
+			errs = append(errs, fmt.Errorf("unknown phase %q", phase))
+			continue
+		}
+		if pc.PermissionMode != "" && !slices.Contains(PermissionModes, pc.PermissionMode) {
+			errs = append(errs, fmt.Errorf("%s: unknown permission_mode %q (want one of %s)", phase, pc.PermissionMode, strings.Join(PermissionModes, ", ")))
+		}
+		if strings.ContainsAny(pc.Model, " \t\n") {
+			errs = append(errs, fmt.Errorf("%s: invalid model %q", phase, pc.Model))
+		}
+		if pc.MaxTurns < 0 {
+			errs = append(errs, fmt.Errorf("%s: max_turns must not be negative", phase))
+		}
+		for key, value := range map[string]string{"timeout": pc.Timeout, "idle_timeout": pc.IdleTimeout} {
+			if d, err := parseDuration(value); err != nil || d < 0 {
+				errs = append(errs, fmt.Errorf("%s: %s must be a duration like 30m, got %q", phase, key, value))
+			}
+		}
+		for _, arg := range pc.ExtraArgs {
+			flag, _, _ := strings.Cut(arg, "=")
+			if slices.Contains(managedFlags, flag) {
+				errs = append(errs, fmt.Errorf("%s: extra_args can't set %s, use the phase settings instead", phase, flag))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// For returns the configuration for a phase
+func (p Phases) For(phase state.Phase) PhaseConfig {
+	if pc, ok := p[phase]; ok {
+		return pc
+	}
+	return PhaseConfig{PermissionMode: DefaultPermissionMode}
+}
+
+// SetModel sets the model for the given phases
+func (p Phases) SetModel(model string, phases ...state.Phase) {
+	for _, phase := range phases {
+		pc := p.For(phase)
+		pc.Model = model
+		p[phase] = pc
+	}
+}
+
+// SessionOptions converts the phase configuration to Claude session options
+func (pc PhaseConfig) SessionOptions() claude.SessionOptions {
+	return claude.SessionOptions{
+		PermissionMode: pc.PermissionMode,
+		Model:          pc.Model,
+		MaxTurns:       pc.MaxTurns,
+		ExtraArgs:      pc.ExtraArgs,
+		Timeout:        mustParseDuration(pc.Timeout),
+		IdleTimeout:    mustParseDuration(pc.IdleTimeout),
+	}
+}
+
+// parseDuration parses a timeout; empty means none
+func parseDuration(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(v)
+}
+
+// mustParseDuration parses a validated timeout
+func mustParseDuration(v string) time.Duration {
+	d, _ := parseDuration(v)
+	return d
+}
+
+// String summarizes the configuration, e.g. "model=opus, acceptEdits, max 50 turns"
+func (pc PhaseConfig) String() string {
+	model := pc.Model
+	if model == "" {
+		model = "default"
+	}
+	parts := []string{"model=" + model, pc.PermissionMode}
+	if pc.MaxTurns > 0 {
+		parts = append(parts, fmt.Sprintf("max %d turns", pc.MaxTurns))
+	}
+	if pc.Timeout != "" {
+		parts = append(parts, "timeout "+pc.Timeout)
+	}
+	if pc.IdleTimeout != "" {
+		parts = append(parts, "idle timeout "+pc.IdleTimeout)
+	}
+	if len(pc.ExtraArgs) > 0 {
+		parts = append(parts, "args: "+strings.Join(pc.ExtraArgs, " "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Clone returns a copy that can be modified independently
+func (p Phases) Clone() Phases {
+	clone := make(Phases, len(p))
+	for phase, pc := range p {
+		pc.ExtraArgs = slices.Clone(pc.ExtraArgs)
+		clone[phase] = pc
+	}
+	return clone
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+func TestLoadPhases(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	os.MkdirAll(AutoclaudeDir, 0755)
+
+	// Missing file gives defaults
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	phases := cfg.Phases
+	if got := phases.For(state.PhaseCritic); got.PermissionMode != DefaultPermissionMode || got.Model != "" {
+		t.Errorf("unexpected default critic config %+v", got)
+	}
+
+	os.WriteFile(ProjectConfigPath(), []byte(`phases:
+  coder:
+    model: sonnet
+    max_turns: 50
+    extra_args: ["--add-dir", "../shared"]
+    timeout: 45m
+    idle_timeout: 10m
+  critic:
+    model: opus
+    permission_mode: plan
+`), 0644)
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	phases = cfg.Phases
+
+	want := claude.SessionOptions{PermissionMode: "acceptEdits", Model: "sonnet", MaxTurns: 50, ExtraArgs: []string{"--add-dir", "../shared"},
+		Timeout: 45 * time.Minute, IdleTimeout: 10 * time.Minute}
+	if got := phases.For(state.PhaseCoder).SessionOptions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("coder options = %+v, want %+v", got, want)
+	}
+	if got := phases.For(state.PhaseCritic); got.Model != "opus" || got.PermissionMode != "plan" {
+		t.Errorf("unexpected critic config %+v", got)
+	}
+	if got := phases.For(state.PhaseFixer); got.PermissionMode != DefaultPermissionMode {
+		t.Errorf("unset phases should keep defaults, got %+v", got)
+	}
+
+	phases.SetModel("haiku", state.PhaseFixer)
+	if got := phases.For(state.PhaseFixer); got.Model != "haiku" || got.PermissionMode != DefaultPermissionMode {
+		t.Errorf("SetModel should only change the model, got %+v", got)
+	}
+}
+
+func TestLoadPhasesInvalid(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	os.MkdirAll(AutoclaudeDir, 0755)
+	os.WriteFile(ProjectConfigPath(), []byte(`phases:
+  reviewer:
+    model: opus
+  critic:
+    permission_mode: yolo
+    max_turns: -1
+    idle_timeout: soon
+  fixer:
+    extra_args: ["--model=opus"]
+`), 0644)
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{`unknown phase "reviewer"`, `unknown permission_mode "yolo"`, "max_turns", "critic: idle_timeout must be a duration", "extra_args can't set --model"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q, got: %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,644 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/guard"
+	"go.coldcutz.net/autoclaude/internal/state"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	// ProjectConfigFile holds autoclaude settings for the project
+	ProjectConfigFile = "config.yaml"
+
+	// ConfigVersion is the config.yaml format version this build reads and writes
+	ConfigVersion = 1
+
+	// EnvPrefix prefixes environment variables that override config.yaml keys,
+	// e.g. AUTOCLAUDE_BUDGET_MAX_COST for budget.max_cost
+	EnvPrefix = "AUTOCLAUDE_"
+
+	DefaultRetryLimit    = 3    // Review attempts (test gate or critic) per TODO
+	DefaultPruneInterval = 5    // TODOs completed between auto-pruning
+	DefaultMaxFileKB     = 1024 // Largest file autoclaude commits on Claude's behalf
+	DefaultMaxRetries    = 4    // Retries of a session that failed with a transient API error
+
+	// DefaultRetryBackoff is the wait before the first retry of a session that
+	// failed with a transient API error
+	DefaultRetryBackoff = 10 * time.Second
+
+	// DefaultTestTimeout is the longest the test gate lets a test or lint
+	// command run
+	DefaultTestTimeout = 30 * time.Minute
+)
+
+// What happens to the code of a TODO that runs out of review attempts
+const (
+	ExhaustedKeep   = "keep"   // Leave the last attempt in place
+	ExhaustedRevert = "revert" // Commit a revert to the tree from before the coder started
+	ExhaustedStash  = "stash"  // Move the attempt's commits onto a side branch
+)
+
+// ExhaustedPolicies lists the valid on_exhausted values
+var ExhaustedPolicies = []string{ExhaustedKeep, ExhaustedRevert, ExhaustedStash}
+
+// How Claude sessions are run
+const (
+	BackendInteractive = "interactive" // Attached to the terminal
+	BackendHeadless    = "headless"    // Non-interactively, with a log of the stream-json output
+	BackendTmux        = "tmux"        // In a detached tmux session you can attach to
+)
+
+// Backends lists the valid runner.backend values
+var Backends = []string{BackendInteractive, BackendHeadless, BackendTmux}
+
+// ProjectConfig holds the autoclaude settings for a project.
+// Settings resolve as flag > environment > config.yaml > defaults.
+type ProjectConfig struct {
+	Version       int         `yaml:"version"`
+	RetryLimit    int         `yaml:"retry_limit,omitempty"`
+	PruneInterval int         `yaml:"prune_interval,omitempty"`  // -1 disables pruning
+	Parallel      int         `yaml:"parallel,omitempty"`        // Independent TODOs worked on at once, 0 or 1 for one at a time
+	BranchPerTodo bool        `yaml:"branch_per_todo,omitempty"` // Work on each TODO in its own branch, squash-merged on approval
+	OnExhausted   string      `yaml:"on_exhausted,omitempty"`    // keep, revert or stash
+	Budget        Budget      `yaml:"budget,omitempty"`
+	CommitGuard   CommitGuard `yaml:"commit_guard,omitempty"`
+	Runner        Runner      `yaml:"runner,omitempty"`
+	Protected     []string    `yaml:"protected_paths,omitempty"` // Globs Claude's Write, Edit and Bash calls may not touch
+	LintCommands  []string    `yaml:"lint_commands,omitempty"`   // Run by the test gate after the test command
+	TestTimeout   string      `yaml:"test_timeout,omitempty"`    // Duration a test or lint command may run, "0" for no limit
+	Phases        Phases      `yaml:"phases,omitempty"`
+}
+
+// Budget caps the resources a run may use. Zero values mean no limit.
+type Budget struct {
+	MaxCost     float64 `yaml:"max_cost,omitempty"`     // USD
+	MaxTokens   int     `yaml:"max_tokens,omitempty"`   // Including cache reads and writes
+	MaxTime     string  `yaml:"max_time,omitempty"`     // Duration, e.g. "2h"
+	MaxSessions int     `yaml:"max_sessions,omitempty"` // Claude sessions
+}
+
+// CommitGuard limits what autoclaude commits when it commits changes Claude
+// left behind. Secrets are always refused.
+type CommitGuard struct {
+	MaxFileKB    int      `yaml:"max_file_kb,omitempty"`   // -1 for no limit
+	AllowedPaths []string `yaml:"allowed_paths,omitempty"` // Globs; empty allows every path
+}
+
+// Runner picks how Claude sessions are run, and which agent CLI runs them.
+// Any CLI that takes claude's flags, runs its hooks and, for headless runs,
+// prints its stream-json output can stand in for claude.
+type Runner struct {
+	Backend string `yaml:"backend,omitempty"` // interactive, headless or tmux
+	Command string `yaml:"command,omitempty"` // Agent CLI to run; claude if empty
+
+	// Sessions that fail with a transient API error, such as an overload or a
+	// rate limit, are run again after a backoff that doubles for each retry
+	MaxRetries   *int   `yaml:"max_retries,omitempty"`   // 0 disables retrying; the default if unset
+	RetryBackoff string `yaml:"retry_backoff,omitempty"` // Duration before the first retry, e.g. "10s"
+}
+
+// ProjectConfigPath returns the path to the config.yaml file
+func ProjectConfigPath() string {
+	return filepath.Join(AutoclaudeDir, ProjectConfigFile)
+}
+
+// Default returns the configuration used when nothing is set
+func Default() *ProjectConfig {
+	return &ProjectConfig{
+		Version:       ConfigVersion,
+		RetryLimit:    DefaultRetryLimit,
+		PruneInterval: DefaultPruneInterval,
+		OnExhausted:   ExhaustedKeep,
+		TestTimeout:   DefaultTestTimeout.String(),
+		CommitGuard:   CommitGuard{MaxFileKB: DefaultMaxFileKB},
+		Runner:        Runner{Backend: BackendInteractive, MaxRetries: intPtr(DefaultMaxRetries), RetryBackoff: DefaultRetryBackoff.String()},
+		Phases:        DefaultPhases(),
+	}
+}
+
+// Load resolves the project configuration from defaults, config.yaml and
+// AUTOCLAUDE_* environment variables, in increasing precedence. Flags are
+// applied by the caller with Set. A missing config.yaml is not an error.
+func Load() (*ProjectConfig, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(ProjectConfigPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", ProjectConfigPath(), err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ProjectConfigPath(), err)
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	cfg.normalize()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// LoadFile reads config.yaml as written, without defaults or environment
+// overrides. A missing file yields an empty configuration.
+func LoadFile() (*ProjectConfig, error) {
+	cfg := &ProjectConfig{}
+	data, err := os.ReadFile(ProjectConfigPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", ProjectConfigPath(), err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ProjectConfigPath(), err)
+	}
+	return cfg, nil
+}
+
+// SaveFile writes the configuration to config.yaml, stamping the current version
+func (c *ProjectConfig) SaveFile() error {
+	c.Version = ConfigVersion
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	data := buf.Bytes()
+	if err := os.MkdirAll(AutoclaudeDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(ProjectConfigPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ProjectConfigPath(), err)
+	}
+	return nil
+}
+
+// Merged returns the file configuration layered over the defaults, for
+// validating a config.yaml before it is written
+func (c *ProjectConfig) Merged() (*ProjectConfig, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	merged := Default()
+	if err := yaml.Unmarshal(data, merged); err != nil {
+		return nil, fmt.Errorf("failed to merge config: %w", err)
+	}
+	merged.normalize()
+	return merged, nil
+}
+
+// applyEnv applies AUTOCLAUDE_* overrides for every settable key
+func (c *ProjectConfig) applyEnv() error {
+	for _, key := range configKeys() {
+		value, ok := os.LookupEnv(EnvVar(key.name))
+		if !ok {
+			continue
+		}
+		if err := key.set(c, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvVar(key.name), err)
+		}
+	}
+	return nil
+}
+
+// normalize fills in defaults for values that were left unset
+func (c *ProjectConfig) normalize() {
+	if c.Version == 0 {
+		c.Version = ConfigVersion // Files written before versioning
+	}
+	if c.PruneInterval == 0 {
+		c.PruneInterval = DefaultPruneInterval
+	}
+	if c.OnExhausted == "" {
+		c.OnExhausted = ExhaustedKeep
+	}
+	if c.TestTimeout == "" {
+		c.TestTimeout = DefaultTestTimeout.String()
+	}
+	if c.CommitGuard.MaxFileKB == 0 {
+		c.CommitGuard.MaxFileKB = DefaultMaxFileKB
+	}
+	if c.Runner.Backend == "" {
+		c.Runner.Backend = BackendInteractive
+	}
+	if c.Runner.MaxRetries == nil {
+		c.Runner.MaxRetries = intPtr(DefaultMaxRetries)
+	}
+	if c.Runner.RetryBackoff == "" {
+		c.Runner.RetryBackoff = DefaultRetryBackoff.String()
+	}
+	for phase, pc := range c.Phases {
+		if pc.PermissionMode == "" {
+			pc.PermissionMode = DefaultPermissionMode
+			c.Phases[phase] = pc
+		}
+	}
+}
+
+// Validate checks that every setting is usable
+func (c *ProjectConfig) Validate() error {
+	var errs []error
+	if c.Version > ConfigVersion {
+		errs = append(errs, fmt.Errorf("version %d is newer than this autoclaude supports (%d); upgrade autoclaude", c.Version, ConfigVersion))
+	}
+	if c.RetryLimit < 1 {
+		errs = append(errs, fmt.Errorf("retry_limit must be at least 1"))
+	}
+	if c.PruneInterval < -1 {
+		errs = append(errs, fmt.Errorf("prune_interval must be positive, or -1 to disable pruning"))
+	}
+	if c.Parallel < 0 {
+		errs = append(errs, fmt.Errorf("parallel must not be negative"))
+	}
+	if !slices.Contains(ExhaustedPolicies, c.OnExhausted) {
+		errs = append(errs, fmt.Errorf("on_exhausted must be one of %s, got %q", strings.Join(ExhaustedPolicies, ", "), c.OnExhausted))
+	}
+	if c.Budget.MaxCost < 0 || c.Budget.MaxTokens < 0 || c.Budget.MaxSessions < 0 {
+		errs = append(errs, fmt.Errorf("budget limits must not be negative"))
+	}
+	if c.Budget.MaxTime != "" {
+		if d, err := time.ParseDuration(c.Budget.MaxTime); err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("budget.max_time must be a duration like 2h30m, got %q", c.Budget.MaxTime))
+		}
+	}
+	if d, err := time.ParseDuration(c.TestTimeout); err != nil || d < 0 {
+		errs = append(errs, fmt.Errorf("test_timeout must be a duration like 30m, or 0 for no limit, got %q", c.TestTimeout))
+	}
+	if c.CommitGuard.MaxFileKB < -1 {
+		errs = append(errs, fmt.Errorf("commit_guard.max_file_kb must be positive, or -1 for no limit"))
+	}
+	if !slices.Contains(Backends, c.Runner.Backend) {
+		errs = append(errs, fmt.Errorf("runner.backend must be one of %s, got %q", strings.Join(Backends, ", "), c.Runner.Backend))
+	}
+	if c.Runner.MaxRetries != nil && *c.Runner.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("runner.max_retries must not be negative; 0 disables retrying"))
+	}
+	if d, err := time.ParseDuration(c.Runner.RetryBackoff); err != nil || d <= 0 {
+		errs = append(errs, fmt.Errorf("runner.retry_backoff must be a duration like 10s, got %q", c.Runner.RetryBackoff))
+	}
+	errs = append(errs, validateGlobs("commit_guard.allowed_paths", c.CommitGuard.AllowedPaths)...)
+	errs = append(errs, validateGlobs("protected_paths", c.Protected)...)
+	for _, lintCmd := range c.LintCommands {
+		if strings.TrimSpace(lintCmd) == "" {
+			errs = append(errs, fmt.Errorf("lint_commands must not contain empty commands"))
+			break
+		}
+	}
+	if err := c.Phases.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// validateGlobs checks that every pattern of a path glob list parses
+func validateGlobs(key string, patterns []string) []error {
+	var errs []error
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil || strings.TrimSpace(pattern) == "" {
+			errs = append(errs, fmt.Errorf("%s has an invalid glob %q", key, pattern))
+		}
+	}
+	return errs
+}
+
+// PruneEvery returns the number of TODOs between auto-pruning, 0 if disabled
+func (c *ProjectConfig) PruneEvery() int {
+	if c.PruneInterval < 0 {
+		return 0
+	}
+	return c.PruneInterval
+}
+
+// CheckTimeout returns the longest a test or lint command may run, 0 for no limit
+func (c *ProjectConfig) CheckTimeout() time.Duration {
+	d, _ := time.ParseDuration(c.TestTimeout)
+	return d
+}
+
+// Retries returns how many times a session that failed with a transient API
+// error is retried, 0 if retrying is disabled
+func (r Runner) Retries() int {
+	if r.MaxRetries == nil {
+		return DefaultMaxRetries
+	}
+	return max(*r.MaxRetries, 0)
+}
+
+// Backoff returns the wait before the first retry of a failed session
+func (r Runner) Backoff() time.Duration {
+	d, _ := time.ParseDuration(r.RetryBackoff)
+	return d
+}
+
+// StateBudget converts the budget for the engine, nil if no limit is set
+func (b Budget) StateBudget() *state.Budget {
+	sb := &state.Budget{
+		MaxCostUSD:  b.MaxCost,
+		MaxTokens:   b.MaxTokens,
+		MaxSessions: b.MaxSessions,
+	}
+	if d, err := time.ParseDuration(b.MaxTime); err == nil {
+		sb.MaxSeconds = int64(d / time.Second)
+	}
+	if sb.IsZero() {
+		return nil
+	}
+	return sb
+}
+
+// Policy converts the commit guard settings for the engine. The loop's own
+// files are always allowed.
+func (g CommitGuard) Policy() guard.Policy {
+	p := guard.Policy{}
+	if g.MaxFileKB > 0 {
+		p.MaxFileBytes = int64(g.MaxFileKB) * 1024
+	}
+	if len(g.AllowedPaths) > 0 {
+		p.AllowedPaths = append(slices.Clone(g.AllowedPaths), AutoclaudeDir+"/**")
+	}
+	return p
+}
+
+// configKey is a dotted config.yaml key that can be read and set as a string
+type configKey struct {
+	name string
+	get  func(*ProjectConfig) string
+	set  func(*ProjectConfig, string) error
+}
+
+// configKeys lists every settable key
+func configKeys() []configKey {
+	keys := []configKey{
+		intKey("retry_limit", func(c *ProjectConfig) *int { return &c.RetryLimit }),
+		intKey("prune_interval", func(c *ProjectConfig) *int { return &c.PruneInterval }),
+		intKey("parallel", func(c *ProjectConfig) *int { return &c.Parallel }),
+		boolKey("branch_per_todo", func(c *ProjectConfig) *bool { return &c.BranchPerTodo }),
+		stringKey("on_exhausted", func(c *ProjectConfig) *string { return &c.OnExhausted }),
+		{
+			name: "budget.max_cost",
+			get:  func(c *ProjectConfig) string { return strconv.FormatFloat(c.Budget.MaxCost, 'f', -1, 64) },
+			set: func(c *ProjectConfig, v string) error {
+				if strings.TrimSpace(v) == "" {
+					c.Budget.MaxCost = 0
+					return nil
+				}
+				f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+				if err != nil {
+					return fmt.Errorf("expected a number, got %q", v)
+				}
+				c.Budget.MaxCost = f
+				return nil
+			},
+		},
+		intKey("budget.max_tokens", func(c *ProjectConfig) *int { return &c.Budget.MaxTokens }),
+		stringKey("budget.max_time", func(c *ProjectConfig) *string { return &c.Budget.MaxTime }),
+		intKey("budget.max_sessions", func(c *ProjectConfig) *int { return &c.Budget.MaxSessions }),
+		intKey("commit_guard.max_file_kb", func(c *ProjectConfig) *int { return &c.CommitGuard.MaxFileKB }),
+		listKey("commit_guard.allowed_paths", func(c *ProjectConfig) *[]string { return &c.CommitGuard.AllowedPaths }),
+		stringKey("runner.backend", func(c *ProjectConfig) *string { return &c.Runner.Backend }),
+		stringKey("runner.command", func(c *ProjectConfig) *string { return &c.Runner.Command }),
+		optionalIntKey("runner.max_retries", func(c *ProjectConfig) **int { return &c.Runner.MaxRetries }),
+		stringKey("runner.retry_backoff", func(c *ProjectConfig) *string { return &c.Runner.RetryBackoff }),
+		listKey("protected_paths", func(c *ProjectConfig) *[]string { return &c.Protected }),
+		listKey("lint_commands", func(c *ProjectConfig) *[]string { return &c.LintCommands }),
+		stringKey("test_timeout", func(c *ProjectConfig) *string { return &c.TestTimeout }),
+	}
+
+	for _, phase := range state.Phases {
+		keys = append(keys,
+			phaseKey(phase, "model", func(pc *PhaseConfig) any { return &pc.Model }),
+			phaseKey(phase, "permission_mode", func(pc *PhaseConfig) any { return &pc.PermissionMode }),
+			phaseKey(phase, "max_turns", func(pc *PhaseConfig) any { return &pc.MaxTurns }),
+			phaseKey(phase, "extra_args", func(pc *PhaseConfig) any { return &pc.ExtraArgs }),
+			phaseKey(phase, "timeout", func(pc *PhaseConfig) any { return &pc.Timeout }),
+			phaseKey(phase, "idle_timeout", func(pc *PhaseConfig) any { return &pc.IdleTimeout }),
+		)
+	}
+	return keys
+}
+
+// Keys returns the names of all settable keys
+func Keys() []string {
+	var names []string
+	for _, key := range configKeys() {
+		names = append(names, key.name)
+	}
+	return names
+}
+
+// EnvVar returns the environment variable that overrides a key
+func EnvVar(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Get returns a key's value as a string. Lists are returned one item per line.
+func (c *ProjectConfig) Get(name string) (string, error) {
+	if name == "version" {
+		return strconv.Itoa(c.Version), nil
+	}
+	key, err := findKey(name)
+	if err != nil {
+		return "", err
+	}
+	return key.get(c), nil
+}
+
+// Set parses and sets a key's value. Lists accept a YAML flow sequence such
+// as ["go vet ./...", "golangci-lint run"], or a single item.
+func (c *ProjectConfig) Set(name, value string) error {
+	key, err := findKey(name)
+	if err != nil {
+		return err
+	}
+	if err := key.set(c, value); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+	return nil
+}
+
+func findKey(name string) (configKey, error) {
+	keys := configKeys()
+	idx := slices.IndexFunc(keys, func(k configKey) bool { return k.name == name })
+	if idx < 0 {
+		return configKey{}, fmt.Errorf("unknown config key %q (run 'autoclaude config get' to list keys)", name)
+	}
+	return keys[idx], nil
+}
+
+func intKey(name string, field func(*ProjectConfig) *int) configKey {
+	return configKey{
+		name: name,
+		get:  func(c *ProjectConfig) string { return strconv.Itoa(*field(c)) },
+		set: func(c *ProjectConfig, v string) error {
+			n, err := parseInt(v)
+			if err != nil {
+				return err
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+// intPtr returns a pointer to a copy of n
+func intPtr(n int) *int {
+	return &n
+}
+
+// optionalIntKey is an intKey whose 0 is kept apart from being unset, which
+// an empty value restores
+func optionalIntKey(name string, field func(*ProjectConfig) **int) configKey {
+	return configKey{
+		name: name,
+		get: func(c *ProjectConfig) string {
+			if *field(c) == nil {
+				return ""
+			}
+			return strconv.Itoa(**field(c))
+		},
+		set: func(c *ProjectConfig, v string) error {
+			if strings.TrimSpace(v) == "" {
+				*field(c) = nil
+				return nil
+			}
+			n, err := parseInt(v)
+			if err != nil {
+				return err
+			}
+			*field(c) = &n
+			return nil
+		},
+	}
+}
+
+func boolKey(name string, field func(*ProjectConfig) *bool) configKey {
+	return configKey{
+		name: name,
+		get:  func(c *ProjectConfig) string { return strconv.FormatBool(*field(c)) },
+		set: func(c *ProjectConfig, v string) error {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				*field(c) = false
+				return nil
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			*field(c) = b
+			return nil
+		},
+	}
+}
+
+func stringKey(name string, field func(*ProjectConfig) *string) configKey {
+	return configKey{
+		name: name,
+		get:  func(c *ProjectConfig) string { return *field(c) },
+		set: func(c *ProjectConfig, v string) error {
+			*field(c) = strings.TrimSpace(v)
+			return nil
+		},
+	}
+}
+
+func listKey(name string, field func(*ProjectConfig) *[]string) configKey {
+	return configKey{
+		name: name,
+		get:  func(c *ProjectConfig) string { return strings.Join(*field(c), "\n") },
+		set: func(c *ProjectConfig, v string) error {
+			list, err := parseList(v)
+			if err != nil {
+				return err
+			}
+			*field(c) = list
+			return nil
+		},
+	}
+}
+
+// phaseKey builds a phases.<phase>.<field> key. field returns a pointer to a
+// string, int or []string field of the phase's configuration.
+func phaseKey(phase state.Phase, name string, field func(*PhaseConfig) any) configKey {
+	return configKey{
+		name: fmt.Sprintf("phases.%s.%s", phase, name),
+		get: func(c *ProjectConfig) string {
+			pc := c.Phases[phase]
+			switch f := field(&pc).(type) {
+			case *string:
+				return *f
+			case *int:
+				return strconv.Itoa(*f)
+			case *[]string:
+				return strings.Join(*f, "\n")
+			}
+			return ""
+		},
+		set: func(c *ProjectConfig, v string) error {
+			if c.Phases == nil {
+				c.Phases = make(Phases)
+			}
+			pc := c.Phases[phase]
+			switch f := field(&pc).(type) {
+			case *string:
+				*f = strings.TrimSpace(v)
+			case *int:
+				n, err := parseInt(v)
+				if err != nil {
+					return err
+				}
+				*f = n
+			case *[]string:
+				list, err := parseList(v)
+				if err != nil {
+					return err
+				}
+				*f = list
+			}
+			c.Phases[phase] = pc
+			return nil
+		},
+	}
+}
+
+// parseInt parses an integer value; empty means 0
+func parseInt(v string) (int, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("expected an integer, got %q", v)
+	}
+	return n, nil
+}
+
+// parseList parses a YAML flow sequence, or treats the value as a single item.
+// An empty value gives an empty list.
+func parseList(v string) ([]string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(v, "[") {
+		return []string{v}, nil
+	}
+	var list []string
+	if err := yaml.Unmarshal([]byte(v), &list); err != nil {
+		return nil, fmt.Errorf("expected a list like [\"a\", \"b\"]: %w", err)
+	}
+	return list, nil
+}
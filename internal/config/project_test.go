@@ -0,0 +1,238 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+func TestLoadProjectConfigPrecedence(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	os.MkdirAll(AutoclaudeDir, 0755)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.RetryLimit != DefaultRetryLimit || cfg.PruneEvery() != DefaultPruneInterval || cfg.Budget.StateBudget() != nil {
+		t.Errorf("unexpected defaults %+v", cfg)
+	}
+
+	os.WriteFile(ProjectConfigPath(), []byte(`version: 1
+retry_limit: 5
+prune_interval: -1
+budget:
+  max_cost: 10
+  max_time: 90m
+lint_commands:
+  - go vet ./...
+`), 0644)
+	t.Setenv("AUTOCLAUDE_RETRY_LIMIT", "7")
+	t.Setenv("AUTOCLAUDE_PHASES_CRITIC_MODEL", "opus")
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// Environment beats the file, the file beats defaults
+	if cfg.RetryLimit != 7 {
+		t.Errorf("expected retry_limit 7 from env, got %d", cfg.RetryLimit)
+	}
+	if cfg.PruneEvery() != 0 {
+		t.Errorf("prune_interval -1 should disable pruning, got %d", cfg.PruneEvery())
+	}
+	if got := cfg.Phases.For(state.PhaseCritic); got.Model != "opus" || got.PermissionMode != DefaultPermissionMode {
+		t.Errorf("unexpected critic config %+v", got)
+	}
+	want := state.Budget{MaxCostUSD: 10, MaxSeconds: 5400}
+	if got := cfg.Budget.StateBudget(); got == nil || *got != want {
+		t.Errorf("budget = %+v, want %+v", got, want)
+	}
+	if len(cfg.LintCommands) != 1 || cfg.LintCommands[0] != "go vet ./..." {
+		t.Errorf("unexpected lint commands %v", cfg.LintCommands)
+	}
+
+	// A flag is applied last with Set
+	if err := cfg.Set("retry_limit", "2"); err != nil || cfg.RetryLimit != 2 {
+		t.Errorf("Set failed: %v (retry_limit %d)", err, cfg.RetryLimit)
+	}
+}
+
+func TestLoadProjectConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     string
+		wantErr string
+	}{
+		{"newer version", "version: 2\n", "", "newer than this autoclaude supports"},
+		{"zero retries", "retry_limit: 0\n", "", "retry_limit"},
+		{"bad duration", "budget:\n  max_time: forever\n", "", "budget.max_time"},
+		{"bad exhausted policy", "on_exhausted: delete\n", "", "on_exhausted must be one of keep, revert, stash"},
+		{"bad backend", "runner:\n  backend: docker\n", "", "runner.backend must be one of interactive, headless, tmux"},
+		{"bad max retries", "runner:\n  max_retries: -2\n", "", "runner.max_retries"},
+		{"bad retry backoff", "runner:\n  retry_backoff: soon\n", "", "runner.retry_backoff must be a duration"},
+		{"bad test timeout", "test_timeout: -5m\n", "", "test_timeout must be a duration"},
+		{"bad protected path", "protected_paths: [\"\"]\n", "", "protected_paths has an invalid glob"},
+		{"bad allowed path", "commit_guard:\n  allowed_paths: [\"src/[\"]\n", "", "commit_guard.allowed_paths has an invalid glob"},
+		{"bad yaml", "retry_limit: [\n", "", "failed to parse"},
+		{"bad env", "", "lots", "AUTOCLAUDE_BUDGET_MAX_COST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			oldDir, _ := os.Getwd()
+			os.Chdir(tmpDir)
+			defer os.Chdir(oldDir)
+
+			os.MkdirAll(AutoclaudeDir, 0755)
+			os.WriteFile(ProjectConfigPath(), []byte(tt.content), 0644)
+			if tt.env != "" {
+				t.Setenv("AUTOCLAUDE_BUDGET_MAX_COST", tt.env)
+			}
+
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProjectConfigGetSet(t *testing.T) {
+	cfg := Default()
+
+	tests := []struct {
+		key   string
+		value string
+		want  string
+	}{
+		{"budget.max_cost", "12.5", "12.5"},
+		{"budget.max_time", "2h", "2h"},
+		{"branch_per_todo", "true", "true"},
+		{"on_exhausted", "stash", "stash"},
+		{"commit_guard.max_file_kb", "-1", "-1"},
+		{"runner.backend", "tmux", "tmux"},
+		{"runner.command", "my-agent", "my-agent"},
+		{"commit_guard.allowed_paths", `["src/**", "go.mod"]`, "src/**\ngo.mod"},
+		{"protected_paths", `["migrations/**", "go.sum"]`, "migrations/**\ngo.sum"},
+		{"lint_commands", `["go vet ./...", "golangci-lint run"]`, "go vet ./...\ngolangci-lint run"},
+		{"phases.coder.model", "sonnet", "sonnet"},
+		{"phases.coder.max_turns", "40", "40"},
+		{"phases.fixer.extra_args", "--verbose", "--verbose"},
+		{"phases.coder.idle_timeout", "10m", "10m"},
+	}
+	for _, tt := range tests {
+		if err := cfg.Set(tt.key, tt.value); err != nil {
+			t.Errorf("Set(%q) failed: %v", tt.key, err)
+			continue
+		}
+		if got, _ := cfg.Get(tt.key); got != tt.want {
+			t.Errorf("Get(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+
+	if err := cfg.Set("retry_limit", "three"); err == nil {
+		t.Error("expected error for non-integer retry_limit")
+	}
+	if err := cfg.Set("branch_per_todo", "maybe"); err == nil {
+		t.Error("expected error for non-boolean branch_per_todo")
+	}
+	if err := cfg.Set("no_such_key", "1"); err == nil {
+		t.Error("expected error for unknown key")
+	}
+	if got := EnvVar("phases.coder.model"); got != "AUTOCLAUDE_PHASES_CODER_MODEL" {
+		t.Errorf("EnvVar = %q", got)
+	}
+}
+
+func TestProjectConfigSaveFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	cfg, err := LoadFile()
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	cfg.Set("phases.critic.model", "opus")
+	if err := cfg.SaveFile(); err != nil {
+		t.Fatalf("SaveFile failed: %v", err)
+	}
+
+	// Only explicitly set keys are written, stamped with the version
+	data, _ := os.ReadFile(ProjectConfigPath())
+	if !strings.Contains(string(data), "version: 1") || !strings.Contains(string(data), "model: opus") {
+		t.Errorf("unexpected config.yaml:\n%s", data)
+	}
+	if strings.Contains(string(data), "retry_limit") || strings.Contains(string(data), "coder") {
+		t.Errorf("defaults should not be written:\n%s", data)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Phases.For(state.PhaseCritic).Model != "opus" || loaded.RetryLimit != DefaultRetryLimit {
+		t.Errorf("unexpected loaded config %+v", loaded)
+	}
+}
+
+func TestProjectConfigMaxRetries(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	if cfg, _ := Load(); cfg.Runner.Retries() != DefaultMaxRetries {
+		t.Errorf("expected %d retries by default, got %d", DefaultMaxRetries, cfg.Runner.Retries())
+	}
+
+	// 0 turns retrying off, and survives being written and read back
+	cfg, _ := LoadFile()
+	if err := cfg.Set("runner.max_retries", "0"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cfg.SaveFile(); err != nil {
+		t.Fatalf("SaveFile failed: %v", err)
+	}
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Runner.Retries() != 0 {
+		t.Errorf("expected max_retries: 0 to disable retrying, got %d retries", loaded.Runner.Retries())
+	}
+	if got, _ := loaded.Get("runner.max_retries"); got != "0" {
+		t.Errorf("expected max_retries to read back as 0, got %q", got)
+	}
+
+	// An empty value unsets it again
+	cfg.Set("runner.max_retries", "")
+	cfg.SaveFile()
+	if loaded, _ := Load(); loaded.Runner.Retries() != DefaultMaxRetries {
+		t.Errorf("expected unsetting max_retries to restore the default, got %d", loaded.Runner.Retries())
+	}
+}
+
+func TestCommitGuardPolicy(t *testing.T) {
+	if p := Default().CommitGuard.Policy(); p.MaxFileBytes != DefaultMaxFileKB*1024 || p.AllowedPaths != nil {
+		t.Errorf("unexpected default policy %+v", p)
+	}
+
+	p := CommitGuard{MaxFileKB: -1, AllowedPaths: []string{"src/**"}}.Policy()
+	if p.MaxFileBytes != 0 {
+		t.Errorf("expected no size limit, got %d", p.MaxFileBytes)
+	}
+	if !p.Allows("src/main.go") || !p.Allows(".autoclaude/TODO.md") || p.Allows("docs/README.md") {
+		t.Errorf("unexpected allowed paths %v", p.AllowedPaths)
+	}
+}
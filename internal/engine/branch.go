@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"go.coldcutz.net/autoclaude/internal/git"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+// startTodoBranch creates the TODO's branch from the current branch and
+// switches to it, so rejected attempts stay off the current branch. A branch
+// left from an earlier attempt is reused, rebased onto the current branch.
+func (e *Engine) startTodoBranch(t *state.Todo) error {
+	s := e.state
+	base, err := git.CurrentBranch()
+	if err != nil {
+		return fmt.Errorf("branch-per-TODO mode needs a checked-out branch: %w", err)
+	}
+
+	branch := TodoBranch(t)
+	if err := claimTodoBranch(t); err != nil {
+		return err
+	}
+	exists := git.BranchExists(branch)
+	if err := git.Checkout(branch, !exists); err != nil {
+		return fmt.Errorf("failed to switch to %s: %w", branch, err)
+	}
+	if exists {
+		if err := rebaseTodoBranch("", branch, base); err != nil {
+			git.Checkout(base, false)
+			return err
+		}
+	}
+	if err := git.SetBranchDescription(branch, t.Title); err != nil {
+		return err
+	}
+	s.BaseBranch = base
+	s.TodoBranch = branch
+	fmt.Printf("  Branch: %s (from %s)\n", branch, base)
+	return nil
+}
+
+// rebaseTodoBranch brings a TODO's branch, checked out in dir, up to date
+// with base, so that work continued on it builds on everything merged since
+// it was left. A branch that doesn't rebase cleanly is refused rather than
+// worked on from an old base.
+func rebaseTodoBranch(dir, branch, base string) error {
+	if git.IsAncestor(base, branch) {
+		return nil
+	}
+	conflicts, err := git.Rebase(dir, base)
+	if err == nil && len(conflicts) > 0 {
+		err = fmt.Errorf("conflicts in %s", strings.Join(conflicts, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("%s was left from an earlier attempt and doesn't rebase onto %s (%w); rebase or delete it", branch, base, err)
+	}
+	fmt.Printf("  Rebased %s onto %s\n", branch, base)
+	return nil
+}
+
+// mergeTodoBranch squash-merges the approved TODO's branch into the base
+// branch with a generated message and deletes it. Everything on the branch
+// must be committed.
+func (e *Engine) mergeTodoBranch() error {
+	s := e.state
+	branch, base := s.TodoBranch, s.BaseBranch
+	title := state.CurrentTodoTitle()
+
+	subjects, err := git.CommitSubjects(base, branch)
+	if err != nil {
+		return err
+	}
+	review, _ := state.LoadCriticReview()
+
+	if err := git.Checkout(base, false); err != nil {
+		return fmt.Errorf("failed to switch back to %s: %w", base, err)
+	}
+	conflicts, err := git.Merge(branch, squashMessage(title, review, subjects), true)
+	if err == nil && len(conflicts) > 0 {
+		err = fmt.Errorf("conflicts in %s", strings.Join(conflicts, ", "))
+	}
+	if err != nil {
+		// Go back so that resume retries the merge
+		git.Checkout(branch, false)
+		return fmt.Errorf("failed to squash-merge %s into %s: %w", branch, base, err)
+	}
+	fmt.Printf("  ✓ Squash-merged %s into %s as %s\n", branch, base, git.CommitHash())
+	if err := git.DeleteBranch(branch); err != nil {
+		fmt.Printf("  ⚠ Failed to delete branch %s: %v\n", branch, err)
+	}
+	s.TodoBranch, s.BaseBranch = "", ""
+	return nil
+}
+
+// leaveTodoBranch switches back to the base branch without merging the
+// TODO's branch, and marks the TODO blocked with the branch recorded on it
+func (e *Engine) leaveTodoBranch(review *state.CriticReview) error {
+	s := e.state
+	branch, base := s.TodoBranch, s.BaseBranch
+	title := state.CurrentTodoTitle()
+
+	if err := e.commitLastAttempt(title); err != nil {
+		return err
+	}
+	branchTodos, err := state.LoadTodos()
+	if err != nil {
+		return err
+	}
+	if err := git.Checkout(base, false); err != nil {
+		return fmt.Errorf("failed to switch back to %s: %w", base, err)
+	}
+
+	list, err := state.LoadTodos()
+	if err != nil {
+		return err
+	}
+	addNewTodos(list, branchTodos)
+	if t := list.Find(title); t != nil {
+		recordUnmergedBranch(t, branch, e.exhaustedReason(), review)
+	}
+	if err := list.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("  ⚠ Left %s unmerged\n", branch)
+	s.TodoBranch, s.BaseBranch = "", ""
+	return nil
+}
+
+// squashMessage builds the commit message for a squash-merged TODO
+func squashMessage(title string, review *state.CriticReview, subjects []string) string {
+	var b strings.Builder
+	b.WriteString(title + "\n")
+	if review != nil && review.Summary != "" {
+		b.WriteString("\n" + review.Summary + "\n")
+	}
+	if len(subjects) > 0 {
+		b.WriteString("\nSquashed commits:\n")
+		for _, subject := range subjects {
+			b.WriteString("- " + subject + "\n")
+		}
+	}
+	return b.String()
+}
+
+// addNewTodos adds the TODOs in from that list doesn't have yet, such as ones
+// a critic suggested on another branch
+func addNewTodos(list, from *state.TodoList) {
+	for _, t := range from.Todos {
+		if list.Find(t.Title) == nil {
+			list.Add(&state.Todo{Title: t.Title, Completion: t.Completion, Priority: t.Priority, Dependencies: t.Dependencies}, "Pending")
+		}
+	}
+}
+
+// recordUnmergedBranch marks a TODO blocked and notes the branch its work was left on
+func recordUnmergedBranch(t *state.Todo, branch, reason string, review *state.CriticReview) {
+	blockTodo(t, reason, review)
+	t.SubBullets = append(t.SubBullets, "Unmerged branch: "+branch)
+}
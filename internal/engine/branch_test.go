@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"go.coldcutz.net/autoclaude/internal/git"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+func TestEngineBranchPerTodoSquashMerges(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	base, err := git.CurrentBranch()
+	if err != nil {
+		t.Fatalf("CurrentBranch failed: %v", err)
+	}
+	fake := &fakeClaude{t: t, verdicts: []string{
+		"NEEDS_FIXES\n\nbroken",
+		"---\nverdict: APPROVED\nsummary: Parser handles every case.\n---\n",
+	}}
+
+	e := newTestEngine(s, fake)
+	e.opts.BranchPerTodo = true
+	if err := e.Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if branch, _ := git.CurrentBranch(); branch != base {
+		t.Errorf("expected to end on %s, got %s", base, branch)
+	}
+	if git.BranchExists("autoclaude/todo-first") {
+		t.Error("merged branch should be deleted")
+	}
+
+	// The coder and fixer commits are squashed into one commit on the base branch
+	log := gitIn(t, ".", "log", "--format=%s")
+	if log != "First\ninitial" {
+		t.Errorf("expected a single squashed commit, got:\n%s", log)
+	}
+	body := gitIn(t, ".", "log", "-1", "--format=%b")
+	for _, want := range []string{"Parser handles every case.", "Squashed commits:", "- coder", "- fix"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("squash message missing %q:\n%s", want, body)
+		}
+	}
+	if s.TodoBranch != "" || s.BaseBranch != "" {
+		t.Errorf("branch state should be cleared, got %q/%q", s.TodoBranch, s.BaseBranch)
+	}
+}
+
+func TestEngineBranchPerTodoLeavesExhaustedBranch(t *testing.T) {
+	s := setupProject(t, "## Pending\n- [ ] **First** - Completion: done\n", "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"NEEDS_FIXES", "NEEDS_FIXES", "NEEDS_FIXES"}}
+
+	e := newTestEngine(s, fake)
+	e.opts.BranchPerTodo = true
+	if err := e.Run(t.Context()); !errors.Is(err, ErrEscalated) {
+		t.Fatalf("expected escalation, got %v", err)
+	}
+
+	if log := gitIn(t, ".", "log", "--format=%s"); log != "initial" {
+		t.Errorf("rejected attempts should stay off the base branch, got:\n%s", log)
+	}
+	if !git.BranchExists("autoclaude/todo-first") {
+		t.Fatal("exhausted branch should be kept")
+	}
+	if commits := gitIn(t, ".", "log", "--format=%s", "HEAD..autoclaude/todo-first"); !strings.Contains(commits, "coder") {
+		t.Errorf("branch should hold the attempts, got:\n%s", commits)
+	}
+
+	list, _ := state.LoadTodos()
+	first := list.Find("First")
+	if first == nil || first.Status != state.StatusBlocked || !strings.Contains(list.String(), "Unmerged branch: autoclaude/todo-first") {
+		t.Errorf("exhausted TODO should be blocked with its branch recorded, got:\n%s", list)
+	}
+}
+
+// setupStaleTodoBranch leaves a branch for the TODO "First" from an earlier
+// attempt, then moves the base branch on. Both change file.
+func setupStaleTodoBranch(t *testing.T, file string) (base string) {
+	t.Helper()
+	base, _ = git.CurrentBranch()
+	gitIn(t, ".", "checkout", "-q", "-b", "autoclaude/todo-first")
+	os.WriteFile(file, []byte("earlier attempt\n"), 0644)
+	commitAll(t, "earlier attempt")
+	gitIn(t, ".", "checkout", "-q", base)
+	os.WriteFile("base.txt", []byte("base\n"), 0644)
+	if file == "base.txt" {
+		os.WriteFile(file, []byte("moved on\n"), 0644)
+	}
+	commitAll(t, "base moved on")
+	return base
+}
+
+func TestEngineBranchPerTodoRebasesStaleBranch(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	setupStaleTodoBranch(t, "attempt.txt")
+	fake := &fakeClaude{t: t, verdicts: []string{"---\nverdict: APPROVED\nsummary: Done.\n---\n"}}
+	fake.onCoder = func() {
+		if _, err := os.Stat("base.txt"); err != nil {
+			t.Error("the coder should work on top of the current base branch")
+		}
+		commitAll(t, "coder")
+	}
+
+	e := newTestEngine(s, fake)
+	e.opts.BranchPerTodo = true
+	if err := e.Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if log := gitIn(t, ".", "log", "--format=%s"); log != "First\nbase moved on\ninitial" {
+		t.Errorf("expected the rebased branch squashed onto the base, got:\n%s", log)
+	}
+	if !strings.Contains(gitIn(t, ".", "log", "-1", "--format=%b"), "- earlier attempt") {
+		t.Error("the earlier attempt should be kept")
+	}
+}
+
+func TestEngineBranchPerTodoRefusesConflictingStaleBranch(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	base := setupStaleTodoBranch(t, "base.txt")
+	stale := gitIn(t, ".", "rev-parse", "autoclaude/todo-first")
+
+	e := newTestEngine(s, &fakeClaude{t: t})
+	e.opts.BranchPerTodo = true
+	err := e.Run(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "doesn't rebase onto "+base) || !strings.Contains(err.Error(), "base.txt") {
+		t.Fatalf("expected the stale branch to be refused, got %v", err)
+	}
+
+	if branch, _ := git.CurrentBranch(); branch != base {
+		t.Errorf("expected to be back on %s, got %s", base, branch)
+	}
+	if got := gitIn(t, ".", "rev-parse", "autoclaude/todo-first"); got != stale {
+		t.Error("the refused branch should be left as it was")
+	}
+}
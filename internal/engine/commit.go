@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"go.coldcutz.net/autoclaude/internal/git"
+	"go.coldcutz.net/autoclaude/internal/guard"
+	"go.coldcutz.net/autoclaude/internal/prompt"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+// checkCommitCreated commits what a phase left behind if it made no commit
+// itself. If the commit guard refuses the changes, nothing is committed and
+// instructions for the fixer are returned instead.
+func (e *Engine) checkCommitCreated(before string, phase state.Phase) (string, error) {
+	if after := git.CommitHash(); after != before {
+		e.record(state.Event{Type: state.EventCommitCreated, Phase: phase, Before: before, Commit: after})
+		return "", nil
+	}
+	name := strings.ToUpper(string(phase[:1])) + string(phase[1:])
+	fmt.Printf("  ⚠ %s did not commit, forcing commit...\n", name)
+	violations, err := e.checkStaged()
+	if err != nil {
+		return "", err
+	}
+	if len(violations) > 0 {
+		return prompt.GenerateCommitGuardInstructions(problems(violations)), nil
+	}
+	git.ForceCommit(name)
+	if after := git.CommitHash(); after != before {
+		e.record(state.Event{Type: state.EventForcedCommit, Phase: phase, Before: before, Commit: after})
+	}
+	return "", nil
+}
+
+// commitAll commits every change with the given message, unless the commit
+// guard refuses them, in which case nothing is committed
+func (e *Engine) commitAll(message string) ([]guard.Violation, error) {
+	violations, err := e.checkStaged()
+	if err != nil || len(violations) > 0 {
+		return violations, err
+	}
+	before := git.CommitHash()
+	if err := git.CommitAll(message); err != nil {
+		return nil, err
+	}
+	if after := git.CommitHash(); after != before {
+		e.record(state.Event{Type: state.EventForcedCommit, Before: before, Commit: after, Message: message})
+	}
+	return nil, nil
+}
+
+// checkStaged stages every change and runs the commit guard over them. The
+// changes are unstaged again if the guard finds problems.
+func (e *Engine) checkStaged() ([]guard.Violation, error) {
+	if err := git.StageAll(); err != nil {
+		return nil, err
+	}
+	violations, err := guard.CheckStaged(e.opts.CommitGuard)
+	if err == nil && len(violations) == 0 {
+		return nil, nil
+	}
+	if unstageErr := git.Unstage(); err == nil {
+		err = unstageErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("commit guard failed: %w", err)
+	}
+
+	e.state.Stats.CommitsRefused++
+	e.record(state.Event{Type: state.EventCommitRefused, Message: strings.Join(problems(violations), "; ")})
+	fmt.Println("  ✗ Commit guard refused the changes:")
+	for _, v := range violations {
+		fmt.Printf("    - %s\n", v)
+	}
+	return violations, nil
+}
+
+// refusedError stops the loop when the guard refuses a commit that no fixer
+// can be asked to clean up
+func refusedError(what string, violations []guard.Violation) error {
+	return fmt.Errorf("commit guard refused to commit %s (%s); remove the files or secrets and resume", what, strings.Join(problems(violations), "; "))
+}
+
+// problems formats violations for messages
+func problems(violations []guard.Violation) []string {
+	var out []string
+	for _, v := range violations {
+		out = append(out, v.String())
+	}
+	return out
+}
@@ -0,0 +1,726 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/git"
+	"go.coldcutz.net/autoclaude/internal/guard"
+	"go.coldcutz.net/autoclaude/internal/prompt"
+	"go.coldcutz.net/autoclaude/internal/state"
+	"go.coldcutz.net/autoclaude/internal/testgate"
+)
+
+// ErrBudgetExceeded is returned by Run when a budget limit stopped the loop
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// ErrEscalated is returned by Run when only blocked TODOs and the ones
+// waiting on them are left, and a human has to step in
+var ErrEscalated = errors.New("blocked TODOs need attention")
+
+// ErrAuth is returned by Run when Claude could not authenticate, and a human
+// has to log in again
+var ErrAuth = errors.New("claude could not authenticate")
+
+// MaxRetryDelay caps the backoff between retries of a session that failed
+// with a transient API error
+const MaxRetryDelay = 5 * time.Minute
+
+// MaxCriticReasks is how many times the critic is asked to rewrite a verdict
+// that can't be parsed before the review counts as failed
+const MaxCriticReasks = 2
+
+// Options configures an engine run
+type Options struct {
+	AutoclaudePath string        // Path to the autoclaude binary, for stop hooks
+	Phases         config.Phases // Claude session settings for each phase
+	PruneInterval  int           // TODOs between auto-pruning, 0 to disable
+	RetryLimit     int           // Review attempts (test gate or critic) per TODO, 0 for default
+	Budget         *state.Budget // Resource limits, nil for none
+	LintCommands   []string      // Run by the test gate after the test command
+	TestTimeout    time.Duration // Longest the test gate lets a test or lint command run, 0 for no limit
+	Backend        string        // How Claude sessions are run (config.Backend*), interactive if empty
+	Command        string        // Agent CLI to run instead of claude
+	Parallel       int           // Independent TODOs to work on at once in separate worktrees, 0 or 1 for one at a time
+	SingleTodo     bool          // Stop after the current TODO instead of selecting the next one (parallel workers)
+	BranchPerTodo  bool          // Work on each TODO in its own branch and squash-merge it on approval
+	OnExhausted    string        // Keep, revert or stash the code of a TODO that runs out of review attempts (config.Exhausted*)
+	CommitGuard    guard.Policy  // What may be committed on Claude's behalf
+	MaxRetries     int           // Retries of a session that failed with a transient API error, 0 for none
+	RetryBackoff   time.Duration // Wait before the first such retry, doubled for each one after; config default if 0
+}
+
+// Engine drives the coder → test → critic → fixer → evaluator state machine.
+// Every transition is persisted to state.json, so a run can be resumed from
+// whatever step it was interrupted in.
+type Engine struct {
+	state  *state.State
+	opts   Options
+	params prompt.PromptParams
+
+	// lastTick is when elapsed time was last added to the stats
+	lastTick time.Time
+
+	// ctx stops the Claude session in progress when the run is cancelled
+	ctx context.Context
+
+	// runner runs Claude sessions; replaced in tests
+	runner claude.Runner
+
+	// runWorker works on one TODO in the given worktree; replaced in tests
+	runWorker func(dir string, out io.Writer) error
+
+	// sleep waits between retries of a failed session; replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New creates an engine for the given state
+func New(s *state.State, opts Options) *Engine {
+	if s.Stats == nil {
+		s.Stats = &state.Stats{}
+	}
+	if opts.Phases == nil {
+		opts.Phases = config.DefaultPhases()
+	}
+	if opts.RetryLimit <= 0 {
+		opts.RetryLimit = config.DefaultRetryLimit
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = config.DefaultRetryBackoff
+	}
+	s.RetryLimit = opts.RetryLimit
+	e := &Engine{
+		state: s,
+		opts:  opts,
+		params: prompt.PromptParams{
+			Goal:    s.Goal,
+			TestCmd: s.TestCmd,
+		},
+		runner: NewRunner(opts.Backend, opts.Command),
+		sleep:  sleepContext,
+	}
+	e.runWorker = e.execWorker
+	return e
+}
+
+// Run drives the loop from the persisted step until all TODOs are done and
+// the evaluator has signed off. Cancelling ctx stops the session in progress
+// and returns, leaving the step to be resumed.
+func (e *Engine) Run(ctx context.Context) (err error) {
+	e.ctx = ctx
+	// A session still running from a loop that crashed would fight this one
+	claude.StopOrphan()
+
+	// Enable stop hook for all phases (kills Claude when it stops to return control)
+	if err := config.SetupStopHook(e.opts.AutoclaudePath); err != nil {
+		return fmt.Errorf("failed to setup stop hook: %w", err)
+	}
+	defer config.RemoveStopHook(e.opts.AutoclaudePath)
+	// Deny Claude's tool calls that touch protected paths
+	if err := config.SetupPreToolUseHook(e.opts.AutoclaudePath); err != nil {
+		return fmt.Errorf("failed to setup pre-tool-use hook: %w", err)
+	}
+	defer config.RemovePreToolUseHook(e.opts.AutoclaudePath)
+
+	// The journal and history outlive resets of the working tree, so keep them out of git
+	for _, path := range []string{state.EventsPath(), state.HistoryPath()} {
+		if err := git.Exclude("/" + filepath.ToSlash(path)); err != nil {
+			fmt.Printf("  ⚠ Failed to exclude %s from git: %v\n", path, err)
+		}
+	}
+	e.record(state.Event{Type: state.EventRunStarted, Commit: git.CommitHash(), Message: "from " + string(e.state.Step)})
+	if e.state.RunID == "" {
+		// Resuming a run started before runs were kept in the history
+		e.state.RunID = state.NewRunID(time.Now())
+		e.state.RunStartedAt = time.Now()
+	}
+	e.saveRun(state.OutcomeRunning)
+	defer func() { e.recordRunEnded(err) }()
+
+	e.lastTick = time.Now()
+	for e.state.Step != state.StepDone {
+		e.trackTime()
+		if reason := e.opts.Budget.Exceeded(e.state.Stats); reason != "" {
+			return e.stopForBudget(reason)
+		}
+		if err := e.step(); err != nil {
+			return err
+		}
+	}
+
+	state.ClearEscalation()
+	e.state.UpdateStatus("Complete!")
+	fmt.Println("\n=== COMPLETE ===")
+	return nil
+}
+
+// step runs the current step and persists the transition to the next one
+func (e *Engine) step() error {
+	switch e.state.Step {
+	case state.StepCoder:
+		return e.coder()
+	case state.StepTest:
+		return e.test()
+	case state.StepCritic:
+		return e.critic()
+	case state.StepFixer:
+		return e.fixer()
+	case state.StepEvaluator:
+		return e.evaluator()
+	default:
+		return fmt.Errorf("unknown step %q in state file", e.state.Step)
+	}
+}
+
+// stopForBudget stops between phases, leaving the step in place so that
+// resume can continue once the budget is raised
+func (e *Engine) stopForBudget(reason string) error {
+	fmt.Printf("\n=== STOPPED: %s ===\n", reason)
+	e.record(state.Event{Type: state.EventBudgetHit, Message: reason})
+	e.state.UpdateStatus(fmt.Sprintf("Stopped: %s. Raise the budget and run `autoclaude resume` to continue.", reason))
+	if err := e.state.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return fmt.Errorf("%w: %s", ErrBudgetExceeded, reason)
+}
+
+// escalate stops the loop with a report on the blocked TODOs instead of
+// running the evaluator as if everything succeeded. The step stays at coder,
+// so resume selects TODOs again once they have been dealt with.
+func (e *Engine) escalate(list *state.TodoList) error {
+	blocked := list.WithStatus(state.StatusBlocked)
+	if err := state.WriteEscalation(list); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n=== NEEDS ATTENTION: %d blocked TODO(s) ===\n", len(blocked))
+	for _, t := range blocked {
+		fmt.Printf("  ⚠ %s\n", t.Title)
+	}
+	fmt.Printf("  Report: %s\n", state.EscalationPath())
+	var titles []string
+	for _, t := range blocked {
+		titles = append(titles, t.Title)
+	}
+	e.record(state.Event{Type: state.EventEscalated, Message: strings.Join(titles, "; ")})
+	e.state.UpdateStatus(fmt.Sprintf("Needs attention: %d blocked TODO(s), see %s. Unblock them and run `autoclaude resume` to continue.", len(blocked), state.EscalationPath()))
+	if err := e.state.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return fmt.Errorf("%w: %d blocked", ErrEscalated, len(blocked))
+}
+
+// trackTime adds the time since the last tick to the elapsed time stat
+func (e *Engine) trackTime() {
+	now := time.Now()
+	if !e.lastTick.IsZero() {
+		e.state.Stats.ElapsedMs += now.Sub(e.lastTick).Milliseconds()
+	}
+	e.lastTick = now
+}
+
+// transition moves to the next step and persists the state
+func (e *Engine) transition(next state.Step) error {
+	e.state.Step = next
+	e.trackTime()
+	if err := e.state.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// coder selects a TODO if none is in progress and runs the coder on it
+func (e *Engine) coder() error {
+	s := e.state
+
+	currentTodo := state.GetCurrentTodo()
+	if currentTodo == "(unknown)" {
+		if e.opts.SingleTodo {
+			return e.transition(state.StepDone)
+		}
+		if !state.HasIncompleteTodos() {
+			list, err := state.LoadTodos()
+			if err != nil {
+				return err
+			}
+			if len(list.WithStatus(state.StatusBlocked)) > 0 {
+				return e.escalate(list)
+			}
+			return e.transition(state.StepEvaluator)
+		}
+
+		if e.opts.Parallel > 1 {
+			list, err := state.LoadTodos()
+			if err != nil {
+				return err
+			}
+			ready, err := list.Ready()
+			if err != nil {
+				return fmt.Errorf("failed to select next TODO: %w", err)
+			}
+			if batch := batchTodos(ready, e.opts.Parallel); len(batch) > 1 {
+				return e.runBatch(list, batch)
+			}
+		}
+
+		// Get next TODO and save it as current (before coder checks it off)
+		next, err := state.NextTodo()
+		if err != nil {
+			return fmt.Errorf("failed to select next TODO: %w", err)
+		}
+		if e.opts.BranchPerTodo {
+			if err := e.startTodoBranch(next); err != nil {
+				return err
+			}
+		}
+		currentTodo = next.Header()
+		state.SetCurrentTodo(currentTodo)
+		state.ClearCriticVerdict()
+		s.TodoStartCommit = git.CommitHash()
+		s.Iteration++
+		s.RetryCount = 0
+		s.FixInstructions = ""
+		s.Stats.TodosAttempted++
+		e.record(state.Event{Type: state.EventTodoStarted, Commit: s.TodoStartCommit, Message: next.Title})
+		if err := e.transition(state.StepCoder); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n=== TODO %d: CODER ===\n", s.Iteration)
+	fmt.Printf("  Working on: %s\n", currentTodo)
+	s.UpdateStatus(fmt.Sprintf("Working on: %s", currentTodo))
+
+	commitBefore := git.CommitHash()
+	coderPrompt, err := prompt.LoadCoder()
+	if err != nil {
+		return err
+	}
+	if err := e.runPhase(state.PhaseCoder, prompt.AppendCurrentTodo(coderPrompt, currentTodo)); err != nil {
+		if !errors.Is(err, claude.ErrTimeout) {
+			return fmt.Errorf("coder phase failed: %w", err)
+		}
+		fmt.Printf("  ✗ Coder: %v (retry %d/%d)\n", err, s.RetryCount+1, e.opts.RetryLimit)
+		return e.requestFix(prompt.GenerateTimeoutInstructions(err.Error(), ""))
+	}
+	instructions, err := e.checkCommitCreated(commitBefore, state.PhaseCoder)
+	if err != nil {
+		return err
+	}
+	if instructions != "" {
+		fmt.Printf("  ✗ Skipping tests (retry %d/%d)\n", s.RetryCount+1, e.opts.RetryLimit)
+		return e.requestFix(instructions)
+	}
+
+	return e.transition(state.StepTest)
+}
+
+// test runs the test command and lint commands itself instead of trusting
+// Claude's claims. A failing check skips the critic and goes straight to the fixer.
+func (e *Engine) test() error {
+	s := e.state
+
+	if strings.TrimSpace(s.TestCmd) != "" {
+		fmt.Printf("  Running tests: %s\n", s.TestCmd)
+		s.UpdateStatus("Running tests...")
+
+		result, err := e.runCheck(s.TestCmd)
+		if err != nil {
+			return err
+		}
+		s.Stats.TestRuns++
+		e.recordCheck(result)
+		if !result.Passed() {
+			s.Stats.TestFailures++
+			fmt.Printf("  ✗ Tests failed with exit code %d (output in %s)\n", result.ExitCode, testgate.OutputPath())
+			fmt.Printf("  ✗ Skipping critic (retry %d/%d)\n", s.RetryCount+1, e.opts.RetryLimit)
+			return e.requestFix(prompt.GenerateTestFailureInstructions(s.TestCmd, result.ExitCode, result.Tail(), testgate.OutputPath()))
+		}
+		fmt.Printf("  ✓ Tests passed (%.1fs)\n", float64(result.DurationMs)/1000)
+	}
+
+	for _, lintCmd := range e.opts.LintCommands {
+		fmt.Printf("  Running lint: %s\n", lintCmd)
+		s.UpdateStatus("Running lint...")
+
+		result, err := e.runCheck(lintCmd)
+		if err != nil {
+			return err
+		}
+		s.Stats.LintRuns++
+		e.recordCheck(result)
+		if !result.Passed() {
+			s.Stats.LintFailures++
+			fmt.Printf("  ✗ Lint failed with exit code %d (output in %s)\n", result.ExitCode, testgate.OutputPath())
+			fmt.Printf("  ✗ Skipping critic (retry %d/%d)\n", s.RetryCount+1, e.opts.RetryLimit)
+			return e.requestFix(prompt.GenerateLintFailureInstructions(lintCmd, result.ExitCode, result.Tail(), testgate.OutputPath()))
+		}
+		fmt.Printf("  ✓ Lint passed (%.1fs)\n", float64(result.DurationMs)/1000)
+	}
+
+	return e.transition(state.StepCritic)
+}
+
+// runCheck runs a test or lint command and saves its output for the fixer
+func (e *Engine) runCheck(command string) (*testgate.Result, error) {
+	result, err := testgate.Run(e.ctx, command, e.opts.TestTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("test gate failed: %w", err)
+	}
+	if result.TimedOut {
+		fmt.Printf("  ✗ Killed after running longer than %s\n", e.opts.TestTimeout)
+	}
+	if err := result.Save(); err != nil {
+		return nil, fmt.Errorf("test gate failed: %w", err)
+	}
+	return result, nil
+}
+
+// critic reviews the current TODO and acts on the verdict
+func (e *Engine) critic() error {
+	s := e.state
+
+	fmt.Printf("=== TODO %d: CRITIC (attempt %d/%d) ===\n", s.Iteration, s.RetryCount+1, e.opts.RetryLimit)
+	s.UpdateStatus("Running critic review...")
+
+	state.ClearCriticVerdict()
+
+	criticPrompt, err := prompt.LoadCritic()
+	if err != nil {
+		return err
+	}
+	if err := e.runPhase(state.PhaseCritic, criticPrompt); err != nil {
+		return e.criticFailed(err)
+	}
+
+	// Ask the critic to rewrite a verdict we can't parse before giving up on it
+	review, err := state.LoadCriticReview()
+	for attempt := 1; err != nil && attempt <= MaxCriticReasks; attempt++ {
+		fmt.Printf("  ? Critic verdict unreadable (%v), asking again (%d/%d)\n", err, attempt, MaxCriticReasks)
+		if err := e.runPhase(state.PhaseCritic, prompt.GenerateCriticReask(err, state.CriticVerdictPath())); err != nil {
+			return e.criticFailed(err)
+		}
+		review, err = state.LoadCriticReview()
+	}
+	if err != nil {
+		fmt.Printf("  ? Critic: No clear verdict (%v), assuming needs review\n", err)
+		e.record(state.Event{Type: state.EventVerdict, Phase: state.PhaseCritic, Message: "unreadable: " + err.Error()})
+		return e.retryReview()
+	}
+
+	if added, err := state.AddSuggestedTodos(review.SuggestedTodos); err != nil {
+		fmt.Printf("  ⚠ Failed to add suggested TODOs: %v\n", err)
+	} else if added > 0 {
+		fmt.Printf("  + Added %d suggested TODO(s)\n", added)
+	}
+
+	e.record(state.Event{Type: state.EventVerdict, Phase: state.PhaseCritic, Verdict: review.Verdict, Message: review.Summary})
+	switch review.Verdict {
+	case state.VerdictApproved:
+		fmt.Println("  ✓ Critic: APPROVED")
+		s.Stats.CriticApprovals++
+		return e.completeTodo()
+
+	case state.VerdictMinorIssues:
+		fmt.Println("  ✓ Critic: MINOR_ISSUES (added to TODOs for later)")
+		s.Stats.CriticMinor++
+		return e.completeTodo()
+
+	default: // NEEDS_FIXES
+		fmt.Printf("  ✗ Critic: NEEDS_FIXES (retry %d/%d)\n", s.RetryCount+1, e.opts.RetryLimit)
+		s.Stats.CriticRejections++
+		return e.requestFix(review.FixInstructions())
+	}
+}
+
+// criticFailed handles a critic session that failed. One that timed out
+// counts as a review without a verdict; anything else stops the loop.
+func (e *Engine) criticFailed(err error) error {
+	if !errors.Is(err, claude.ErrTimeout) {
+		return fmt.Errorf("critic phase failed: %w", err)
+	}
+	fmt.Printf("  ? Critic: %v, assuming needs review\n", err)
+	return e.retryReview()
+}
+
+// retryReview counts a review that gave no verdict as a failed attempt and
+// runs the test gate and critic again, or gives up on the TODO if retries
+// are exhausted
+func (e *Engine) retryReview() error {
+	if e.state.RetryCount < e.opts.RetryLimit-1 {
+		e.state.RetryCount++
+		return e.transition(state.StepTest)
+	}
+	return e.exhaustTodo()
+}
+
+// fixer addresses the pending fix instructions, then goes back to the test gate
+func (e *Engine) fixer() error {
+	s := e.state
+
+	fmt.Println("=== FIXER ===")
+	s.UpdateStatus(fmt.Sprintf("Fixing: %s", state.GetCurrentTodo()))
+	s.Stats.FixAttempts++
+
+	fixerCommitBefore := git.CommitHash()
+	// Use state.GetCurrentTodo() to read from file (robust across restarts)
+	fixerPrompt := prompt.GenerateFixer(e.params, s.FixInstructions, state.GetCurrentTodo())
+	if err := e.runPhase(state.PhaseFixer, fixerPrompt); err != nil {
+		if !errors.Is(err, claude.ErrTimeout) {
+			return fmt.Errorf("fixer phase failed: %w", err)
+		}
+		instructions := prompt.GenerateTimeoutInstructions(err.Error(), s.FixInstructions)
+		s.FixInstructions = ""
+		s.RetryCount++
+		fmt.Printf("  ✗ Fixer: %v (retry %d/%d)\n", err, s.RetryCount+1, e.opts.RetryLimit)
+		return e.requestFix(instructions)
+	}
+	instructions, err := e.checkCommitCreated(fixerCommitBefore, state.PhaseFixer)
+	if err != nil {
+		return err
+	}
+
+	s.FixInstructions = ""
+	s.RetryCount++
+	if instructions != "" {
+		fmt.Printf("  ✗ Skipping tests (retry %d/%d)\n", s.RetryCount+1, e.opts.RetryLimit)
+		return e.requestFix(instructions)
+	}
+	return e.transition(state.StepTest)
+}
+
+// evaluator runs the final check; it may add TODOs, which sends the loop back to the coder
+func (e *Engine) evaluator() error {
+	s := e.state
+
+	fmt.Println("\n=== EVALUATOR ===")
+	s.UpdateStatus("Running evaluator...")
+
+	// Clean up any stale evaluation_complete file
+	config.RemoveEvaluationComplete()
+
+	// Set up evaluator stop hook (only kills Claude when evaluation_complete file exists)
+	if err := config.SetupEvaluatorStopHook(e.opts.AutoclaudePath); err != nil {
+		return fmt.Errorf("failed to setup evaluator stop hook: %w", err)
+	}
+
+	evalPrompt, err := prompt.LoadEvaluator()
+	if err == nil {
+		err = e.runPhase(state.PhaseEvaluator, evalPrompt)
+	}
+
+	// Clean up hook and marker
+	config.RemoveEvaluatorStopHook(e.opts.AutoclaudePath)
+	config.RemoveEvaluationComplete()
+
+	if err != nil {
+		return fmt.Errorf("evaluator phase failed: %w", err)
+	}
+
+	// Check if evaluator added more TODOs (user requested more work)
+	if state.HasIncompleteTodos() {
+		fmt.Println("User requested more work. Continuing...")
+		return e.transition(state.StepCoder)
+	}
+
+	return e.transition(state.StepDone)
+}
+
+// requestFix schedules the fixer with the given instructions, or gives up on
+// the TODO if retries are exhausted
+func (e *Engine) requestFix(instructions string) error {
+	if e.state.RetryCount >= e.opts.RetryLimit-1 {
+		return e.exhaustTodo()
+	}
+	e.state.FixInstructions = instructions
+	return e.transition(state.StepFixer)
+}
+
+// completeTodo records an approved TODO and moves on to the next one
+func (e *Engine) completeTodo() error {
+	s := e.state
+	if s.TodoBranch != "" {
+		violations, err := e.commitAll(fmt.Sprintf("autoclaude: finish %s", state.CurrentTodoTitle()))
+		if err != nil {
+			return err
+		}
+		if len(violations) > 0 {
+			return e.requestFix(prompt.GenerateCommitGuardInstructions(problems(violations)))
+		}
+		if err := e.mergeTodoBranch(); err != nil {
+			return err
+		}
+	}
+	s.Stats.TodosCompleted++
+	if s.RetryCount > 0 {
+		s.Stats.FixSuccesses++
+	}
+	e.record(state.Event{Type: state.EventTodoCompleted, Commit: git.CommitHash(), Message: state.CurrentTodoTitle()})
+	if err := e.finishTodo(); err != nil {
+		return err
+	}
+
+	// Check if we need to run periodic pruning
+	interval := e.opts.PruneInterval
+	if interval > 0 && s.Stats.TodosCompleted > 0 && s.Stats.TodosCompleted%interval == 0 {
+		s.TodosSincePrune = 0
+		s.LastPruneAt = time.Now().Unix()
+		if err := s.Save(); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+		if err := e.prune(); err != nil {
+			fmt.Printf("  ⚠ Pruning failed: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// exhaustTodo gives up on the current TODO after too many failed reviews and
+// marks it blocked with the critic's last feedback
+func (e *Engine) exhaustTodo() error {
+	fmt.Printf("  ⚠ Max retries (%d) reached for TODO %d, marking it blocked\n", e.opts.RetryLimit, e.state.Iteration)
+	e.record(state.Event{Type: state.EventTodoBlocked, Message: e.exhaustedReason()})
+	review, _ := state.LoadCriticReview()
+	if e.state.TodoBranch != "" {
+		// The attempt is already off the base branch
+		if err := e.leaveTodoBranch(review); err != nil {
+			return err
+		}
+	} else if err := e.blockCurrentTodo(review); err != nil {
+		return err
+	}
+	return e.finishTodo()
+}
+
+// finishTodo clears the current TODO so the coder step selects the next one,
+// or ends the run if this engine only works on a single TODO
+func (e *Engine) finishTodo() error {
+	state.ClearCurrentTodo()
+	e.state.RetryCount = 0
+	e.state.FixInstructions = ""
+	e.state.TodoStartCommit = ""
+	if e.opts.SingleTodo {
+		return e.transition(state.StepDone)
+	}
+	return e.transition(state.StepCoder)
+}
+
+// prune runs the TODO pruner between TODOs
+func (e *Engine) prune() error {
+	fmt.Println("\n=== Running Periodic TODO Pruning ===")
+	e.record(state.Event{Type: state.EventPrune, Message: fmt.Sprintf("after %d completed TODOs", e.state.Stats.TodosCompleted)})
+
+	prunerPrompt := prompt.GeneratePruner(e.params)
+	if err := e.runPhase(state.PhasePruner, prunerPrompt); err != nil {
+		return fmt.Errorf("pruner phase failed: %w", err)
+	}
+
+	fmt.Println("  ✓ Pruning complete")
+	return nil
+}
+
+// runPhase writes the prompt, runs a Claude session in the foreground with the
+// phase's settings and records its usage against the phase and current TODO.
+// A session that fails with a transient API error is run again after a
+// backoff, up to MaxRetries times, and one that can't authenticate returns
+// ErrAuth.
+func (e *Engine) runPhase(phase state.Phase, content string) error {
+	promptPath, err := prompt.WriteCurrentPrompt(content)
+	if err != nil {
+		return err
+	}
+	for retry := 1; ; retry++ {
+		result, err := e.runSession(phase, promptPath)
+		switch claude.Classify(result, err) {
+		case claude.FailureAuth:
+			return fmt.Errorf("%w: %v", ErrAuth, err)
+		case claude.FailureTransient:
+			if retry > e.opts.MaxRetries {
+				if e.opts.MaxRetries > 0 {
+					err = fmt.Errorf("%w (gave up after %d retries)", err, e.opts.MaxRetries)
+				}
+				return err
+			}
+		default:
+			return err
+		}
+
+		delay := e.backoff(retry)
+		e.state.Stats.SessionRetries++
+		fmt.Printf("  ⟳ Claude API error, retrying in %s (%d/%d): %v\n", delay.Round(time.Second), retry, e.opts.MaxRetries, err)
+		e.record(state.Event{Type: state.EventRetry, Phase: phase, Message: fmt.Sprintf("retry %d/%d in %s: %v", retry, e.opts.MaxRetries, delay.Round(time.Second), err)})
+		if err := e.sleep(e.ctx, delay); err != nil {
+			return fmt.Errorf("stopped waiting to retry: %w", err)
+		}
+	}
+}
+
+// runSession runs one Claude session for a phase
+func (e *Engine) runSession(phase state.Phase, promptPath string) (*claude.SessionResult, error) {
+	e.state.Stats.ClaudeRuns++
+	pc := e.opts.Phases.For(phase)
+	started := state.Event{Type: state.EventPhaseStarted, Phase: phase, Model: pc.Model, Before: git.CommitHash()}
+	e.record(started)
+
+	start := time.Now()
+	result, err := claude.RunWithPromptFile(e.ctx, e.runner, promptPath, pc.SessionOptions())
+	if result != nil {
+		e.recordUsage(phase, result)
+	}
+	if err == nil && result.IsError {
+		err = fmt.Errorf("claude reported an error: %s", result.ErrorMessage())
+	}
+	e.recordPhaseEnded(started, time.Since(start), result, err)
+	if errors.Is(err, claude.ErrTimeout) {
+		e.record(state.Event{Type: state.EventTimeout, Phase: phase, Message: err.Error()})
+	}
+	return result, err
+}
+
+// backoff returns how long to wait before a retry: RetryBackoff doubled for
+// each retry before it, capped at MaxRetryDelay, with up to half of it
+// randomized so that loops hitting the same rate limit don't retry in step
+func (e *Engine) backoff(retry int) time.Duration {
+	delay := e.opts.RetryBackoff
+	for i := 1; i < retry && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, MaxRetryDelay)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// sleepContext waits for d, or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// recordUsage adds a session's tokens and cost to the stats and persists them
+func (e *Engine) recordUsage(phase state.Phase, result *claude.SessionResult) {
+	e.state.Stats.RecordUsage(phase, state.CurrentTodoTitle(), state.Usage{
+		Sessions:            1,
+		InputTokens:         result.Usage.InputTokens,
+		OutputTokens:        result.Usage.OutputTokens,
+		CacheCreationTokens: result.Usage.CacheCreationInputTokens,
+		CacheReadTokens:     result.Usage.CacheReadInputTokens,
+		CostUSD:             result.CostUSD,
+		Estimated:           result.Estimated,
+	})
+	if err := e.state.Save(); err != nil {
+		fmt.Printf("  ⚠ Failed to save usage: %v\n", err)
+	}
+}
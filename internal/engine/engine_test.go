@@ -0,0 +1,669 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/prompt"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+// fakeClaude stands in for Claude sessions, identifying the phase from the prompt
+type fakeClaude struct {
+	t        *testing.T
+	verdicts []string // critic verdicts to write, in order
+	phases   []string // phases run, in order
+	onFixer  func()
+	onCoder  func() // replaces the coder's commit if set
+	timeOut  string // the first session whose prompt contains this times out
+	fixes    []string
+	failures []string // API errors the first sessions fail with, in order
+}
+
+func (f *fakeClaude) run(_ context.Context, content string, _ claude.SessionOptions) (*claude.SessionResult, error) {
+	if len(f.failures) > 0 {
+		msg := f.failures[0]
+		f.failures = f.failures[1:]
+		f.phases = append(f.phases, "failed")
+		return &claude.SessionResult{APIError: msg}, fmt.Errorf("claude exited with error: exit status 1: %s", msg)
+	}
+	if f.timeOut != "" && strings.Contains(content, f.timeOut) {
+		f.timeOut = ""
+		f.phases = append(f.phases, "timeout")
+		return &claude.SessionResult{}, fmt.Errorf("claude session stopped: %w", fmt.Errorf("%w after 1m0s", claude.ErrTimeout))
+	}
+
+	switch {
+	case strings.Contains(content, "You are fixing issues"):
+		f.phases = append(f.phases, "fixer")
+		f.fixes = append(f.fixes, content)
+		if f.onFixer != nil {
+			f.onFixer()
+		}
+		commitAll(f.t, "fix")
+	case strings.Contains(content, "code reviewer"), strings.Contains(content, "could not read your verdict"):
+		f.phases = append(f.phases, "critic")
+		if len(f.verdicts) == 0 {
+			f.t.Fatal("critic ran more times than scripted")
+		}
+		os.WriteFile(state.CriticVerdictPath(), []byte(f.verdicts[0]), 0644)
+		f.verdicts = f.verdicts[1:]
+	case strings.Contains(content, "evaluator"):
+		f.phases = append(f.phases, "evaluator")
+		os.WriteFile(config.EvaluationCompletePath(), []byte("done"), 0644)
+	case strings.Contains(content, "TODO list pruner"):
+		f.phases = append(f.phases, "pruner")
+	default:
+		f.phases = append(f.phases, "coder")
+		list, _ := state.LoadTodos()
+		next, _ := list.Next()
+		next.Checked = true
+		list.Save()
+		if f.onCoder != nil {
+			f.onCoder()
+			break
+		}
+		commitAll(f.t, "coder")
+	}
+	return &claude.SessionResult{Usage: claude.Usage{InputTokens: 100, OutputTokens: 10}, CostUSD: 0.25}, nil
+}
+
+func commitAll(t *testing.T, msg string) {
+	t.Helper()
+	exec.Command("git", "add", "-A").Run()
+	exec.Command("git", "commit", "-m", msg).Run()
+}
+
+// setupProject creates a git repo with initialized autoclaude files
+func setupProject(t *testing.T, todos, testCmd string) *state.State {
+	t.Helper()
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	t.Cleanup(func() { os.Chdir(oldDir) })
+
+	exec.Command("git", "init").Run()
+	exec.Command("git", "config", "user.email", "test@test.com").Run()
+	exec.Command("git", "config", "user.name", "Test").Run()
+
+	if err := state.InitDir("goal", testCmd); err != nil {
+		t.Fatalf("InitDir failed: %v", err)
+	}
+	if err := prompt.SavePrompts(prompt.PromptParams{Goal: "goal", TestCmd: testCmd}); err != nil {
+		t.Fatalf("SavePrompts failed: %v", err)
+	}
+	os.WriteFile(state.TodoPath(), []byte(todos), 0644)
+
+	s := state.NewState("goal", testCmd, "", 3)
+	s.Iteration = 0
+	s.Save()
+	commitAll(t, "initial")
+	return s
+}
+
+func newTestEngine(s *state.State, fake *fakeClaude) *Engine {
+	e := New(s, Options{AutoclaudePath: "/test/autoclaude"})
+	e.runner = claude.RunnerFunc(fake.run)
+	return e
+}
+
+const twoTodos = `# TODOs
+
+## Pending
+- [ ] **First** - Completion: done
+  - Priority: high
+- [ ] **Second** - Completion: done
+  - Priority: low
+`
+
+func TestEngineFullRun(t *testing.T) {
+	s := setupProject(t, twoTodos, "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"NEEDS_FIXES\n\nbroken", "APPROVED", "MINOR_ISSUES"}}
+
+	if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := "coder critic fixer critic coder critic evaluator"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+
+	if s.Step != state.StepDone {
+		t.Errorf("expected step done, got %q", s.Step)
+	}
+	st := s.Stats
+	if st.TodosAttempted != 2 || st.TodosCompleted != 2 {
+		t.Errorf("expected 2 attempted/completed, got %d/%d", st.TodosAttempted, st.TodosCompleted)
+	}
+	if st.CriticApprovals != 1 || st.CriticMinor != 1 || st.CriticRejections != 1 {
+		t.Errorf("unexpected verdict counts %+v", st)
+	}
+	if st.FixAttempts != 1 || st.FixSuccesses != 1 {
+		t.Errorf("expected 1 fix attempt and success, got %d/%d", st.FixAttempts, st.FixSuccesses)
+	}
+	if st.ClaudeRuns != 7 {
+		t.Errorf("expected 7 Claude runs, got %d", st.ClaudeRuns)
+	}
+
+	if st.Usage.Sessions != 7 || st.Usage.CostUSD != 1.75 || st.Usage.InputTokens != 700 {
+		t.Errorf("unexpected usage totals %+v", st.Usage)
+	}
+	if got := st.PhaseUsage[state.PhaseCritic]; got == nil || got.Sessions != 3 {
+		t.Errorf("expected 3 critic sessions, got %+v", got)
+	}
+	// First: coder, critic, fixer, critic; Second: coder, critic; evaluator has no TODO
+	if got := st.TodoUsage["First"]; got == nil || got.Sessions != 4 || got.CostUSD != 1 {
+		t.Errorf("unexpected usage for First: %+v", got)
+	}
+	if got := st.TodoUsage["Second"]; got == nil || got.Sessions != 2 {
+		t.Errorf("unexpected usage for Second: %+v", got)
+	}
+
+	loaded, _ := state.Load()
+	if loaded.Step != state.StepDone {
+		t.Errorf("persisted step should be done, got %q", loaded.Step)
+	}
+}
+
+func TestEngineJournal(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"APPROVED"}}
+
+	if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	events, err := state.LoadEvents()
+	if err != nil {
+		t.Fatalf("LoadEvents failed: %v", err)
+	}
+	var types []string
+	for _, ev := range events {
+		types = append(types, string(ev.Type))
+	}
+	want := "run_started todo_started phase_started phase_ended commit_created check phase_started phase_ended verdict todo_completed phase_started phase_ended run_ended"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+
+	coder := events[3]
+	if coder.Phase != state.PhaseCoder || coder.Todo != "first" || coder.Before == "" || coder.Commit == coder.Before || coder.Tokens != 110 || coder.CostUSD != 0.25 {
+		t.Errorf("unexpected coder phase_ended event %+v", coder)
+	}
+	if verdict := events[8]; verdict.Verdict != state.VerdictApproved {
+		t.Errorf("unexpected verdict event %+v", verdict)
+	}
+	if ended := events[len(events)-1]; ended.Message != "complete" {
+		t.Errorf("unexpected run_ended event %+v", ended)
+	}
+
+	// The journal is excluded from git, so it never shows up as a change
+	if out, _ := exec.Command("git", "status", "--porcelain").Output(); strings.Contains(string(out), state.EventsFile) {
+		t.Errorf("journal should be ignored by git, got status %q", out)
+	}
+}
+
+func TestEngineRunHistory(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	s.StartRun(time.Now().Add(-time.Hour))
+	fake := &fakeClaude{t: t, verdicts: []string{"NEEDS_FIXES", "NEEDS_FIXES", "NEEDS_FIXES"}}
+	if err := newTestEngine(s, fake).Run(t.Context()); !errors.Is(err, ErrEscalated) {
+		t.Fatalf("expected escalation, got %v", err)
+	}
+
+	// The next run starts with fresh stats, and the first keeps its own
+	s.StartRun(time.Now())
+	s.Step = state.StepEvaluator
+	if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	runs, err := state.LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %+v", runs)
+	}
+	first, second := runs[0], runs[1]
+	if first.Outcome != state.OutcomeEscalated || first.Stats.FixAttempts != 2 || first.Stats.ClaudeRuns != 6 || first.EndedAt.IsZero() {
+		t.Errorf("unexpected first run %+v with stats %+v", first, first.Stats)
+	}
+	if second.Outcome != state.OutcomeComplete || second.Stats.ClaudeRuns != 1 || second.ID == first.ID {
+		t.Errorf("unexpected second run %+v with stats %+v", second, second.Stats)
+	}
+}
+
+func TestEngineSessionTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeOut string
+		phase   state.Phase
+		want    string
+	}{
+		// The coder's attempt goes to the fixer like a failed review
+		{"coder", "The orchestrator selected this TODO", state.PhaseCoder, "timeout fixer critic evaluator"},
+		// The critic's counts as a review without a verdict
+		{"critic", "code reviewer", state.PhaseCritic, "coder timeout critic evaluator"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+			fake := &fakeClaude{t: t, verdicts: []string{"APPROVED"}, timeOut: tt.timeOut}
+			// The fixer finishes what the stopped coder started
+			fake.onFixer = func() {
+				list, _ := state.LoadTodos()
+				if next, _ := list.Next(); next != nil {
+					next.Checked = true
+					list.Save()
+				}
+			}
+
+			if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if got := strings.Join(fake.phases, " "); got != tt.want {
+				t.Errorf("phases = %q, want %q", got, tt.want)
+			}
+			if s.Stats.TodosCompleted != 1 {
+				t.Errorf("expected the TODO to be completed, got stats %+v", s.Stats)
+			}
+			if len(fake.fixes) > 0 && !strings.Contains(fake.fixes[0], "session timed out after 1m0s") {
+				t.Errorf("expected the fixer to be told about the timeout, got:\n%s", fake.fixes[0])
+			}
+
+			events, _ := state.LoadEvents()
+			idx := slices.IndexFunc(events, func(ev state.Event) bool { return ev.Type == state.EventTimeout })
+			if idx < 0 || events[idx].Phase != tt.phase {
+				t.Errorf("expected a timeout event for the %s, got %+v", tt.phase, events)
+			}
+		})
+	}
+}
+
+func TestEngineSessionFailures(t *testing.T) {
+	const overloaded = `API Error: 529 {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+	tests := []struct {
+		name     string
+		failures []string
+		want     string // phases run
+		wantErr  string
+		retries  int
+		auth     bool
+	}{
+		{"transient", []string{overloaded, "API Error: 429 rate_limit_error"},
+			"failed failed coder critic evaluator", "", 2, false},
+		{"retries exhausted", []string{overloaded, overloaded, overloaded, overloaded},
+			"failed failed failed failed", "gave up after 3 retries", 3, false},
+		{"auth", []string{"Invalid API key · Please run /login"},
+			"failed", "claude could not authenticate", 0, true},
+		{"fatal", []string{"Error: unknown option '--bogus'"},
+			"failed", "unknown option", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+			fake := &fakeClaude{t: t, verdicts: []string{"APPROVED"}, failures: tt.failures}
+			e := New(s, Options{AutoclaudePath: "/test/autoclaude", MaxRetries: 3, RetryBackoff: 10 * time.Second})
+			e.runner = claude.RunnerFunc(fake.run)
+			var delays []time.Duration
+			e.sleep = func(_ context.Context, d time.Duration) error {
+				delays = append(delays, d)
+				return nil
+			}
+
+			err := e.Run(t.Context())
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if got := strings.Join(fake.phases, " "); got != tt.want {
+				t.Errorf("phases = %q, want %q", got, tt.want)
+			}
+			if s.Stats.SessionRetries != tt.retries || len(delays) != tt.retries {
+				t.Errorf("expected %d retries, got %d with delays %v", tt.retries, s.Stats.SessionRetries, delays)
+			}
+			// The backoff doubles for each retry, with up to half of it random
+			for i, d := range delays {
+				if base := 10 * time.Second << i; d < base/2 || d > base {
+					t.Errorf("retry %d waited %v, want between %v and %v", i+1, d, base/2, base)
+				}
+			}
+			if errors.Is(err, ErrAuth) != tt.auth {
+				t.Errorf("expected ErrAuth to be %v, got %v", tt.auth, err)
+			}
+		})
+	}
+}
+
+func TestEngineInterrupted(t *testing.T) {
+	s := setupProject(t, twoTodos, "true")
+	ctx, cancel := context.WithCancel(t.Context())
+	fake := &fakeClaude{t: t, verdicts: []string{"APPROVED"}}
+	fake.onCoder = cancel
+
+	err := newTestEngine(s, fake).Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to be interrupted, got %v", err)
+	}
+	if s.Step != state.StepCoder {
+		t.Errorf("expected the coder step to be left for resume, got %q", s.Step)
+	}
+	if runs, _ := state.LoadHistory(); len(runs) != 1 || runs[0].Outcome != state.OutcomeInterrupted {
+		t.Errorf("expected an interrupted run in the history, got %+v", runs)
+	}
+}
+
+func TestEngineResumeFromCritic(t *testing.T) {
+	s := setupProject(t, "- [x] **First** - Completion: done\n", "true")
+	state.SetCurrentTodo("**First** - Completion: done")
+	s.Step = state.StepCritic
+	s.Iteration = 1
+	s.Save()
+
+	fake := &fakeClaude{t: t, verdicts: []string{"NEEDS_FIXES\n\nbroken", "NEEDS_FIXES\n\nstill broken", "APPROVED"}}
+	if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Resuming in the critic step re-critiques after each fix and counts the approval
+	want := "critic fixer critic fixer critic evaluator"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+	if s.Stats.CriticApprovals != 1 || s.Stats.TodosCompleted != 1 || s.Stats.FixSuccesses != 1 {
+		t.Errorf("approval not counted on resume: %+v", s.Stats)
+	}
+}
+
+func TestEngineExhaustsRetries(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"NEEDS_FIXES", "NEEDS_FIXES", "NEEDS_FIXES"}}
+
+	if err := newTestEngine(s, fake).Run(t.Context()); !errors.Is(err, ErrEscalated) {
+		t.Fatalf("expected escalation, got %v", err)
+	}
+
+	want := "coder critic fixer critic fixer critic"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+	if s.Stats.TodosCompleted != 0 || s.Stats.FixAttempts != 2 {
+		t.Errorf("unexpected stats after exhausting retries: %+v", s.Stats)
+	}
+}
+
+func TestEngineEscalatesBlockedTodos(t *testing.T) {
+	s := setupProject(t, `## Pending
+- [ ] **First** - Completion: done
+- [ ] **Second** - Completion: done
+  - Dependencies: First
+- [ ] **Third** - Completion: done
+`, "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"NEEDS_FIXES\n\nstill broken", "APPROVED"}}
+
+	e := newTestEngine(s, fake)
+	e.opts.RetryLimit = 1
+	if err := e.Run(t.Context()); !errors.Is(err, ErrEscalated) {
+		t.Fatalf("expected escalation, got %v", err)
+	}
+
+	// Second waits on the blocked First; the evaluator doesn't run
+	want := "coder critic coder critic"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+	if s.Step != state.StepCoder {
+		t.Errorf("escalation should leave the step at coder, got %q", s.Step)
+	}
+	report, err := os.ReadFile(state.EscalationPath())
+	if err != nil {
+		t.Fatalf("escalation report missing: %v", err)
+	}
+	for _, want := range []string{"### First", "Critic feedback: still broken", "Waiting on it: Second"} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+
+	// Once a human skips the blocked TODO, resuming finishes the run
+	list, _ := state.LoadTodos()
+	list.Find("First").Status = state.StatusSkipped
+	list.Save()
+	fake.verdicts = []string{"APPROVED"}
+	fake.phases = nil
+	if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+		t.Fatalf("resumed Run failed: %v", err)
+	}
+	if got := strings.Join(fake.phases, " "); got != "coder critic evaluator" {
+		t.Errorf("phases after resume = %q", got)
+	}
+	if _, err := os.Stat(state.EscalationPath()); !os.IsNotExist(err) {
+		t.Error("escalation report should be removed once the run completes")
+	}
+}
+
+func TestEngineReasksUnparseableVerdict(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	fake := &fakeClaude{t: t, verdicts: []string{
+		"Looks good to me",
+		"---\nverdict: APPROVED\n---\n",
+	}}
+
+	if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The re-ask is a second critic session rather than a retry of the TODO
+	want := "coder critic critic evaluator"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+	if s.Stats.CriticApprovals != 1 || s.Stats.TodosCompleted != 1 || s.RetryCount != 0 {
+		t.Errorf("expected the re-asked verdict to be approved, got %+v (retries %d)", s.Stats, s.RetryCount)
+	}
+}
+
+func TestEngineUnparseableVerdictUsesRetry(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"?", "?", "?", "APPROVED"}}
+
+	if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// One review plus MaxCriticReasks re-asks, then the TODO is re-tested and reviewed again
+	want := "coder critic critic critic critic evaluator"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+	if s.Stats.CriticApprovals != 1 {
+		t.Errorf("expected approval after retry, got %+v", s.Stats)
+	}
+}
+
+func TestEngineAddsSuggestedTodos(t *testing.T) {
+	s := setupProject(t, "## Pending\n- [ ] **First** - Completion: done\n", "true")
+	fake := &fakeClaude{t: t, verdicts: []string{
+		`---
+verdict: MINOR_ISSUES
+summary: Works, one naming nit.
+suggested_todos:
+  - title: "Fix: rename helper"
+    completion: helper renamed
+  - title: First
+---
+`,
+		"APPROVED",
+	}}
+
+	if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The suggested TODO is picked up by the loop; the duplicate is skipped
+	want := "coder critic coder critic evaluator"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+	list, err := state.LoadTodos()
+	if err != nil {
+		t.Fatalf("LoadTodos failed: %v", err)
+	}
+	if len(list.Todos) != 2 {
+		t.Fatalf("expected 2 TODOs, got %d", len(list.Todos))
+	}
+	if added := list.Find("Fix: rename helper"); added == nil || added.Priority != state.PriorityLow || added.Section != "Pending" {
+		t.Errorf("suggested TODO not added as expected: %+v", added)
+	}
+}
+
+func TestEngineTestGateSkipsCritic(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "test -f fixed.txt")
+	fake := &fakeClaude{t: t, verdicts: []string{"APPROVED"}}
+	fake.onFixer = func() {
+		data, _ := os.ReadFile(config.CurrentPromptPath())
+		if !strings.Contains(string(data), "exited with code 1") {
+			t.Error("fixer prompt should contain the failing test run")
+		}
+		os.WriteFile("fixed.txt", []byte("ok"), 0644)
+	}
+
+	if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := "coder fixer critic evaluator"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+	if s.Stats.TestRuns != 2 || s.Stats.TestFailures != 1 {
+		t.Errorf("expected 2 test runs with 1 failure, got %d/%d", s.Stats.TestRuns, s.Stats.TestFailures)
+	}
+}
+
+func TestEngineStopsAtBudgetAndResumes(t *testing.T) {
+	s := setupProject(t, twoTodos, "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"APPROVED", "APPROVED"}}
+
+	e := newTestEngine(s, fake)
+	e.opts.Budget = &state.Budget{MaxSessions: 2}
+	err := e.Run(t.Context())
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if got := strings.Join(fake.phases, " "); got != "coder critic" {
+		t.Errorf("phases = %q, want %q", got, "coder critic")
+	}
+
+	// The stop is persisted between phases so resume can pick it up
+	loaded, _ := state.Load()
+	if loaded.Step != state.StepCoder || loaded.Stats.ClaudeRuns != 2 {
+		t.Errorf("unexpected persisted state: step=%q runs=%d", loaded.Step, loaded.Stats.ClaudeRuns)
+	}
+	status, _ := os.ReadFile(state.StatusPath())
+	if !strings.Contains(string(status), "session budget reached") {
+		t.Errorf("STATUS.md should record the budget stop:\n%s", status)
+	}
+
+	// Raising the budget lets the run finish
+	fake.phases = nil
+	e = newTestEngine(loaded, fake)
+	e.opts.Budget = &state.Budget{MaxSessions: 10}
+	if err := e.Run(t.Context()); err != nil {
+		t.Fatalf("resumed Run failed: %v", err)
+	}
+	if got := strings.Join(fake.phases, " "); got != "coder critic evaluator" {
+		t.Errorf("resumed phases = %q, want %q", got, "coder critic evaluator")
+	}
+	if loaded.Stats.TodosCompleted != 2 {
+		t.Errorf("expected 2 TODOs completed across runs, got %d", loaded.Stats.TodosCompleted)
+	}
+}
+
+func TestEngineLintFailureGoesToFixer(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"APPROVED"}}
+	fake.onFixer = func() {
+		data, _ := os.ReadFile(config.CurrentPromptPath())
+		if !strings.Contains(string(data), "## Lint Failed") {
+			t.Error("fixer prompt should contain the failing lint run")
+		}
+		os.WriteFile("linted.txt", []byte("ok"), 0644)
+	}
+
+	e := newTestEngine(s, fake)
+	e.opts.LintCommands = []string{"test -f linted.txt"}
+	if err := e.Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := "coder fixer critic evaluator"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+	if s.Stats.TestRuns != 2 || s.Stats.LintRuns != 2 || s.Stats.LintFailures != 1 {
+		t.Errorf("unexpected check counts %+v", s.Stats)
+	}
+}
+
+func TestEngineRetryLimit(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"NEEDS_FIXES"}}
+
+	e := newTestEngine(s, fake)
+	e.opts.RetryLimit = 1
+	if err := e.Run(t.Context()); !errors.Is(err, ErrEscalated) {
+		t.Fatalf("expected escalation, got %v", err)
+	}
+
+	// A single attempt means no fixer runs after the critic rejects
+	want := "coder critic"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+}
+
+func TestEngineCommitGuardRefusesSecrets(t *testing.T) {
+	s := setupProject(t, "- [ ] **First** - Completion: done\n", "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"APPROVED"}}
+	fake.onCoder = func() {
+		os.WriteFile(".env", []byte("TOKEN=1\n"), 0644)
+		os.WriteFile("main.go", []byte("package main\n"), 0644)
+	}
+	fake.onFixer = func() {
+		data, _ := os.ReadFile(config.CurrentPromptPath())
+		if !strings.Contains(string(data), "## Commit Refused") || !strings.Contains(string(data), ".env: environment file") {
+			t.Errorf("fixer prompt should list the refused file, got:\n%s", data)
+		}
+		os.Remove(".env")
+	}
+
+	if err := newTestEngine(s, fake).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := "coder fixer critic evaluator"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+	if s.Stats.CommitsRefused != 1 || s.Stats.TestRuns != 1 {
+		t.Errorf("expected 1 refused commit and 1 test run, got %+v", s.Stats)
+	}
+	out, _ := exec.Command("git", "log", "--name-only", "--format=").Output()
+	if strings.Contains(string(out), ".env") || !strings.Contains(string(out), "main.go") {
+		t.Errorf("expected main.go but not .env to be committed, got:\n%s", out)
+	}
+}
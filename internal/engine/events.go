@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+	"go.coldcutz.net/autoclaude/internal/git"
+	"go.coldcutz.net/autoclaude/internal/state"
+	"go.coldcutz.net/autoclaude/internal/testgate"
+)
+
+// record appends an event to the journal. The loop doesn't depend on the
+// journal, so failing to write it is only a warning.
+func (e *Engine) record(ev state.Event) {
+	if err := state.RecordEvent(ev); err != nil {
+		fmt.Printf("  ⚠ Failed to record %s event: %v\n", ev.Type, err)
+	}
+}
+
+// recordRunEnded records how Run ended, and the error if it failed, in the
+// journal and the run history
+func (e *Engine) recordRunEnded(err error) {
+	outcome := state.OutcomeComplete
+	switch {
+	case errors.Is(err, ErrBudgetExceeded):
+		outcome = state.OutcomeBudget
+	case errors.Is(err, ErrEscalated):
+		outcome = state.OutcomeEscalated
+	case errors.Is(err, context.Canceled):
+		outcome = state.OutcomeInterrupted
+	case err != nil:
+		e.record(state.Event{Type: state.EventError, Message: err.Error()})
+		outcome = state.OutcomeFailed
+	}
+	e.record(state.Event{Type: state.EventRunEnded, Commit: git.CommitHash(), Message: outcome})
+	e.saveRun(outcome)
+}
+
+// saveRun saves the run and its stats so far to the history. Parallel
+// workers are part of the loop's run, so they keep no history of their own.
+func (e *Engine) saveRun(outcome string) {
+	if e.opts.SingleTodo {
+		return
+	}
+	var ended time.Time
+	if outcome != state.OutcomeRunning {
+		ended = time.Now()
+	}
+	if err := state.SaveRun(e.state.RunRecord(outcome, ended)); err != nil {
+		fmt.Printf("  ⚠ Failed to save run history: %v\n", err)
+	}
+}
+
+// recordPhaseEnded records a finished Claude session with what it cost
+func (e *Engine) recordPhaseEnded(started state.Event, took time.Duration, result *claude.SessionResult, err error) {
+	ev := started
+	ev.Type = state.EventPhaseEnded
+	ev.Time = time.Time{}
+	ev.Commit = git.CommitHash()
+	ev.DurationMs = took.Milliseconds()
+	if result != nil {
+		ev.SessionID = result.SessionID
+		if result.Model != "" {
+			ev.Model = result.Model
+		}
+		u := result.Usage
+		ev.Tokens = u.InputTokens + u.OutputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+		ev.CostUSD = result.CostUSD
+	}
+	if err != nil {
+		ev.Message = err.Error()
+	}
+	e.record(ev)
+}
+
+// recordCheck records a test or lint command run by the test gate
+func (e *Engine) recordCheck(result *testgate.Result) {
+	e.record(state.Event{Type: state.EventCheck, DurationMs: result.DurationMs, ExitCode: result.ExitCode, Message: result.Command})
+}
+
+// mergeWorkerEvents copies a parallel worker's journal into the loop's. The
+// worker's own run start and end are left out, since they are part of this run.
+func (e *Engine) mergeWorkerEvents(dir string) {
+	events, err := state.LoadEventsFrom(dir)
+	if err != nil {
+		fmt.Printf("  ⚠ Failed to read worker events: %v\n", err)
+		return
+	}
+	var keep []state.Event
+	for _, ev := range events {
+		if ev.Type != state.EventRunStarted && ev.Type != state.EventRunEnded {
+			keep = append(keep, ev)
+		}
+	}
+	if err := state.AppendEvents(keep); err != nil {
+		fmt.Printf("  ⚠ Failed to record worker events: %v\n", err)
+	}
+}
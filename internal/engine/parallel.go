@@ -0,0 +1,465 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/git"
+	"go.coldcutz.net/autoclaude/internal/state"
+	"go.coldcutz.net/autoclaude/internal/testgate"
+)
+
+const (
+	// WorktreesDir holds the worktrees of parallel workers, inside .autoclaude
+	WorktreesDir = "worktrees"
+
+	// WorkerOptionsFile passes engine options from the loop to a worker
+	WorkerOptionsFile = "worker.json"
+
+	// BranchPrefix is prepended to the TODO ID to name its branch
+	BranchPrefix = "autoclaude/todo-"
+)
+
+// workerScratchFiles are per-TODO files that workers start without
+var workerScratchFiles = []string{
+	WorktreesDir,
+	state.StateFile,
+	state.CurrentTodoFile,
+	state.CriticVerdictFile,
+	state.LastSessionFile,
+	state.EventsFile,
+	state.HistoryFile,
+	testgate.OutputFile,
+	testgate.ResultFile,
+}
+
+// worker is one TODO being worked on in its own worktree
+type worker struct {
+	todo   *state.Todo
+	dir    string
+	branch string
+	err    error
+}
+
+// TodoBranch returns the branch a TODO is worked on in
+func TodoBranch(t *state.Todo) string {
+	return BranchPrefix + t.ID()
+}
+
+// claimTodoBranch checks that the TODO's branch, if it exists already, holds
+// work on this TODO rather than another one with the same ID. The TODO is
+// recorded as the branch's description once the branch has been created.
+func claimTodoBranch(t *state.Todo) error {
+	branch := TodoBranch(t)
+	if !git.BranchExists(branch) {
+		return nil
+	}
+	// Branches from before TODOs were recorded on them are taken as they are
+	if owner := git.BranchDescription(branch); owner != "" && owner != t.Title {
+		return fmt.Errorf("branch %s already holds work on %q; rename one of the TODOs or delete the branch", branch, owner)
+	}
+	return nil
+}
+
+// batchTodos picks up to n of the ready TODOs to work on at once, leaving out
+// any with the same ID as one already picked, since they would share a
+// worktree and branch
+func batchTodos(ready []*state.Todo, n int) []*state.Todo {
+	var batch []*state.Todo
+	ids := make(map[string]bool)
+	for _, t := range ready {
+		if len(batch) == n {
+			break
+		}
+		if ids[t.ID()] {
+			continue
+		}
+		ids[t.ID()] = true
+		batch = append(batch, t)
+	}
+	return batch
+}
+
+// LoadWorkerOptions reads the options the loop passed to this worker
+func LoadWorkerOptions() (Options, error) {
+	var opts Options
+	data, err := os.ReadFile(filepath.Join(state.AutoclaudeDir, WorkerOptionsFile))
+	if err != nil {
+		return opts, fmt.Errorf("failed to read worker options: %w", err)
+	}
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return opts, fmt.Errorf("failed to parse worker options: %w", err)
+	}
+	return opts, nil
+}
+
+// runBatch works on independent TODOs at once, each in its own worktree and
+// branch, then merges the approved branches back in order. A merge conflict
+// becomes a new TODO to merge the branch by hand, and a worker that failed
+// blocks just its own TODO.
+func (e *Engine) runBatch(list *state.TodoList, todos []*state.Todo) error {
+	s := e.state
+
+	fmt.Printf("\n=== PARALLEL: %d TODOs ===\n", len(todos))
+	base := git.CommitHash()
+	if base == "" {
+		return fmt.Errorf("parallel mode needs at least one commit")
+	}
+	if err := git.Exclude("/" + filepath.Join(state.AutoclaudeDir, WorktreesDir) + "/"); err != nil {
+		return fmt.Errorf("failed to exclude worktrees from git: %w", err)
+	}
+
+	workers := make([]*worker, 0, len(todos))
+	for _, t := range todos {
+		w, err := e.prepareWorker(t, base)
+		if err != nil {
+			for _, w := range workers {
+				git.RemoveWorktree(w.dir)
+			}
+			return fmt.Errorf("failed to prepare worktree for %q: %w", t.Title, err)
+		}
+		workers = append(workers, w)
+		s.Iteration++
+		s.Stats.TodosAttempted++
+		fmt.Printf("  [%s] %s on %s\n", t.ID(), t.Title, w.branch)
+	}
+	s.UpdateStatus(fmt.Sprintf("Working on %d TODOs in parallel", len(workers)))
+	if err := s.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out := &prefixWriter{w: os.Stdout, mu: &mu, prefix: "[" + w.todo.ID() + "] "}
+			w.err = e.runWorker(w.dir, out)
+			out.Flush()
+		}()
+	}
+	wg.Wait()
+	e.trackTime()
+
+	fmt.Println("\n=== MERGING ===")
+	completedBefore := s.Stats.TodosCompleted
+	for i, w := range workers {
+		if err := e.finishWorker(list, w); err != nil {
+			// Leave the rest for resume, which starts them over
+			for _, w := range workers[i+1:] {
+				git.RemoveWorktree(w.dir)
+			}
+			list.Save()
+			s.Save()
+			return err
+		}
+	}
+	if err := list.Save(); err != nil {
+		return err
+	}
+
+	interval := e.opts.PruneInterval
+	if interval > 0 && completedBefore/interval != s.Stats.TodosCompleted/interval {
+		if err := e.prune(); err != nil {
+			fmt.Printf("  ⚠ Pruning failed: %v\n", err)
+		}
+	}
+	return e.transition(state.StepCoder)
+}
+
+// prepareWorker creates a worktree for the TODO with a copy of the loop's
+// scratch state, set up to work on just that TODO
+func (e *Engine) prepareWorker(t *state.Todo, base string) (*worker, error) {
+	w := &worker{
+		todo:   t,
+		dir:    filepath.Join(state.AutoclaudeDir, WorktreesDir, t.ID()),
+		branch: TodoBranch(t),
+	}
+
+	if err := claimTodoBranch(t); err != nil {
+		return nil, err
+	}
+	// Left over from an interrupted run; an existing branch is reused
+	if _, err := os.Stat(w.dir); err == nil {
+		if err := git.RemoveWorktree(w.dir); err != nil {
+			return nil, err
+		}
+	}
+	exists := git.BranchExists(w.branch)
+	if err := git.AddWorktree(w.dir, w.branch, base); err != nil {
+		return nil, err
+	}
+	if exists {
+		if err := rebaseTodoBranch(w.dir, w.branch, base); err != nil {
+			git.RemoveWorktree(w.dir)
+			return nil, err
+		}
+	}
+	if err := git.SetBranchDescription(w.branch, t.Title); err != nil {
+		return nil, err
+	}
+
+	scratch := filepath.Join(w.dir, state.AutoclaudeDir)
+	if err := copyDir(state.AutoclaudeDir, scratch, workerScratchFiles); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(config.SettingsPath()); err == nil {
+		if err := copyFile(config.SettingsPath(), filepath.Join(w.dir, config.SettingsPath())); err != nil {
+			return nil, err
+		}
+	}
+
+	s := e.state
+	ws := state.NewState(s.Goal, s.TestCmd, s.Constraints, s.MaxIterations)
+	ws.Iteration = s.Iteration + 1
+	ws.Stats = &state.Stats{}
+	if err := ws.SaveTo(w.dir); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(w.dir, state.CurrentTodoPath()), []byte(t.Header()), 0644); err != nil {
+		return nil, err
+	}
+
+	opts := e.opts
+	opts.Parallel = 0
+	opts.SingleTodo = true
+	// The worker is already on the TODO's branch, and workers share the
+	// terminal so none of them can own it
+	opts.BranchPerTodo = false
+	opts.OnExhausted = config.ExhaustedKeep
+	opts.Backend = config.BackendHeadless
+	opts.Budget = nil
+	opts.PruneInterval = 0
+	data, err := json.MarshalIndent(opts, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(scratch, WorkerOptionsFile), data, 0644); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// finishWorker collects a worker's stats and TODOs, then merges its branch if
+// the TODO was approved. A worker that failed, or whose branch can't be
+// cleaned up or merged, blocks its TODO with the branch recorded, and the
+// other workers are finished as usual. An error is returned only if the loop
+// was cancelled or a failed merge left the working tree in an unknown state.
+func (e *Engine) finishWorker(list *state.TodoList, w *worker) error {
+	s := e.state
+	t := list.Find(w.todo.Title)
+	id := w.todo.ID()
+
+	ws, err := state.LoadFrom(w.dir)
+	if err == nil {
+		s.Stats.Merge(ws.Stats)
+	}
+	e.mergeWorkerEvents(w.dir)
+	if w.err == nil && err != nil {
+		w.err = err
+	}
+	if w.err != nil {
+		git.RemoveWorktree(w.dir)
+		if e.ctx.Err() != nil {
+			return fmt.Errorf("worker for %q stopped: %w", w.todo.Title, context.Cause(e.ctx))
+		}
+		e.blockWorker(t, w, fmt.Sprintf("worker failed: %v", w.err), nil)
+		return nil
+	}
+	approved := ws.Step == state.StepDone && ws.Stats != nil && ws.Stats.TodosCompleted > 0
+
+	// Keep TODOs the worker's critic suggested
+	addNewTodos(list, state.ParseTodos(readFile(filepath.Join(w.dir, state.TodoPath()))))
+
+	review, _ := state.ParseCriticReview(readFile(filepath.Join(w.dir, state.CriticVerdictPath())))
+
+	// The loop owns its scratch state and Claude settings, so drop the worker's
+	// changes to them before merging
+	subjects, err := cleanWorkerBranch(w)
+	if err != nil {
+		git.RemoveWorktree(w.dir)
+		e.blockWorker(t, w, err.Error(), review)
+		return nil
+	}
+	if !approved {
+		e.blockWorker(t, w, e.exhaustedReason(), review)
+		return nil
+	}
+
+	message := fmt.Sprintf("autoclaude: merge %s", w.todo.Title)
+	if e.opts.BranchPerTodo {
+		message = squashMessage(w.todo.Title, review, subjects)
+	}
+	conflicts, err := git.Merge(w.branch, message, e.opts.BranchPerTodo)
+	if errors.Is(err, git.ErrAbortFailed) {
+		return fmt.Errorf("failed to merge %s: %w", w.branch, err)
+	}
+	if err != nil {
+		e.blockWorker(t, w, fmt.Sprintf("failed to merge: %v", err), review)
+		return nil
+	}
+	if len(conflicts) > 0 {
+		fmt.Printf("  ✗ [%s] Merge conflict in %s, added a TODO to resolve it\n", id, strings.Join(conflicts, ", "))
+		resolve := &state.Todo{
+			Title:      "Resolve merge conflict: " + w.todo.Title,
+			Completion: fmt.Sprintf("branch %s is merged with the conflicts resolved and tests passing", w.branch),
+			Priority:   state.PriorityHigh,
+			SubBullets: []string{"Conflicting files: " + strings.Join(conflicts, ", ")},
+		}
+		// None of the TODO's code is in yet, so it and everything that needs
+		// it wait for the merge
+		if t != nil {
+			t.Dependencies = append(t.Dependencies, resolve.Title)
+		}
+		s.Stats.TodosCompleted--
+		list.Add(resolve, "Pending")
+		e.record(state.Event{Type: state.EventError, Todo: id, Message: "merge conflict in " + strings.Join(conflicts, ", ")})
+		return nil
+	}
+
+	if t != nil {
+		t.Checked = true
+	}
+	fmt.Printf("  ✓ [%s] Merged %s\n", id, w.branch)
+	if err := git.DeleteBranch(w.branch); err != nil {
+		fmt.Printf("  ⚠ Failed to delete branch %s: %v\n", w.branch, err)
+	}
+	return nil
+}
+
+// cleanWorkerBranch drops the worker's changes to the loop's scratch state
+// and Claude settings from its branch and removes its worktree. It returns
+// the subjects of the branch's commits.
+func cleanWorkerBranch(w *worker) ([]string, error) {
+	mergeBase, err := git.MergeBase("HEAD", w.branch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find where %s branched off: %w", w.branch, err)
+	}
+	subjects, err := git.CommitSubjects(mergeBase, w.branch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the commits on %s: %w", w.branch, err)
+	}
+	if err := git.ResetPaths(w.dir, mergeBase, state.AutoclaudeDir, config.SettingsPath()); err != nil {
+		return nil, fmt.Errorf("failed to clean up branch %s: %w", w.branch, err)
+	}
+	if err := git.RemoveWorktree(w.dir); err != nil {
+		return nil, fmt.Errorf("failed to remove worktree %s: %w", w.dir, err)
+	}
+	return subjects, nil
+}
+
+// blockWorker blocks a worker's TODO with its branch recorded, leaving the
+// branch for a later attempt or for you to merge by hand
+func (e *Engine) blockWorker(t *state.Todo, w *worker, reason string, review *state.CriticReview) {
+	fmt.Printf("  ✗ [%s] %s, left on branch %s\n", w.todo.ID(), reason, w.branch)
+	e.record(state.Event{Type: state.EventTodoBlocked, Todo: w.todo.ID(), Message: reason})
+	if t != nil {
+		recordUnmergedBranch(t, w.branch, reason, review)
+	}
+}
+
+// execWorker runs a worker as a separate autoclaude process in its worktree
+func (e *Engine) execWorker(dir string, out io.Writer) error {
+	cmd := exec.CommandContext(e.ctx, e.opts.AutoclaudePath, "_worker")
+	// A cancelled worker stops its own session, which may take a while
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = claude.InterruptGrace + claude.TerminateGrace + 5*time.Second
+	cmd.Dir = dir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// prefixWriter prefixes each line with the worker's ID so that interleaved
+// output stays readable
+type prefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		p.writeLine(p.buf[:i+1])
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes any incomplete last line
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	io.WriteString(p.w, p.prefix)
+	p.w.Write(line)
+}
+
+// copyDir copies the files in src to dst, skipping the named top-level entries
+func copyDir(src, dst string, skip []string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if slices.Contains(skip, entry.Name()) {
+			continue
+		}
+		from, to := filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())
+		if entry.IsDir() {
+			err = copyDir(from, to, nil)
+		} else if entry.Type().IsRegular() {
+			err = copyFile(from, to)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile returns a file's content, or "" if it can't be read
+func readFile(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
@@ -0,0 +1,355 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/git"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+// fakeWorker stands in for worker processes. Each worker checks off its TODO
+// and commits a file in its worktree.
+type fakeWorker struct {
+	t        *testing.T
+	mu       sync.Mutex
+	ran      []string          // TODO IDs in the order workers were started
+	rejected map[string]bool   // TODO IDs the critic never approves
+	files    map[string]string // File each worker writes, <id>.txt by default
+	suggest  map[string]string // TODO title each worker's critic suggests
+	failed   map[string]bool   // TODO IDs whose worker fails after committing
+}
+
+func (f *fakeWorker) run(dir string, out io.Writer) error {
+	id := filepath.Base(dir)
+	f.mu.Lock()
+	f.ran = append(f.ran, id)
+	f.mu.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(dir, state.AutoclaudeDir, WorkerOptionsFile))
+	if err != nil {
+		f.t.Errorf("worker options missing: %v", err)
+	}
+	var opts Options
+	if json.Unmarshal(data, &opts); !opts.SingleTodo || opts.Backend != config.BackendHeadless || opts.Parallel != 0 {
+		f.t.Errorf("unexpected worker options %+v", opts)
+	}
+
+	todoPath := filepath.Join(dir, state.TodoPath())
+	data, _ = os.ReadFile(todoPath)
+	list := state.ParseTodos(string(data))
+	var title string
+	for _, t := range list.Todos {
+		if t.ID() == id {
+			t.Checked = true
+			title = t.Title
+		}
+	}
+	if title == "" {
+		f.t.Errorf("worker %s has no matching TODO", id)
+	}
+	if suggested := f.suggest[id]; suggested != "" {
+		list.Add(&state.Todo{Title: suggested, Completion: "done", Priority: state.PriorityLow}, "Pending")
+	}
+	os.WriteFile(todoPath, []byte(list.String()), 0644)
+
+	file := f.files[id]
+	if file == "" {
+		file = id + ".txt"
+	}
+	os.WriteFile(filepath.Join(dir, file), []byte(id+"\n"), 0644)
+	gitIn(f.t, dir, "add", "-A")
+	gitIn(f.t, dir, "commit", "-m", "work on "+id)
+
+	ws, err := state.LoadFrom(dir)
+	if err != nil {
+		f.t.Errorf("worker state missing: %v", err)
+		return err
+	}
+	ws.Step = state.StepDone
+	ws.Stats.RecordUsage(state.PhaseCoder, title, state.Usage{Sessions: 1, CostUSD: 0.25})
+	if !f.rejected[id] {
+		ws.Stats.TodosCompleted = 1
+		ws.Stats.CriticApprovals = 1
+	}
+	io.WriteString(out, "worked on "+id+"\n")
+	if err := ws.SaveTo(dir); err != nil || !f.failed[id] {
+		return err
+	}
+	return errors.New("exit status 1")
+}
+
+func gitIn(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Errorf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func newParallelEngine(s *state.State, fake *fakeClaude, workers *fakeWorker, n int) *Engine {
+	e := newTestEngine(s, fake)
+	e.opts.Parallel = n
+	e.runWorker = workers.run
+	return e
+}
+
+const independentTodos = `# TODOs
+
+## Pending
+- [ ] **First** - Completion: done
+  - Priority: high
+- [ ] **Second** - Completion: done
+- [ ] **Third** - Completion: done
+  - Dependencies: First, Second
+`
+
+func TestEngineParallelMergesApprovedBranches(t *testing.T) {
+	s := setupProject(t, independentTodos, "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"APPROVED"}}
+	workers := &fakeWorker{t: t}
+
+	if err := newParallelEngine(s, fake, workers, 3).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// First and Second run in parallel; Third waits for both and runs alone
+	if len(workers.ran) != 2 {
+		t.Errorf("expected 2 workers, got %v", workers.ran)
+	}
+	want := "coder critic evaluator"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+
+	for _, file := range []string{"first.txt", "second.txt"} {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("expected %s to be merged: %v", file, err)
+		}
+	}
+	log := gitIn(t, ".", "log", "--first-parent", "--format=%s")
+	if !strings.Contains(log, "autoclaude: merge Second\nautoclaude: merge First") {
+		t.Errorf("expected merges in priority order, got:\n%s", log)
+	}
+	if git.BranchExists("autoclaude/todo-first") || git.BranchExists("autoclaude/todo-second") {
+		t.Error("merged branches should be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(state.AutoclaudeDir, WorktreesDir, "first")); !os.IsNotExist(err) {
+		t.Error("worktrees should be removed")
+	}
+	if status := gitIn(t, ".", "status", "--porcelain", "--", "first.txt", "second.txt"); status != "" {
+		t.Errorf("merged files should be committed, got %q", status)
+	}
+
+	list, _ := state.LoadTodos()
+	if len(list.Incomplete()) != 0 {
+		t.Errorf("expected all TODOs done, got:\n%s", list)
+	}
+	st := s.Stats
+	if st.TodosAttempted != 3 || st.TodosCompleted != 3 || st.CriticApprovals != 3 {
+		t.Errorf("worker stats not merged: %+v", st)
+	}
+	if got := st.TodoUsage["First"]; got == nil || got.Sessions != 1 {
+		t.Errorf("expected worker usage for First, got %+v", got)
+	}
+}
+
+func TestEngineParallelSharedTitlePrefix(t *testing.T) {
+	s := setupProject(t, `# TODOs
+
+## Pending
+- [ ] **Add integration tests for the user service** - Completion: done
+- [ ] **Add integration tests for the user service API** - Completion: done
+`, "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"APPROVED"}}
+	workers := &fakeWorker{t: t}
+
+	if err := newParallelEngine(s, fake, workers, 2).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(workers.ran) != 2 || workers.ran[0] == workers.ran[1] {
+		t.Errorf("expected 2 workers in their own worktrees, got %v", workers.ran)
+	}
+	for _, id := range workers.ran {
+		if _, err := os.Stat(id + ".txt"); err != nil {
+			t.Errorf("expected the work of %s to be merged: %v", id, err)
+		}
+	}
+	if list, _ := state.LoadTodos(); len(list.Incomplete()) != 0 {
+		t.Errorf("expected all TODOs done, got:\n%s", list)
+	}
+}
+
+func TestBatchTodos(t *testing.T) {
+	// Titles that differ only in punctuation share an ID
+	a, b, c := &state.Todo{Title: "Add parser (v2)"}, &state.Todo{Title: "Add parser v2"}, &state.Todo{Title: "Other"}
+	if got := batchTodos([]*state.Todo{a, b, c}, 3); len(got) != 2 || got[0] != a || got[1] != c {
+		t.Errorf("expected the TODO with a duplicate ID to be left for later, got %v", got)
+	}
+}
+
+func TestClaimTodoBranch(t *testing.T) {
+	setupProject(t, "- [ ] **Add parser (v2)** - Completion: done\n", "true")
+	todo := &state.Todo{Title: "Add parser (v2)"}
+	branch := TodoBranch(todo)
+
+	gitIn(t, ".", "branch", branch)
+	if err := claimTodoBranch(todo); err != nil {
+		t.Errorf("expected a branch without a recorded TODO to be reused, got %v", err)
+	}
+	git.SetBranchDescription(branch, todo.Title)
+	if err := claimTodoBranch(todo); err != nil {
+		t.Errorf("expected the TODO's own branch to be reused, got %v", err)
+	}
+	if err := claimTodoBranch(&state.Todo{Title: "Add parser v2"}); err == nil || !strings.Contains(err.Error(), `already holds work on "Add parser (v2)"`) {
+		t.Errorf("expected another TODO's branch to be refused, got %v", err)
+	}
+}
+
+func TestEngineParallelConflictBecomesTodo(t *testing.T) {
+	s := setupProject(t, independentTodos, "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"APPROVED", "APPROVED", "APPROVED"}}
+	workers := &fakeWorker{t: t, files: map[string]string{"first": "shared.txt", "second": "shared.txt"}}
+	var coded []string
+	fake.onCoder = func() {
+		// The coder has just checked off its TODO
+		list, _ := state.LoadTodos()
+		for _, todo := range list.Todos {
+			if todo.Checked && !slices.Contains(coded, todo.Title) && todo.Title != "First" {
+				coded = append(coded, todo.Title)
+			}
+		}
+		commitAll(t, "coder")
+	}
+
+	if err := newParallelEngine(s, fake, workers, 2).Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Second isn't done until its branch is merged, and Third waits for it
+	if len(workers.ran) != 2 {
+		t.Errorf("expected 2 workers, got %v", workers.ran)
+	}
+	if got := strings.Join(coded, ", "); got != "Resolve merge conflict: Second, Second, Third" {
+		t.Errorf("expected the merge to be resolved before Second and Third, got %s", got)
+	}
+	data, _ := os.ReadFile("shared.txt")
+	if string(data) != "first\n" {
+		t.Errorf("expected First's change to be merged, got %q", data)
+	}
+	if !git.BranchExists("autoclaude/todo-second") {
+		t.Error("conflicting branch should be kept")
+	}
+	if s.Stats.TodosCompleted != 4 {
+		t.Errorf("expected 4 completed (First, the merge, Second, Third), got %d", s.Stats.TodosCompleted)
+	}
+
+	list, _ := state.LoadTodos()
+	resolve := list.Find("Resolve merge conflict: Second")
+	if resolve == nil {
+		t.Fatalf("expected a TODO for the conflict, got:\n%s", list)
+	}
+	if resolve.Priority != state.PriorityHigh || !strings.Contains(resolve.Completion, "autoclaude/todo-second") {
+		t.Errorf("unexpected conflict TODO %+v", resolve)
+	}
+	if second := list.Find("Second"); !slices.Contains(second.Dependencies, resolve.Title) {
+		t.Errorf("Second should wait for the merge, got %+v", second.Dependencies)
+	}
+	if !strings.Contains(list.String(), "Conflicting files: shared.txt") {
+		t.Errorf("conflict TODO should list the files, got:\n%s", list)
+	}
+}
+
+func TestEngineParallelBlocksFailedWorker(t *testing.T) {
+	s := setupProject(t, independentTodos, "true")
+	fake := &fakeClaude{t: t}
+	workers := &fakeWorker{t: t, failed: map[string]bool{"second": true}}
+
+	if err := newParallelEngine(s, fake, workers, 2).Run(t.Context()); !errors.Is(err, ErrEscalated) {
+		t.Fatalf("expected escalation, got %v", err)
+	}
+
+	if _, err := os.Stat("first.txt"); err != nil {
+		t.Error("the other worker's work should still be merged")
+	}
+	list, _ := state.LoadTodos()
+	if first := list.Find("First"); !first.Checked {
+		t.Error("First should be done")
+	}
+	second := list.Find("Second")
+	if second.Checked || second.Status != state.StatusBlocked || !strings.Contains(strings.Join(second.SubBullets, "\n"), "Unmerged branch: autoclaude/todo-second") {
+		t.Errorf("failed worker's TODO should be blocked with its branch recorded, got %+v", second)
+	}
+	events, _ := state.LoadEvents()
+	var blocked bool
+	for _, ev := range events {
+		blocked = blocked || ev.Type == state.EventTodoBlocked && ev.Todo == "second" && strings.Contains(ev.Message, "worker failed")
+	}
+	if !blocked {
+		t.Error("the failed worker should be journaled")
+	}
+}
+
+func TestEngineParallelKeepsRejectedBranch(t *testing.T) {
+	s := setupProject(t, independentTodos, "true")
+	fake := &fakeClaude{t: t, verdicts: []string{"APPROVED", "APPROVED"}}
+	workers := &fakeWorker{
+		t:        t,
+		rejected: map[string]bool{"second": true},
+		suggest:  map[string]string{"first": "Fix: add docs"},
+	}
+
+	if err := newParallelEngine(s, fake, workers, 2).Run(t.Context()); !errors.Is(err, ErrEscalated) {
+		t.Fatalf("expected escalation, got %v", err)
+	}
+
+	if _, err := os.Stat("second.txt"); !os.IsNotExist(err) {
+		t.Error("rejected work should not be merged")
+	}
+	if !git.BranchExists("autoclaude/todo-second") {
+		t.Error("rejected branch should be kept")
+	}
+
+	list, _ := state.LoadTodos()
+	second := list.Find("Second")
+	if second == nil || second.Checked || second.Status != state.StatusBlocked || !strings.Contains(strings.Join(second.SubBullets, "\n"), "Unmerged branch: autoclaude/todo-second") {
+		t.Errorf("rejected TODO should be blocked with its branch recorded, got %+v", second)
+	}
+	if third := list.Find("Third"); third.Checked {
+		t.Error("Third depends on the blocked TODO and should wait")
+	}
+	if suggested := list.Find("Fix: add docs"); suggested == nil || !suggested.Checked {
+		t.Errorf("worker's suggested TODO should be added and worked on, got:\n%s", list)
+	}
+	if s.Stats.TodosCompleted != 2 {
+		t.Errorf("expected 2 completed (First, suggestion), got %d", s.Stats.TodosCompleted)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	w := &prefixWriter{w: &buf, mu: &mu, prefix: "[a] "}
+
+	io.WriteString(w, "one\ntw")
+	io.WriteString(w, "o\nthree")
+	w.Flush()
+
+	want := "[a] one\n[a] two\n[a] three\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/git"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+// StashBranchPrefix prefixes the side branches exhausted attempts are moved to
+const StashBranchPrefix = "autoclaude/stash-"
+
+// blockCurrentTodo applies the on_exhausted policy to the current TODO's
+// code, then marks it blocked in TODO.md with the critic's last feedback
+func (e *Engine) blockCurrentTodo(review *state.CriticReview) error {
+	title := state.CurrentTodoTitle()
+	if err := e.rollBack(title); err != nil {
+		return err
+	}
+
+	list, err := state.LoadTodos()
+	if err != nil {
+		return err
+	}
+	if t := list.Find(title); t != nil {
+		blockTodo(t, e.exhaustedReason(), review)
+	}
+	return list.Save()
+}
+
+// rollBack undoes the exhausted TODO's commits on the current branch as the
+// on_exhausted policy says. The loop's own files are left as they are.
+func (e *Engine) rollBack(title string) error {
+	policy := e.opts.OnExhausted
+	if policy == "" || policy == config.ExhaustedKeep {
+		return nil
+	}
+	start := e.state.TodoStartCommit
+	if start == "" {
+		fmt.Println("  ⚠ No commit recorded from before the coder, keeping the last attempt")
+		return nil
+	}
+
+	if err := e.commitLastAttempt(title); err != nil {
+		return err
+	}
+	keep := []string{state.AutoclaudeDir, config.SettingsPath()}
+
+	switch policy {
+	case config.ExhaustedRevert:
+		if err := git.RestoreTree(start, fmt.Sprintf("autoclaude: revert %s", title), keep...); err != nil {
+			return fmt.Errorf("failed to revert %q: %w", title, err)
+		}
+		fmt.Printf("  ↺ Reverted changes since %s\n", start)
+
+	case config.ExhaustedStash:
+		branch := stashBranch(title)
+		if err := git.CreateBranch(branch); err != nil {
+			return fmt.Errorf("failed to create %s: %w", branch, err)
+		}
+		if err := git.ResetSoft(start); err != nil {
+			return fmt.Errorf("failed to reset to %s: %w", start, err)
+		}
+		if err := git.RestoreTree(start, fmt.Sprintf("autoclaude: stash %s onto %s", title, branch), keep...); err != nil {
+			return fmt.Errorf("failed to stash %q: %w", title, err)
+		}
+		fmt.Printf("  ↺ Moved the attempt onto %s\n", branch)
+
+	default:
+		return fmt.Errorf("unknown on_exhausted policy %q", policy)
+	}
+	return nil
+}
+
+// commitLastAttempt commits whatever the exhausted TODO left uncommitted, so
+// the attempt can be moved or reverted as a whole
+func (e *Engine) commitLastAttempt(title string) error {
+	what := fmt.Sprintf("the last attempt at %s", title)
+	violations, err := e.commitAll("autoclaude: " + what)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return refusedError(what, violations)
+	}
+	return nil
+}
+
+// stashBranch returns an unused side branch name for the TODO's attempt
+func stashBranch(title string) string {
+	id := (&state.Todo{Title: title}).ID()
+	branch := StashBranchPrefix + id
+	for n := 2; git.BranchExists(branch); n++ {
+		branch = StashBranchPrefix + id + "-" + strconv.Itoa(n)
+	}
+	return branch
+}
+
+// exhaustedReason explains why a TODO was blocked
+func (e *Engine) exhaustedReason() string {
+	return fmt.Sprintf("not approved after %d review attempts", e.opts.RetryLimit)
+}
+
+// blockTodo marks a TODO blocked, attaching the review's feedback if there is one
+func blockTodo(t *state.Todo, reason string, review *state.CriticReview) {
+	var feedback []string
+	if review != nil {
+		feedback = review.Feedback()
+	}
+	t.Block(reason, feedback)
+}
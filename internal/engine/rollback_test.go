@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/git"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+const rejectedReview = `---
+verdict: NEEDS_FIXES
+summary: Parser is still broken.
+issues:
+  - file: broken.txt
+    severity: high
+    description: wrong output
+---
+`
+
+// runExhausted runs a TODO whose fixes are never approved under the given policy
+func runExhausted(t *testing.T, policy string) *state.State {
+	t.Helper()
+	s := setupProject(t, "## Pending\n- [ ] **First** - Completion: done\n- [ ] **Second** - Completion: done\n  - Dependencies: First\n", "true")
+	fake := &fakeClaude{
+		t:        t,
+		verdicts: []string{rejectedReview, rejectedReview, rejectedReview},
+		onFixer:  func() { os.WriteFile("broken.txt", []byte("broken\n"), 0644) },
+	}
+
+	e := newTestEngine(s, fake)
+	e.opts.OnExhausted = policy
+	if err := e.Run(t.Context()); !errors.Is(err, ErrEscalated) {
+		t.Fatalf("expected escalation, got %v", err)
+	}
+
+	// The dependent waits for the blocked TODO, and the run escalates instead of evaluating
+	want := "coder critic fixer critic fixer critic"
+	if got := strings.Join(fake.phases, " "); got != want {
+		t.Errorf("phases = %q, want %q", got, want)
+	}
+	list, _ := state.LoadTodos()
+	first := list.Find("First")
+	if first == nil || first.Checked || first.Status != state.StatusBlocked {
+		t.Fatalf("exhausted TODO should be blocked, got:\n%s", list)
+	}
+	for _, want := range []string{
+		"Blocked: not approved after 3 review attempts",
+		"Critic feedback: Parser is still broken.",
+		"Critic feedback: [high] broken.txt: wrong output",
+	} {
+		if !strings.Contains(list.String(), want) {
+			t.Errorf("TODO.md missing %q:\n%s", want, list)
+		}
+	}
+	if s.TodoStartCommit != "" {
+		t.Errorf("start commit should be cleared, got %q", s.TodoStartCommit)
+	}
+	return s
+}
+
+func TestEngineExhaustedKeep(t *testing.T) {
+	runExhausted(t, config.ExhaustedKeep)
+	if _, err := os.Stat("broken.txt"); err != nil {
+		t.Error("keep should leave the last attempt in place")
+	}
+}
+
+func TestEngineExhaustedRevert(t *testing.T) {
+	runExhausted(t, config.ExhaustedRevert)
+	if _, err := os.Stat("broken.txt"); !os.IsNotExist(err) {
+		t.Error("revert should remove the attempt's files")
+	}
+	log := gitIn(t, ".", "log", "--format=%s")
+	if !strings.HasPrefix(log, "autoclaude: revert First\n") || !strings.Contains(log, "fix") {
+		t.Errorf("revert should be a new commit on top of the attempt, got:\n%s", log)
+	}
+}
+
+func TestEngineExhaustedStash(t *testing.T) {
+	runExhausted(t, config.ExhaustedStash)
+	if _, err := os.Stat("broken.txt"); !os.IsNotExist(err) {
+		t.Error("stash should remove the attempt's files")
+	}
+	if log := gitIn(t, ".", "log", "--format=%s"); log != "autoclaude: stash First onto autoclaude/stash-first\ninitial" {
+		t.Errorf("attempt commits should move off the branch, got:\n%s", log)
+	}
+	if !git.BranchExists("autoclaude/stash-first") {
+		t.Fatal("stash branch should exist")
+	}
+	if files := gitIn(t, ".", "ls-tree", "--name-only", "autoclaude/stash-first"); !strings.Contains(files, "broken.txt") {
+		t.Errorf("stash branch should hold the attempt, got:\n%s", files)
+	}
+}
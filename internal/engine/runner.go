@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/state"
+	"go.coldcutz.net/autoclaude/internal/tmux"
+)
+
+// NewRunner returns the runner for a backend (config.Backend*), running
+// command instead of claude if it is set
+func NewRunner(backend, command string) claude.Runner {
+	switch backend {
+	case config.BackendHeadless:
+		return claude.Headless{Command: command}
+	case config.BackendTmux:
+		return transcriptRunner{tmux.Runner{Command: command}}
+	default:
+		return transcriptRunner{claude.Interactive{Command: command}}
+	}
+}
+
+// transcriptRunner wraps a backend whose sessions report no structured
+// result. Usage is read back from the transcript the stop hook recorded.
+type transcriptRunner struct {
+	claude.Runner
+}
+
+// Start starts the session, forgetting the one the stop hook recorded last
+func (r transcriptRunner) Start(ctx context.Context, prompt string, opts claude.SessionOptions) (claude.Session, error) {
+	state.ClearLastSession()
+	// File times lag the clock a little, so the transcript of a session that
+	// ends at once could look older than its start
+	started := time.Now().Add(-time.Second)
+	session, err := r.Runner.Start(ctx, prompt, opts)
+	if err != nil {
+		return nil, err
+	}
+	return transcriptSession{session, started}, nil
+}
+
+type transcriptSession struct {
+	claude.Session
+	started time.Time
+}
+
+// LastActivity passes on the activity of the wrapped session, if it reports any
+func (s transcriptSession) LastActivity() time.Time {
+	if a, ok := s.Session.(claude.ActivityReporter); ok {
+		return a.LastActivity()
+	}
+	return time.Now()
+}
+
+// Wait waits for the session and reads its usage from the transcript. Usage
+// is read for sessions that failed or were stopped too, since they cost as
+// much as the ones that succeeded; those may have ended before the stop hook
+// recorded their transcript, so the latest one is looked for instead.
+func (s transcriptSession) Wait() (*claude.SessionResult, error) {
+	_, err := s.Session.Wait()
+
+	path, sessionID := "", ""
+	if info, infoErr := state.LoadLastSession(); infoErr == nil && info.TranscriptPath != "" {
+		path, sessionID = info.TranscriptPath, info.SessionID
+	} else if err != nil {
+		path, _ = claude.LatestTranscript(s.started)
+	}
+	if path == "" {
+		return &claude.SessionResult{}, err
+	}
+	result, parseErr := claude.ParseTranscript(path)
+	if parseErr != nil {
+		fmt.Printf("  ⚠ Could not read session usage: %v\n", parseErr)
+		return &claude.SessionResult{SessionID: sessionID}, err
+	}
+	return result, err
+}
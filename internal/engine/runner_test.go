@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+func TestTranscriptSessionFailedUsage(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+	t.Setenv("CLAUDE_CONFIG_DIR", filepath.Join(tmpDir, "config"))
+	os.MkdirAll(state.AutoclaudeDir, 0755)
+
+	dir, err := claude.TranscriptDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(dir, 0755)
+	transcript := `{"type":"assistant","sessionId":"s1","message":{"id":"msg_1","model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":1000,"output_tokens":100}}}` + "\n"
+	sessionErr := errors.New("claude exited with error: exit status 1")
+
+	for _, hook := range []bool{true, false} {
+		path := filepath.Join(dir, "s1.jsonl")
+		os.Remove(path)
+		runner := transcriptRunner{claude.RunnerFunc(func(context.Context, string, claude.SessionOptions) (*claude.SessionResult, error) {
+			os.WriteFile(path, []byte(transcript), 0644)
+			if hook {
+				state.SaveLastSession(state.SessionInfo{SessionID: "s1", TranscriptPath: path})
+			}
+			return nil, sessionErr
+		})}
+		session, err := runner.Start(context.Background(), "prompt", claude.SessionOptions{})
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		result, err := session.Wait()
+		if !errors.Is(err, sessionErr) {
+			t.Errorf("hook=%v: expected the session's error, got %v", hook, err)
+		}
+		if result == nil || result.Usage.InputTokens != 1000 || result.SessionID != "s1" {
+			t.Errorf("hook=%v: expected usage from the transcript, got %+v", hook, result)
+		}
+	}
+}
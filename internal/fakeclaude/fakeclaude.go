@@ -0,0 +1,377 @@
+// Package fakeclaude is a scriptable stand-in for the claude CLI, so the loop
+// can be tested end to end without a network or an Anthropic account.
+//
+// A test binary becomes the fake when it is run under the name "claude":
+// Install links it into a directory put first on PATH, and TestMain calls
+// RunIfInvoked before anything else. Each time the fake is run it plays the
+// next session of the scenario: it checks the prompt, writes files, checks off
+// a TODO, writes a critic verdict, commits, fires the project's Stop hooks
+// and reports the result the way the real CLI does.
+package fakeclaude
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+	"go.coldcutz.net/autoclaude/internal/config"
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+// ScenarioEnv names the environment variable holding the scenario file
+const ScenarioEnv = "AUTOCLAUDE_FAKE_SCENARIO"
+
+// Scenario scripts the sessions the fake plays, in order
+type Scenario struct {
+	Sessions []Session `json:"sessions"`
+}
+
+// Session scripts what one run of the fake does
+type Session struct {
+	Match     string            `json:"match,omitempty"`     // Text the prompt must contain, e.g. "code reviewer"
+	Write     map[string]string `json:"write,omitempty"`     // Files to write, relative to the project, including marker files
+	CheckTodo bool              `json:"checkTodo,omitempty"` // Check off the next TODO in TODO.md
+	Verdict   string            `json:"verdict,omitempty"`   // Written to the critic verdict file
+	Commit    string            `json:"commit,omitempty"`    // Commit every change with this message
+	Hooks     []string          `json:"hooks"`               // Stop hooks to fire, matched against their command; nil fires all of them
+	Result    string            `json:"result,omitempty"`    // Final assistant text
+	IsError   bool              `json:"isError,omitempty"`   // Report the session as failed
+	ExitCode  int               `json:"exitCode,omitempty"`  // Exit with this code after the session
+	Tokens    int               `json:"tokens,omitempty"`    // Input tokens to report; output tokens are a tenth of them
+	CostUSD   float64           `json:"costUsd,omitempty"`   // Cost to report in headless mode
+}
+
+// Call is one run of the fake, as logged for the test to inspect
+type Call struct {
+	Session  int      `json:"session"` // Index of the session played, or -1 if none was left
+	Args     []string `json:"args"`    // Flags, without the prompt
+	Prompt   string   `json:"prompt"`
+	Headless bool     `json:"headless"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// CallsPath returns the log of calls kept next to a scenario file
+func CallsPath(scenarioPath string) string {
+	return scenarioPath + ".calls"
+}
+
+// RunIfInvoked plays a session and exits if the running binary was started
+// as the fake claude. Call it first thing in TestMain.
+func RunIfInvoked() {
+	if filepath.Base(os.Args[0]) == "claude" && os.Getenv(ScenarioEnv) != "" {
+		os.Exit(Main(os.Args[1:]))
+	}
+}
+
+// Main plays the next session of the scenario named by ScenarioEnv and
+// returns the exit code
+func Main(args []string) int {
+	scenarioPath := os.Getenv(ScenarioEnv)
+	flags, prompt := splitArgs(args)
+	call := Call{Session: -1, Args: flags, Prompt: prompt, Headless: hasFlag(flags, "-p")}
+
+	// Interactive sessions are stopped by the hooks with SIGINT, which the
+	// real CLI takes as a request to exit cleanly
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+
+	session, err := nextSession(scenarioPath, &call)
+	if err == nil {
+		err = session.play(call, filepath.Dir(scenarioPath))
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	if logErr := logCall(scenarioPath, call); logErr != nil && err == nil {
+		err = logErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake claude: %v\n", err)
+		return 1
+	}
+
+	if !call.Headless && ownsPidFile() {
+		// The stop hook may already have sent SIGINT; give it a moment to arrive
+		select {
+		case <-interrupted:
+		case <-time.After(time.Second):
+		}
+	}
+	return session.ExitCode
+}
+
+// splitArgs separates the flags from the prompt, which follows "--"
+func splitArgs(args []string) ([]string, string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], strings.Join(args[i+1:], " ")
+		}
+	}
+	return args, ""
+}
+
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// nextSession returns the first session not yet played, going by the calls
+// logged so far
+func nextSession(scenarioPath string, call *Call) (*Session, error) {
+	if scenarioPath == "" {
+		return nil, fmt.Errorf("%s is not set", ScenarioEnv)
+	}
+	data, err := os.ReadFile(scenarioPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+	calls, err := LoadCalls(scenarioPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(calls) >= len(sc.Sessions) {
+		return nil, fmt.Errorf("no session left to play (all %d played)", len(sc.Sessions))
+	}
+	call.Session = len(calls)
+	s := sc.Sessions[call.Session]
+	if !strings.Contains(call.Prompt, s.Match) {
+		return nil, fmt.Errorf("session %d expects a prompt containing %q", call.Session, s.Match)
+	}
+	return &s, nil
+}
+
+// play carries out the session's script in the current directory. Files that
+// aren't part of the project, like transcripts, are kept in dir.
+func (s *Session) play(call Call, dir string) error {
+	if !call.Headless {
+		// Hooks find the session to stop through the PID file, which is
+		// written once the process has started
+		if err := waitForPidFile(); err != nil {
+			return err
+		}
+	}
+
+	for name, content := range s.Write {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	if s.CheckTodo {
+		list, err := state.LoadTodos()
+		if err != nil {
+			return err
+		}
+		next, err := list.Next()
+		if err != nil || next == nil {
+			return fmt.Errorf("no TODO to check off: %v", err)
+		}
+		next.Checked = true
+		if err := list.Save(); err != nil {
+			return err
+		}
+	}
+	if s.Verdict != "" {
+		if err := os.WriteFile(state.CriticVerdictPath(), []byte(s.Verdict), 0644); err != nil {
+			return err
+		}
+	}
+	if s.Commit != "" {
+		if out, err := exec.Command("git", "add", "-A").CombinedOutput(); err != nil {
+			return fmt.Errorf("git add failed: %s", out)
+		}
+		if out, err := exec.Command("git", "commit", "--allow-empty", "-m", s.Commit).CombinedOutput(); err != nil {
+			return fmt.Errorf("git commit failed: %s", out)
+		}
+	}
+
+	sessionID := fmt.Sprintf("fake-session-%d", call.Session)
+	transcript, err := s.writeTranscript(dir, sessionID)
+	if err != nil {
+		return err
+	}
+	if err := s.fireStopHooks(sessionID, transcript); err != nil {
+		return err
+	}
+	if call.Headless {
+		return s.writeStream(sessionID)
+	}
+	return nil
+}
+
+// model is reported as the model of every session
+const model = "claude-sonnet-4-5"
+
+func (s *Session) usage() claude.Usage {
+	return claude.Usage{InputTokens: s.Tokens, OutputTokens: s.Tokens / 10}
+}
+
+// writeTranscript writes a transcript like the one interactive sessions leave
+// behind, so usage can be read back from it
+func (s *Session) writeTranscript(dir, sessionID string) (string, error) {
+	path, err := filepath.Abs(filepath.Join(dir, sessionID+".jsonl"))
+	if err != nil {
+		return "", err
+	}
+	u := s.usage()
+	entry := map[string]any{
+		"type":      "assistant",
+		"sessionId": sessionID,
+		"message": map[string]any{
+			"id":      "msg-" + sessionID,
+			"model":   model,
+			"content": []claude.ContentBlock{{Type: "text", Text: s.Result}},
+			"usage":   u,
+		},
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// fireStopHooks runs the project's Stop hooks the way Claude Code does when
+// a session stops
+func (s *Session) fireStopHooks(sessionID, transcript string) error {
+	settings, err := config.LoadExisting()
+	if err != nil || settings.Hooks == nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	input, _ := json.Marshal(map[string]any{
+		"session_id":       sessionID,
+		"transcript_path":  transcript,
+		"hook_event_name":  "Stop",
+		"stop_hook_active": false,
+	})
+	for _, hc := range settings.Hooks.Stop {
+		for _, h := range hc.Hooks {
+			if h.Type != "command" || !s.firesHook(h.Command) {
+				continue
+			}
+			cmd := exec.Command("sh", "-c", h.Command)
+			cmd.Stdin = strings.NewReader(string(input))
+			cmd.Env = append(os.Environ(), "CLAUDE_PROJECT_DIR="+cwd)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("stop hook %q failed: %v\n%s", h.Command, err, out)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Session) firesHook(command string) bool {
+	if s.Hooks == nil {
+		return true
+	}
+	for _, name := range s.Hooks {
+		if strings.Contains(command, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeStream prints the session as `--output-format stream-json` does
+func (s *Session) writeStream(sessionID string) error {
+	u := s.usage()
+	subtype := "success"
+	if s.IsError {
+		subtype = "error_during_execution"
+	}
+	events := []claude.StreamEvent{
+		{Type: "system", Subtype: "init", SessionID: sessionID, Model: model},
+		{Type: "assistant", SessionID: sessionID, Message: &claude.StreamMessage{
+			Model:   model,
+			Content: []claude.ContentBlock{{Type: "text", Text: s.Result}},
+			Usage:   &u,
+		}},
+		{Type: "result", Subtype: subtype, SessionID: sessionID, Result: s.Result, IsError: s.IsError,
+			NumTurns: 1, DurationMs: 1, TotalCostUSD: s.CostUSD, Usage: &u},
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForPidFile waits for the loop to record this process as the running
+// Claude session
+func waitForPidFile() error {
+	deadline := time.Now().Add(5 * time.Second)
+	for !ownsPidFile() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s was not written", claude.PidFile)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func ownsPidFile() bool {
+	rec, err := claude.ReadPidFile()
+	return err == nil && rec.PID == os.Getpid() && rec.Running()
+}
+
+// LoadCalls reads the calls logged next to a scenario file
+func LoadCalls(scenarioPath string) ([]Call, error) {
+	data, err := os.ReadFile(CallsPath(scenarioPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var calls []Call
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var c Call
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			return nil, fmt.Errorf("failed to parse call log: %w", err)
+		}
+		calls = append(calls, c)
+	}
+	return calls, nil
+}
+
+func logCall(scenarioPath string, call Call) error {
+	if scenarioPath == "" {
+		return nil
+	}
+	data, err := json.Marshal(call)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(CallsPath(scenarioPath), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
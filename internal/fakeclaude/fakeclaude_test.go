@@ -0,0 +1,41 @@
+package fakeclaude
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMainChecksPrompt(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	scenarioPath := filepath.Join(tmpDir, "scenario.json")
+	data, _ := json.Marshal(Scenario{Sessions: []Session{
+		{Match: "code reviewer", Verdict: "APPROVED", Hooks: []string{}},
+	}})
+	os.WriteFile(scenarioPath, data, 0644)
+	t.Setenv(ScenarioEnv, scenarioPath)
+
+	if code := Main([]string{"-p", "--", "You are fixing issues"}); code != 1 {
+		t.Errorf("expected exit 1 for an unexpected prompt, got %d", code)
+	}
+	if code := Main([]string{"-p", "--", "You are a code reviewer"}); code != 1 {
+		t.Errorf("expected exit 1 once every session was played, got %d", code)
+	}
+
+	calls, err := LoadCalls(scenarioPath)
+	if err != nil {
+		t.Fatalf("LoadCalls failed: %v", err)
+	}
+	if len(calls) != 2 || !strings.Contains(calls[0].Error, `expects a prompt containing "code reviewer"`) || calls[1].Session != -1 {
+		t.Errorf("unexpected calls %+v", calls)
+	}
+	if !calls[0].Headless || calls[0].Prompt != "You are fixing issues" || len(calls[0].Args) != 1 {
+		t.Errorf("unexpected first call %+v", calls[0])
+	}
+}
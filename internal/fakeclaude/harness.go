@@ -0,0 +1,68 @@
+package fakeclaude
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Harness is a fake claude installed for one test
+type Harness struct {
+	t            testing.TB
+	ScenarioPath string
+}
+
+// Install puts the running test binary first on PATH as "claude", playing
+// the given scenario. The test's TestMain must call RunIfInvoked.
+func Install(t testing.TB, sc Scenario) *Harness {
+	t.Helper()
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to find test binary: %v", err)
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(self, filepath.Join(bin, "claude")); err != nil {
+		t.Fatalf("failed to link fake claude: %v", err)
+	}
+
+	h := &Harness{t: t, ScenarioPath: filepath.Join(dir, "scenario.json")}
+	data, err := json.Marshal(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(h.ScenarioPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv(ScenarioEnv, h.ScenarioPath)
+	return h
+}
+
+// Calls returns the runs of the fake so far
+func (h *Harness) Calls() []Call {
+	h.t.Helper()
+	calls, err := LoadCalls(h.ScenarioPath)
+	if err != nil {
+		h.t.Fatalf("failed to load fake claude calls: %v", err)
+	}
+	return calls
+}
+
+// Played checks that every scripted session was played, without errors
+func (h *Harness) Played(sc Scenario) {
+	h.t.Helper()
+	calls := h.Calls()
+	for _, c := range calls {
+		if c.Error != "" {
+			h.t.Errorf("session %d failed: %s", c.Session, c.Error)
+		}
+	}
+	if len(calls) != len(sc.Sessions) {
+		h.t.Errorf("expected %d sessions to be played, got %d", len(sc.Sessions), len(calls))
+	}
+}
@@ -0,0 +1,45 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CommitHash returns the current HEAD commit hash (short form)
+func CommitHash() string {
+	cmd := exec.Command("git", "rev-parse", "--short", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// ForceCommit stages all changes and commits them
+func ForceCommit(phase string) {
+	// Check if there are any changes to commit
+	statusCmd := exec.Command("git", "status", "--porcelain")
+	output, err := statusCmd.Output()
+	if err != nil || len(strings.TrimSpace(string(output))) == 0 {
+		fmt.Println("  (no changes to commit)")
+		return
+	}
+
+	// Stage all changes
+	addCmd := exec.Command("git", "add", "-A")
+	if err := addCmd.Run(); err != nil {
+		fmt.Printf("  ✗ Failed to stage changes: %v\n", err)
+		return
+	}
+
+	// Commit
+	msg := fmt.Sprintf("autoclaude: %s changes (auto-committed)", strings.ToLower(phase))
+	commitCmd := exec.Command("git", "commit", "-m", msg)
+	if err := commitCmd.Run(); err != nil {
+		fmt.Printf("  ✗ Failed to commit: %v\n", err)
+		return
+	}
+
+	fmt.Printf("  ✓ Auto-committed as: %s\n", CommitHash())
+}
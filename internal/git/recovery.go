@@ -0,0 +1,96 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// RecoveryBranchPrefix prefixes the branches uncommitted changes are saved to
+const RecoveryBranchPrefix = "autoclaude/recovery-"
+
+// Snapshot saves all uncommitted and untracked changes (but not ignored files)
+// as a commit on top of HEAD in a new recovery-<timestamp> branch. The
+// working tree, index and current branch are left untouched. Returns the
+// branch name.
+func Snapshot(message string) (string, error) {
+	index, err := os.CreateTemp("", "autoclaude-index-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	index.Close()
+	// git wants to create the index itself
+	os.Remove(index.Name())
+	defer os.Remove(index.Name())
+
+	// Build the tree in a separate index so that the real one keeps what the user staged
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+	if _, err := runEnv("", env, "read-tree", "HEAD"); err != nil {
+		return "", err
+	}
+	if _, err := runEnv("", env, "add", "-A"); err != nil {
+		return "", err
+	}
+	tree, err := runEnv("", env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	commit, err := run("", "commit-tree", tree, "-p", "HEAD", "-m", message)
+	if err != nil {
+		return "", err
+	}
+
+	stamp := time.Now().Format("20060102-150405")
+	branch := RecoveryBranchPrefix + stamp
+	for n := 2; BranchExists(branch); n++ {
+		branch = fmt.Sprintf("%s%s-%d", RecoveryBranchPrefix, stamp, n)
+	}
+	if _, err := run("", "branch", branch, commit); err != nil {
+		return "", err
+	}
+	return branch, nil
+}
+
+// SnapshotFiles lists the changes saved in a recovery branch as
+// "<status> <path>" lines, e.g. "M main.go"
+func SnapshotFiles(branch string) ([]string, error) {
+	out, err := run("", "diff", "--name-status", branch+"^", branch)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(out, "\n") {
+		files = append(files, strings.ReplaceAll(line, "\t", " "))
+	}
+	return files, nil
+}
+
+// RecoveryBranches returns the recovery branches, newest first
+func RecoveryBranches() ([]string, error) {
+	out, err := run("", "for-each-ref", "--sort=-refname", "--format=%(refname:short)", "refs/heads/"+RecoveryBranchPrefix+"*")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// RestoreSnapshot applies the changes saved in a recovery branch to the
+// working tree as uncommitted changes. The working tree must be clean; if the
+// changes don't apply cleanly it is left clean and an error is returned.
+func RestoreSnapshot(branch string) error {
+	if out, err := run("", "status", "--porcelain"); err != nil {
+		return err
+	} else if out != "" {
+		return fmt.Errorf("working tree has uncommitted changes; commit or stash them first")
+	}
+	if _, err := run("", "cherry-pick", "--no-commit", branch); err != nil {
+		// The tree was clean, so resetting only drops the partial apply
+		run("", "cherry-pick", "--abort")
+		run("", "reset", "--hard", "--quiet")
+		return fmt.Errorf("changes in %s don't apply to the current HEAD: %w", branch, err)
+	}
+	// Unstage, so the changes are back to being uncommitted edits
+	_, err := run("", "reset", "--quiet")
+	return err
+}
@@ -0,0 +1,81 @@
+package git
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotAndRestore(t *testing.T) {
+	setupRepo(t)
+	os.WriteFile("shared.txt", []byte("edited\n"), 0644)
+	os.WriteFile("untracked.txt", []byte("new"), 0644)
+	os.Remove("scratch/state.txt")
+	run("", "add", "shared.txt")
+	head := CommitHash()
+
+	branch, err := Snapshot("save")
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if !strings.HasPrefix(branch, RecoveryBranchPrefix) {
+		t.Errorf("unexpected branch %q", branch)
+	}
+	if CommitHash() != head {
+		t.Error("Snapshot should not move HEAD")
+	}
+	if staged, _ := run("", "diff", "--cached", "--name-only"); staged != "shared.txt" {
+		t.Errorf("Snapshot should leave the index alone, got %q", staged)
+	}
+
+	files, err := SnapshotFiles(branch)
+	if err != nil || strings.Join(files, ",") != "D scratch/state.txt,M shared.txt,A untracked.txt" {
+		t.Errorf("SnapshotFiles = %v, %v", files, err)
+	}
+	if branches, _ := RecoveryBranches(); len(branches) != 1 || branches[0] != branch {
+		t.Errorf("RecoveryBranches = %v", branches)
+	}
+
+	if err := RestoreSnapshot(branch); err == nil {
+		t.Error("restoring over uncommitted changes should fail")
+	}
+	run("", "reset", "--hard", "--quiet")
+	run("", "clean", "-fd")
+
+	if err := RestoreSnapshot(branch); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if data, _ := os.ReadFile("shared.txt"); string(data) != "edited\n" {
+		t.Errorf("modified file not restored, got %q", data)
+	}
+	if data, _ := os.ReadFile("untracked.txt"); string(data) != "new" {
+		t.Errorf("untracked file not restored, got %q", data)
+	}
+	if _, err := os.Stat("scratch/state.txt"); !os.IsNotExist(err) {
+		t.Error("deleted file should be deleted again")
+	}
+	if CommitHash() != head {
+		t.Error("RestoreSnapshot should not commit")
+	}
+}
+
+func TestRestoreSnapshotConflict(t *testing.T) {
+	setupRepo(t)
+	os.WriteFile("shared.txt", []byte("saved\n"), 0644)
+	branch, err := Snapshot("save")
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	run("", "checkout", "--", ".")
+	commitIn(t, ".", "shared.txt", "moved on\n")
+
+	if err := RestoreSnapshot(branch); err == nil {
+		t.Fatal("expected a conflict")
+	}
+	if status, _ := run("", "status", "--porcelain"); status != "" {
+		t.Errorf("failed restore should leave the tree clean, got %q", status)
+	}
+	if data, _ := os.ReadFile("shared.txt"); string(data) != "moved on\n" {
+		t.Errorf("committed content should be kept, got %q", data)
+	}
+}
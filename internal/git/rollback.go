@@ -0,0 +1,46 @@
+package git
+
+import "strings"
+
+// CreateBranch creates a branch at the current HEAD without switching to it
+func CreateBranch(branch string) error {
+	_, err := run("", "branch", branch)
+	return err
+}
+
+// ResetSoft moves the current branch to commit, keeping the index and
+// working tree as they are
+func ResetSoft(commit string) error {
+	_, err := run("", "reset", "--soft", commit)
+	return err
+}
+
+// RestoreTree makes the index and working tree match commit, except for the
+// keep paths, and commits the result with the given message. Files added
+// since commit are removed. Does nothing if there is no difference.
+func RestoreTree(commit, message string, keep ...string) error {
+	pathspec := []string{"--", "."}
+	for _, path := range keep {
+		pathspec = append(pathspec, ":(exclude)"+path)
+	}
+
+	added, err := run("", append([]string{"diff", "--cached", "--name-only", "--diff-filter=A", commit}, pathspec...)...)
+	if err != nil {
+		return err
+	}
+	if added != "" {
+		args := append([]string{"rm", "-r", "-f", "--quiet", "--"}, strings.Split(added, "\n")...)
+		if _, err := run("", args...); err != nil {
+			return err
+		}
+	}
+	if _, err := run("", append([]string{"checkout", commit}, pathspec...)...); err != nil {
+		return err
+	}
+
+	if _, err := run("", "diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	_, err = run("", "commit", "-m", message)
+	return err
+}
@@ -0,0 +1,75 @@
+package git
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRestoreTree(t *testing.T) {
+	setupRepo(t)
+	start := CommitHash()
+
+	commitIn(t, ".", "shared.txt", "broken\n")
+	commitIn(t, ".", "new/file.txt", "new")
+	commitIn(t, ".", "scratch/state.txt", "loop")
+
+	if err := RestoreTree(start, "revert", "scratch"); err != nil {
+		t.Fatalf("RestoreTree failed: %v", err)
+	}
+	if data, _ := os.ReadFile("shared.txt"); string(data) != "base\n" {
+		t.Errorf("modified file should be restored, got %q", data)
+	}
+	if _, err := os.Stat("new/file.txt"); !os.IsNotExist(err) {
+		t.Error("added file should be removed")
+	}
+	if data, _ := os.ReadFile("scratch/state.txt"); string(data) != "loop" {
+		t.Errorf("kept paths should not be restored, got %q", data)
+	}
+	if log, _ := run("", "log", "-1", "--format=%s"); log != "revert" {
+		t.Errorf("expected a revert commit, got %q", log)
+	}
+	if status, _ := run("", "status", "--porcelain"); status != "" {
+		t.Errorf("working tree should be clean, got %q", status)
+	}
+
+	// Nothing left to restore
+	head := CommitHash()
+	if err := RestoreTree(start, "again", "scratch"); err != nil || CommitHash() != head {
+		t.Errorf("restoring a matching tree should not commit: %v", err)
+	}
+}
+
+func TestStashOntoBranch(t *testing.T) {
+	setupRepo(t)
+	start := CommitHash()
+	commitIn(t, ".", "shared.txt", "broken\n")
+	commitIn(t, ".", "new.txt", "new")
+	commitIn(t, ".", "scratch/state.txt", "loop")
+	attempt := CommitHash()
+
+	if err := CreateBranch("side"); err != nil {
+		t.Fatalf("CreateBranch failed: %v", err)
+	}
+	if err := ResetSoft(start); err != nil {
+		t.Fatalf("ResetSoft failed: %v", err)
+	}
+	if err := RestoreTree(start, "stash", "scratch"); err != nil {
+		t.Fatalf("RestoreTree failed: %v", err)
+	}
+
+	if log, _ := run("", "log", "--format=%s"); log != "stash\ninitial" {
+		t.Errorf("attempt commits should leave the branch, got:\n%s", log)
+	}
+	if data, _ := os.ReadFile("shared.txt"); string(data) != "base\n" {
+		t.Errorf("code should be restored, got %q", data)
+	}
+	if _, err := os.Stat("new.txt"); !os.IsNotExist(err) {
+		t.Error("added file should be removed")
+	}
+	if data, _ := os.ReadFile("scratch/state.txt"); string(data) != "loop" {
+		t.Errorf("kept paths should stay, got %q", data)
+	}
+	if side, _ := run("", "rev-parse", "--short", "side"); side != attempt {
+		t.Errorf("side branch should point at the attempt, got %s want %s", side, attempt)
+	}
+}
@@ -0,0 +1,41 @@
+package git
+
+import (
+	"strconv"
+	"strings"
+)
+
+// StageAll stages every change in the working tree, including untracked files
+func StageAll() error {
+	_, err := run("", "add", "-A")
+	return err
+}
+
+// Unstage removes all changes from the index, keeping them in the working tree
+func Unstage() error {
+	_, err := run("", "reset", "--quiet")
+	return err
+}
+
+// StagedFiles returns the paths added, copied, modified or renamed in the index
+func StagedFiles() ([]string, error) {
+	out, err := run("", "diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(out, "\x00"), "\x00"), nil
+}
+
+// StagedDiff returns the staged changes as a unified diff without context lines
+func StagedDiff() (string, error) {
+	return run("", "diff", "--cached", "-U0", "--no-color", "--no-ext-diff")
+}
+
+// StagedSize returns the size in bytes of a file as staged
+func StagedSize(path string) (int64, error) {
+	out, err := run("", "cat-file", "-s", ":"+path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(out, 10, 64)
+}
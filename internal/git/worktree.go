@@ -0,0 +1,237 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrAbortFailed is wrapped by the error of a merge or rebase that failed and
+// couldn't be aborted either, leaving the working tree mid-way through it
+var ErrAbortFailed = errors.New("failed to abort")
+
+// run runs git in dir ("" for the current directory) and returns its trimmed output
+func run(dir string, args ...string) (string, error) {
+	return runEnv(dir, nil, args...)
+}
+
+// runEnv is run with extra environment variables
+func runEnv(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	output, err := cmd.CombinedOutput()
+	out := strings.TrimSpace(string(output))
+	if err != nil {
+		if out != "" {
+			return out, fmt.Errorf("git %s: %w: %s", args[0], err, out)
+		}
+		return out, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// BranchExists reports whether a local branch exists
+func BranchExists(branch string) bool {
+	_, err := run("", "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	return err == nil
+}
+
+// AddWorktree checks out branch in a new worktree at path, creating the branch
+// from base if it doesn't exist yet
+func AddWorktree(path, branch, base string) error {
+	if BranchExists(branch) {
+		_, err := run("", "worktree", "add", path, branch)
+		return err
+	}
+	_, err := run("", "worktree", "add", "-b", branch, path, base)
+	return err
+}
+
+// RemoveWorktree removes the worktree at path, discarding uncommitted changes
+func RemoveWorktree(path string) error {
+	if _, err := run("", "worktree", "remove", "--force", path); err != nil {
+		// The directory may be gone or never registered; clean up what's left
+		os.RemoveAll(path)
+		_, pruneErr := run("", "worktree", "prune")
+		return pruneErr
+	}
+	return nil
+}
+
+// DeleteBranch force-deletes a local branch
+func DeleteBranch(branch string) error {
+	_, err := run("", "branch", "-D", branch)
+	return err
+}
+
+// SetBranchDescription records what a branch is for in the repository's config
+func SetBranchDescription(branch, description string) error {
+	_, err := run("", "config", "branch."+branch+".description", description)
+	return err
+}
+
+// BranchDescription returns what was recorded for a branch, "" if nothing
+func BranchDescription(branch string) string {
+	out, err := run("", "config", "--get", "branch."+branch+".description")
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
+// MergeBase returns the best common ancestor of two commits
+func MergeBase(a, b string) (string, error) {
+	return run("", "merge-base", a, b)
+}
+
+// ResetPaths commits a change in the worktree at dir that restores the paths
+// to how they were at base, so the branch no longer touches them. Does
+// nothing if the paths are unchanged.
+func ResetPaths(dir, base string, paths ...string) error {
+	for _, path := range paths {
+		if _, err := run(dir, "rm", "-r", "--cached", "--quiet", "--ignore-unmatch", "--", path); err != nil {
+			return err
+		}
+		if !pathExistsAt(dir, base, path) {
+			continue
+		}
+		if _, err := run(dir, "checkout", base, "--", path); err != nil {
+			return err
+		}
+	}
+	if _, err := run(dir, "diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	_, err := run(dir, "commit", "-m", "autoclaude: restore "+strings.Join(paths, ", "))
+	return err
+}
+
+// pathExistsAt reports whether path exists in the given commit
+func pathExistsAt(dir, commit, path string) bool {
+	out, err := run(dir, "ls-tree", "--name-only", commit, "--", path)
+	return err == nil && out != ""
+}
+
+// IsAncestor reports whether commit a is an ancestor of, or the same as, commit b
+func IsAncestor(a, b string) bool {
+	_, err := run("", "merge-base", "--is-ancestor", a, b)
+	return err == nil
+}
+
+// Rebase rebases the branch checked out in dir ("" for the current
+// directory) onto another commit. If the rebase conflicts it is aborted and
+// the conflicting files are returned.
+func Rebase(dir, onto string) ([]string, error) {
+	if _, rebaseErr := run(dir, "rebase", onto); rebaseErr != nil {
+		out, err := run(dir, "diff", "--name-only", "--diff-filter=U")
+		if _, abortErr := run(dir, "rebase", "--abort"); abortErr != nil {
+			return nil, fmt.Errorf("%w rebase: %w", ErrAbortFailed, abortErr)
+		}
+		if err != nil || out == "" {
+			return nil, rebaseErr
+		}
+		return strings.Split(out, "\n"), nil
+	}
+	return nil, nil
+}
+
+// Merge merges branch into the current branch, either with a merge commit or
+// squashed into a single commit. If the merge conflicts it is aborted and the
+// conflicting files are returned.
+func Merge(branch, message string, squash bool) ([]string, error) {
+	args := []string{"merge", "--no-ff", "-m", message, branch}
+	if squash {
+		args = []string{"merge", "--squash", branch}
+	}
+	if _, mergeErr := run("", args...); mergeErr != nil {
+		out, err := run("", "diff", "--name-only", "--diff-filter=U")
+		if _, resetErr := run("", "reset", "--merge"); resetErr != nil {
+			return nil, fmt.Errorf("%w merge: %w", ErrAbortFailed, resetErr)
+		}
+		if err != nil || out == "" {
+			return nil, mergeErr
+		}
+		return strings.Split(out, "\n"), nil
+	}
+
+	if squash {
+		if _, err := run("", "diff", "--cached", "--quiet"); err == nil {
+			return nil, nil // Nothing to commit
+		}
+		if _, err := run("", "commit", "-m", message); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// Exclude adds a pattern to the repository's info/exclude file, which works
+// like .gitignore without touching tracked files
+func Exclude(pattern string) error {
+	gitDir, err := run("", "rev-parse", "--git-common-dir")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(gitDir, "info", "exclude")
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if slices.Contains(strings.Split(string(data), "\n"), pattern) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return os.WriteFile(path, []byte(content+pattern+"\n"), 0644)
+}
+
+// CurrentBranch returns the name of the checked-out branch, or an error if
+// HEAD is detached
+func CurrentBranch() (string, error) {
+	return run("", "symbolic-ref", "--short", "HEAD")
+}
+
+// Checkout switches to a branch, creating it from HEAD if create is set
+func Checkout(branch string, create bool) error {
+	if create {
+		_, err := run("", "checkout", "-b", branch)
+		return err
+	}
+	_, err := run("", "checkout", branch)
+	return err
+}
+
+// CommitSubjects returns the subjects of the commits in to but not in from, oldest first
+func CommitSubjects(from, to string) ([]string, error) {
+	out, err := run("", "log", "--reverse", "--format=%s", from+".."+to)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// CommitAll stages and commits all changes, if there are any
+func CommitAll(message string) error {
+	if out, err := run("", "status", "--porcelain"); err != nil || out == "" {
+		return err
+	}
+	if _, err := run("", "add", "-A"); err != nil {
+		return err
+	}
+	_, err := run("", "commit", "-m", message)
+	return err
+}
@@ -0,0 +1,203 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupRepo creates a git repo with one commit in a temp dir and changes into it
+func setupRepo(t *testing.T) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	t.Cleanup(func() { os.Chdir(oldDir) })
+
+	exec.Command("git", "init").Run()
+	exec.Command("git", "config", "user.email", "test@test.com").Run()
+	exec.Command("git", "config", "user.name", "Test").Run()
+	os.MkdirAll("scratch", 0755)
+	os.WriteFile("scratch/state.txt", []byte("base"), 0644)
+	os.WriteFile("shared.txt", []byte("base\n"), 0644)
+	exec.Command("git", "add", ".").Run()
+	exec.Command("git", "commit", "-m", "initial").Run()
+}
+
+// commitIn writes a file in dir and commits everything
+func commitIn(t *testing.T, dir, file, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755)
+	os.WriteFile(filepath.Join(dir, file), []byte(content), 0644)
+	if _, err := run(dir, "add", "-A"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(dir, "commit", "-m", "change "+file); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorktreeMerge(t *testing.T) {
+	setupRepo(t)
+	base := CommitHash()
+
+	if err := AddWorktree("wt", "feature", base); err != nil {
+		t.Fatalf("AddWorktree failed: %v", err)
+	}
+	if !BranchExists("feature") {
+		t.Fatal("AddWorktree should create the branch")
+	}
+	commitIn(t, "wt", "feature.txt", "feature")
+	commitIn(t, "wt", "scratch/state.txt", "worker")
+	commitIn(t, "wt", "scratch/extra.txt", "worker")
+
+	if err := ResetPaths("wt", base, "scratch", "missing"); err != nil {
+		t.Fatalf("ResetPaths failed: %v", err)
+	}
+	if err := RemoveWorktree("wt"); err != nil {
+		t.Fatalf("RemoveWorktree failed: %v", err)
+	}
+
+	conflicts, err := Merge("feature", "merge feature", false)
+	if err != nil || len(conflicts) > 0 {
+		t.Fatalf("Merge failed: %v %v", conflicts, err)
+	}
+	if _, err := os.Stat("feature.txt"); err != nil {
+		t.Error("merge should bring in the branch's changes")
+	}
+	if data, _ := os.ReadFile("scratch/state.txt"); string(data) != "base" {
+		t.Errorf("reset paths should not be merged, got %q", data)
+	}
+	if _, err := os.Stat("scratch/extra.txt"); !os.IsNotExist(err) {
+		t.Error("files added under a reset path should not be merged")
+	}
+	if err := DeleteBranch("feature"); err != nil || BranchExists("feature") {
+		t.Errorf("DeleteBranch failed: %v", err)
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	setupRepo(t)
+	base := CommitHash()
+
+	if err := AddWorktree("wt", "feature", base); err != nil {
+		t.Fatalf("AddWorktree failed: %v", err)
+	}
+	commitIn(t, "wt", "shared.txt", "feature\n")
+	RemoveWorktree("wt")
+	commitIn(t, ".", "shared.txt", "main\n")
+
+	conflicts, err := Merge("feature", "merge feature", false)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if strings.Join(conflicts, ",") != "shared.txt" {
+		t.Errorf("expected conflict in shared.txt, got %v", conflicts)
+	}
+	if data, _ := os.ReadFile("shared.txt"); string(data) != "main\n" {
+		t.Errorf("conflicting merge should be aborted, got %q", data)
+	}
+	if status, _ := run("", "status", "--porcelain"); status != "" {
+		t.Errorf("working tree should be clean after abort, got %q", status)
+	}
+}
+
+func TestExclude(t *testing.T) {
+	setupRepo(t)
+
+	for range 2 {
+		if err := Exclude("/scratch/tmp/"); err != nil {
+			t.Fatalf("Exclude failed: %v", err)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(".git", "info", "exclude"))
+	if strings.Count(string(data), "/scratch/tmp/") != 1 {
+		t.Errorf("pattern should be added once, got:\n%s", data)
+	}
+
+	os.MkdirAll("scratch/tmp", 0755)
+	os.WriteFile("scratch/tmp/x", []byte("x"), 0644)
+	if status, _ := run("", "status", "--porcelain"); status != "" {
+		t.Errorf("excluded files should not show up, got %q", status)
+	}
+}
+
+func TestSquashMerge(t *testing.T) {
+	setupRepo(t)
+	base, err := CurrentBranch()
+	if err != nil {
+		t.Fatalf("CurrentBranch failed: %v", err)
+	}
+
+	if err := Checkout("feature", true); err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	commitIn(t, ".", "a.txt", "a")
+	commitIn(t, ".", "b.txt", "b")
+	subjects, err := CommitSubjects(base, "feature")
+	if err != nil || strings.Join(subjects, ",") != "change a.txt,change b.txt" {
+		t.Errorf("CommitSubjects = %v, %v", subjects, err)
+	}
+
+	if err := Checkout(base, false); err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if conflicts, err := Merge("feature", "squashed", true); err != nil || len(conflicts) > 0 {
+		t.Fatalf("Merge failed: %v %v", conflicts, err)
+	}
+	if log, _ := run("", "log", "--format=%s"); log != "squashed\ninitial" {
+		t.Errorf("expected one squashed commit, got:\n%s", log)
+	}
+
+	// Merging again has nothing to commit
+	if _, err := Merge("feature", "again", true); err != nil {
+		t.Errorf("empty squash should succeed, got %v", err)
+	}
+	if log, _ := run("", "log", "-1", "--format=%s"); log != "squashed" {
+		t.Errorf("empty squash should not commit, got %q", log)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	setupRepo(t)
+	base := CommitHash()
+
+	if err := AddWorktree("wt", "feature", base); err != nil {
+		t.Fatalf("AddWorktree failed: %v", err)
+	}
+	commitIn(t, "wt", "feature.txt", "feature")
+	commitIn(t, ".", "main.txt", "main")
+	main := CommitHash()
+	if IsAncestor(main, "feature") {
+		t.Fatal("feature should not be based on the new commit yet")
+	}
+
+	if conflicts, err := Rebase("wt", main); err != nil || len(conflicts) > 0 {
+		t.Fatalf("Rebase failed: %v %v", conflicts, err)
+	}
+	if !IsAncestor(main, "feature") {
+		t.Error("feature should be rebased onto the new commit")
+	}
+	if _, err := os.Stat("wt/main.txt"); err != nil {
+		t.Error("the worktree should have the new commit's changes")
+	}
+
+	commitIn(t, "wt", "shared.txt", "feature\n")
+	commitIn(t, ".", "shared.txt", "main\n")
+	before, _ := run("", "rev-parse", "feature")
+	conflicts, err := Rebase("wt", CommitHash())
+	if err != nil {
+		t.Fatalf("Rebase failed: %v", err)
+	}
+	if strings.Join(conflicts, ",") != "shared.txt" {
+		t.Errorf("expected conflict in shared.txt, got %v", conflicts)
+	}
+	if after, _ := run("", "rev-parse", "feature"); after != before {
+		t.Error("conflicting rebase should be aborted")
+	}
+	if status, _ := run("wt", "status", "--porcelain"); status != "" {
+		t.Errorf("worktree should be clean after abort, got %q", status)
+	}
+}
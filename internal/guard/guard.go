@@ -0,0 +1,136 @@
+// Package guard checks staged changes before autoclaude commits them on
+// Claude's behalf, so secrets, huge generated files and files outside the
+// project's allowed paths don't end up in history.
+package guard
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"go.coldcutz.net/autoclaude/internal/git"
+)
+
+// Policy configures what the guard refuses to commit
+type Policy struct {
+	MaxFileBytes int64    // Largest file that may be committed, 0 for no limit
+	AllowedPaths []string // Globs of the paths that may be committed, empty allows all (see Match)
+}
+
+// Violation is one problem found in the staged changes
+type Violation struct {
+	Path    string
+	Line    int // Line in the staged file, 0 if the problem is with the whole file
+	Problem string
+}
+
+func (v Violation) String() string {
+	if v.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", v.Path, v.Line, v.Problem)
+	}
+	return fmt.Sprintf("%s: %s", v.Path, v.Problem)
+}
+
+// CheckStaged checks the changes in the index against the policy and the
+// built-in secret patterns. No violations means the changes may be committed.
+func CheckStaged(p Policy) ([]Violation, error) {
+	files, err := git.StagedFiles()
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	var violations []Violation
+	for _, file := range files {
+		if !p.Allows(file) {
+			violations = append(violations, Violation{Path: file, Problem: "outside the allowed paths (" + strings.Join(p.AllowedPaths, ", ") + ")"})
+			continue
+		}
+		if kind := secretFile(file); kind != "" {
+			violations = append(violations, Violation{Path: file, Problem: kind + " should not be committed"})
+			continue
+		}
+		if p.MaxFileBytes > 0 {
+			size, err := git.StagedSize(file)
+			if err != nil {
+				return nil, err
+			}
+			if size > p.MaxFileBytes {
+				violations = append(violations, Violation{Path: file, Problem: fmt.Sprintf("%s is over the %s limit", formatSize(size), formatSize(p.MaxFileBytes))})
+			}
+		}
+	}
+
+	diff, err := git.StagedDiff()
+	if err != nil {
+		return nil, err
+	}
+	flagged := make(map[string]bool)
+	for _, v := range violations {
+		flagged[v.Path] = true
+	}
+	for _, v := range scanDiff(diff) {
+		if !flagged[v.Path] {
+			violations = append(violations, v)
+		}
+	}
+	return violations, nil
+}
+
+// Allows reports whether the policy lets file be committed
+func (p Policy) Allows(file string) bool {
+	if len(p.AllowedPaths) == 0 {
+		return true
+	}
+	for _, pattern := range p.AllowedPaths {
+		if Match(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether a slash-separated path matches a glob. A pattern
+// without a slash matches the file name at any depth, like in .gitignore.
+// Otherwise it matches the whole path, with "**" standing for any number of
+// directories and the other syntax as in path.Match.
+func Match(pattern, name string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// formatSize formats a byte count for messages
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
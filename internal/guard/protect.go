@@ -0,0 +1,329 @@
+package guard
+
+import (
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ProjectPath returns name relative to dir with forward slashes. A relative
+// name is taken as relative to dir already. Returns false for paths outside dir.
+func ProjectPath(dir, name string) (string, bool) {
+	if filepath.IsAbs(name) {
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return "", false
+		}
+		name = rel
+	}
+	name = path.Clean(filepath.ToSlash(name))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
+
+// Protected returns the first pattern that matches the project-relative
+// name, or "" if none does
+func Protected(patterns []string, name string) string {
+	for _, pattern := range patterns {
+		if Match(pattern, name) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// CommandWriteTargets returns the paths a shell command writes by naming
+// them: redirection targets, and the operands of commands that change the
+// files they are given, such as rm, mv, cp, tee, sed -i and git rm. Paths
+// the command only reads are left out. It doesn't run or fully parse the
+// command, so writes it can't see, such as those made by a build tool,
+// git checkout or a script, are missed.
+func CommandWriteTargets(command string) []string {
+	var targets []string
+	for _, tokens := range simpleCommands(command) {
+		var args []string
+		for i := 0; i < len(tokens); i++ {
+			tok := tokens[i]
+			if !tok.op {
+				args = append(args, tok.text)
+				continue
+			}
+			if i+1 >= len(tokens) || tokens[i+1].op {
+				continue
+			}
+			i++
+			target := tokens[i].text
+			switch tok.text {
+			case ">", ">>", ">|", "&>", "&>>":
+				targets = append(targets, target)
+			case ">&":
+				// >&2 duplicates a descriptor; anything else is a file
+				if strings.Trim(target, "0123456789") != "" && target != "-" {
+					targets = append(targets, target)
+				}
+			}
+		}
+		targets = append(targets, writtenOperands(args)...)
+	}
+	return targets
+}
+
+// writingCommands are the commands writtenOperands knows to change files
+var writingCommands = map[string]bool{
+	"rm": true, "rmdir": true, "unlink": true, "shred": true, "touch": true, "mkdir": true, "truncate": true,
+	"mv": true, "tee": true, "chmod": true, "chown": true, "chgrp": true, "cp": true, "install": true,
+	"ln": true, "rsync": true, "sed": true, "perl": true, "ruby": true, "dd": true, "git": true,
+}
+
+// writtenOperands returns the files a simple command, given as its words,
+// changes through its arguments
+func writtenOperands(args []string) []string {
+	name, args, ok := commandName(args)
+	if !ok {
+		return nil
+	}
+
+	switch name {
+	case "rm", "rmdir", "unlink", "shred", "touch", "mkdir", "truncate", "mv", "tee", "chmod", "chown", "chgrp":
+		return operands(args, nil)
+	case "cp", "install", "ln", "rsync":
+		if dir := optionValue(args, "-t", "--target-directory"); dir != "" {
+			return []string{dir}
+		}
+		if ops := operands(args, nil); len(ops) > 1 {
+			return ops[len(ops)-1:]
+		}
+	case "sed":
+		if !inPlace(args) {
+			return nil
+		}
+		ops := operands(args, []string{"-e", "--expression", "-f", "--file"})
+		if optionValue(args, "-e", "--expression") == "" && optionValue(args, "-f", "--file") == "" && len(ops) > 0 {
+			ops = ops[1:] // The script
+		}
+		return ops
+	case "perl", "ruby":
+		if !inPlace(args) {
+			return nil
+		}
+		ops := operands(args, []string{"-e", "-E"})
+		if optionValue(args, "-e", "-E") == "" && len(ops) > 0 {
+			ops = ops[1:] // The script file
+		}
+		return ops
+	case "dd":
+		var out []string
+		for _, arg := range args {
+			if file, ok := strings.CutPrefix(arg, "of="); ok {
+				out = append(out, file)
+			}
+		}
+		return out
+	case "git":
+		ops := operands(args, []string{"-C", "-c"})
+		if len(ops) == 0 {
+			return nil
+		}
+		switch ops[0] {
+		case "rm", "mv", "restore":
+			return ops[1:]
+		case "checkout":
+			if i := slices.Index(args, "--"); i >= 0 {
+				return args[i+1:]
+			}
+		}
+	}
+	return nil
+}
+
+// commandName finds the command a simple command runs, skipping assignments
+// and wrappers such as sudo or xargs with their options. The values of those
+// options can't be told from the command, so after a wrapper only commands
+// that write are recognized.
+func commandName(words []string) (string, []string, bool) {
+	wrapped := false
+	for i, word := range words {
+		switch {
+		case commandPrefixes[word]:
+			wrapped = true
+		case strings.Contains(word, "=") || strings.HasPrefix(word, "-"):
+		case wrapped && !writingCommands[path.Base(word)]:
+		default:
+			return path.Base(word), words[i+1:], true
+		}
+	}
+	return "", nil, false
+}
+
+// operands returns the arguments that aren't options, or values of the given
+// options that take one
+func operands(args, valued []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--":
+			return append(out, args[i+1:]...)
+		case slices.Contains(valued, arg):
+			i++
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			out = append(out, arg)
+		}
+	}
+	return out
+}
+
+// optionValue returns the value of the first of the given options, whether
+// it's given as "-t dir", "-tdir" or "--target-directory=dir"
+func optionValue(args []string, names ...string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		for _, name := range names {
+			switch {
+			case arg == name && i+1 < len(args):
+				return args[i+1]
+			case strings.HasPrefix(name, "--") && strings.HasPrefix(arg, name+"="):
+				return arg[len(name)+1:]
+			case !strings.HasPrefix(name, "--") && !strings.HasPrefix(arg, "--") && strings.HasPrefix(arg, name) && len(arg) > len(name):
+				return arg[len(name):]
+			}
+		}
+	}
+	return ""
+}
+
+// inPlace reports whether sed, perl or ruby is asked to edit files in place
+// with -i, which may be combined with other flags or carry a backup suffix
+func inPlace(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "--in-place" || strings.HasPrefix(arg, "--in-place=") {
+			return true
+		}
+		if flags, ok := strings.CutPrefix(arg, "-"); ok && !strings.HasPrefix(flags, "-") {
+			// Flags up to one that takes a value, like -e
+			if i := strings.IndexAny(flags, "eEf"); i >= 0 {
+				flags = flags[:i]
+			}
+			if strings.Contains(flags, "i") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shellToken is a word with its quotes removed, or an operator if op is set
+type shellToken struct {
+	text string
+	op   bool
+}
+
+// simpleCommands splits a command into the words and redirection operators
+// of its simple commands. Separators, pipes, subshells and command
+// substitutions all end a simple command.
+func simpleCommands(command string) [][]shellToken {
+	var commands [][]shellToken
+	var tokens []shellToken
+	var word strings.Builder
+	inWord := false
+	endWord := func() {
+		if inWord {
+			tokens = append(tokens, shellToken{text: word.String()})
+		}
+		word.Reset()
+		inWord = false
+	}
+	endCommand := func() {
+		endWord()
+		if len(tokens) > 0 {
+			commands = append(commands, tokens)
+		}
+		tokens = nil
+	}
+	operator := func(op string) {
+		endWord()
+		tokens = append(tokens, shellToken{text: op, op: true})
+	}
+
+	r := []rune(command)
+	for i := 0; i < len(r); i++ {
+		c := r[i]
+		next := func(want ...rune) bool {
+			return i+1 < len(r) && slices.Contains(want, r[i+1])
+		}
+		switch {
+		case c == '\\' && i+1 < len(r):
+			i++
+			word.WriteRune(r[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexRune(string(r[i+1:]), '\'')
+			if end < 0 {
+				end = len(r) - i - 1
+			}
+			word.WriteString(string(r[i+1 : i+1+end]))
+			i += end + 1
+			inWord = true
+		case c == '"':
+			for i++; i < len(r) && r[i] != '"'; i++ {
+				if r[i] == '\\' && i+1 < len(r) && strings.ContainsRune("\"\\$`", r[i+1]) {
+					i++
+				}
+				word.WriteRune(r[i])
+			}
+			inWord = true
+		case c == ' ' || c == '\t':
+			endWord()
+		case c == '>':
+			// A descriptor number in front, as in 2>, belongs to the operator
+			if inWord && strings.Trim(word.String(), "0123456789") == "" {
+				word.Reset()
+				inWord = false
+			}
+			switch {
+			case next('>'):
+				i++
+				operator(">>")
+			case next('|'):
+				i++
+				operator(">|")
+			case next('&'):
+				i++
+				operator(">&")
+			default:
+				operator(">")
+			}
+		case c == '<':
+			if inWord && strings.Trim(word.String(), "0123456789") == "" {
+				word.Reset()
+				inWord = false
+			}
+			for next('<', '&', '>') {
+				i++
+			}
+			operator("<")
+		case c == '&' && next('>'):
+			i++
+			if next('>') {
+				i++
+				operator("&>>")
+			} else {
+				operator("&>")
+			}
+		case strings.ContainsRune(";&|()`\n", c):
+			endCommand()
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	endCommand()
+	return commands
+}
@@ -0,0 +1,84 @@
+package guard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProjectPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"/repo/go.sum", "go.sum", true},
+		{"/repo/db/migrations/001.sql", "db/migrations/001.sql", true},
+		{"./cmd/../go.mod", "go.mod", true},
+		{"/elsewhere/go.sum", "", false},
+		{"../go.sum", "", false},
+		{"/repo", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ProjectPath("/repo", tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ProjectPath(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestProtected(t *testing.T) {
+	patterns := []string{"migrations/**", "go.sum", ".github/workflows/*"}
+	if got := Protected(patterns, "migrations/2024/001.sql"); got != "migrations/**" {
+		t.Errorf("expected migrations to be protected, got %q", got)
+	}
+	if got := Protected(patterns, "tools/go.sum"); got != "go.sum" {
+		t.Errorf("expected go.sum to be protected, got %q", got)
+	}
+	if got := Protected(patterns, ".github/workflows/ci.yml"); got != ".github/workflows/*" {
+		t.Errorf("expected CI config to be protected, got %q", got)
+	}
+	if got := Protected(patterns, "internal/migrations.go"); got != "" {
+		t.Errorf("expected internal/migrations.go not to be protected, got %q", got)
+	}
+}
+
+func TestCommandWriteTargets(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		// Reads
+		{"cat go.sum", nil},
+		{"grep -rn foo migrations/ | head -5", nil},
+		{"ls migrations && wc -l < go.sum", nil},
+		{"go test ./... 2>&1 | tee", nil},
+		{`git log -- go.sum; sed 's/a/b/' go.sum`, nil},
+		{"diff <(sort a.txt) migrations/x.sql", nil},
+		// Redirections
+		{`echo "x > y" >>.github/workflows/ci.yml`, []string{".github/workflows/ci.yml"}},
+		{"go test ./... > out.txt 2>&1", []string{"out.txt"}},
+		{"make 2>errors.log &>all.log", []string{"errors.log", "all.log"}},
+		{"cat a >| b", []string{"b"}},
+		// Commands that change their operands
+		{"rm -rf migrations/old build", []string{"migrations/old", "build"}},
+		{"mv go.sum go.sum.bak", []string{"go.sum", "go.sum.bak"}},
+		{"cp -r migrations/ backup/", []string{"backup/"}},
+		{"cp -t migrations/ a.sql b.sql", []string{"migrations/"}},
+		{"go env | tee -a env.txt 'my file'", []string{"env.txt", "my file"}},
+		{"sed -i 's/a/b/' go.sum go.mod", []string{"go.sum", "go.mod"}},
+		{"sed -i.bak -e 's/a/b/' -e 's/c/d/' go.sum", []string{"go.sum"}},
+		{"sed -n 's/a/b/p' go.sum", nil},
+		{"perl -pi -e 's/a/b/' go.sum", []string{"go.sum"}},
+		{"perl -e 'print 1' go.sum", nil},
+		{"dd if=/dev/zero of=disk.img bs=1M", []string{"disk.img"}},
+		{"sudo -u me touch a", []string{"a"}},
+		{"echo $(rm go.sum)", []string{"go.sum"}},
+		{"git rm --cached go.sum && git checkout main -- migrations/001.sql", []string{"go.sum", "migrations/001.sql"}},
+		{"git add -A && git commit -m 'rm go.sum'", nil},
+	}
+	for _, tt := range tests {
+		if got := CommandWriteTargets(tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CommandWriteTargets(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package guard
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// secretPatterns are matched against every added line. The names end up in
+// the fixer's instructions; the matched text never does.
+var secretPatterns = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN ([A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`)},
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"Anthropic or OpenAI API key", regexp.MustCompile(`\bsk-(ant-|proj-)?[A-Za-z0-9_-]{32,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{20,}\b`)},
+	{"hard-coded credential", regexp.MustCompile(`(?i)(api[_-]?key|secret|token|passw(or)?d)["']?\s*(:=|=|:)\s*["'][^"'\s]{16,}["']`)},
+}
+
+// secretFile returns what kind of secret file name is, or "" if it doesn't
+// look like one
+func secretFile(name string) string {
+	base := path.Base(name)
+	switch ext := path.Ext(base); {
+	case base == ".env" || strings.HasPrefix(base, ".env.") && !isEnvTemplate(base):
+		return "environment file"
+	case ext == ".pem" || ext == ".key" || ext == ".p12" || ext == ".pfx":
+		return "key file"
+	case base == "id_rsa" || base == "id_dsa" || base == "id_ecdsa" || base == "id_ed25519":
+		return "SSH private key"
+	}
+	return ""
+}
+
+// isEnvTemplate reports whether an .env.* file is an example meant to be committed
+func isEnvTemplate(base string) bool {
+	switch strings.TrimPrefix(base, ".env.") {
+	case "example", "sample", "template", "dist", "defaults":
+		return true
+	}
+	return false
+}
+
+// scanDiff finds secrets in the lines a unified diff adds
+func scanDiff(diff string) []Violation {
+	var violations []Violation
+	var file string
+	line := 0
+	for _, text := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			file = diffPath(strings.TrimPrefix(text, "+++ "))
+		case strings.HasPrefix(text, "@@ "):
+			line = hunkStart(text)
+		case strings.HasPrefix(text, "+"):
+			if file == "" {
+				continue
+			}
+			for _, p := range secretPatterns {
+				if p.re.MatchString(text[1:]) {
+					violations = append(violations, Violation{Path: file, Line: line, Problem: "possible " + p.name})
+					break
+				}
+			}
+			line++
+		case strings.HasPrefix(text, " "):
+			line++
+		}
+	}
+	return violations
+}
+
+// diffPath returns the file a "+++" header names, or "" for a deletion
+func diffPath(name string) string {
+	if strings.HasPrefix(name, `"`) {
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			name = unquoted
+		}
+	}
+	if name == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(name, "b/")
+}
+
+// hunkStart returns the first new-file line of a "@@ -a,b +c,d @@" hunk
+func hunkStart(header string) int {
+	fields := strings.Fields(header)
+	if len(fields) < 3 {
+		return 0
+	}
+	start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+	n, _ := strconv.Atoi(start)
+	return n
+}
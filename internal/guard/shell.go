@@ -0,0 +1,86 @@
+package guard
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Reasons a Bash command is refused, worded for Claude
+const (
+	HeredocReason    = "Heredocs are blocked. Create files with the Write tool and change them with the Edit tool instead of feeding text to cat, tee or a script with <<."
+	HerestringReason = "Herestrings (<<<) are blocked. Write the input to a file with the Write tool and pass the file instead, or make the change with the Edit tool."
+	AwkReason        = "awk is blocked because it triggers a permission prompt that nobody is there to answer. Read files with the Read tool, search them with grep, and change them with the Edit tool."
+)
+
+var (
+	heredocRe    = regexp.MustCompile(`<<-?[ \t]*\\?['"]?[A-Za-z_]`)
+	arithmeticRe = regexp.MustCompile(`\(\([^()]*\)\)`)
+
+	// commandPrefixes run the command that follows them
+	commandPrefixes = map[string]bool{"sudo": true, "env": true, "xargs": true, "time": true, "nice": true, "nohup": true, "command": true, "exec": true}
+	awkCommands     = map[string]bool{"awk": true, "gawk": true, "mawk": true, "nawk": true}
+)
+
+// ShellBlocked returns why a Bash command is not allowed, or "" if it is.
+// Text inside quotes is ignored, so a command may mention these patterns in
+// a string or commit message.
+func ShellBlocked(command string) string {
+	code := blankQuoted(command)
+	for arithmeticRe.MatchString(code) {
+		code = arithmeticRe.ReplaceAllString(code, " ")
+	}
+
+	switch {
+	case strings.Contains(code, "<<<"):
+		return HerestringReason
+	case heredocRe.MatchString(code):
+		return HeredocReason
+	case runsAwk(code):
+		return AwkReason
+	}
+	return ""
+}
+
+// blankQuoted replaces the characters inside quotes with x, keeping the quotes
+// themselves, so that operators in strings aren't mistaken for shell syntax
+func blankQuoted(command string) string {
+	b := []byte(command)
+	var quote byte
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case quote == 0 && c == '\\':
+			i++ // Escaped character
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case quote == c:
+			quote = 0
+		case quote == '"' && c == '\\' && i+1 < len(b):
+			b[i], b[i+1] = 'x', 'x'
+			i++
+		case quote != 0:
+			b[i] = 'x'
+		}
+	}
+	return string(b)
+}
+
+// runsAwk reports whether any simple command in code runs awk
+func runsAwk(code string) bool {
+	commands := strings.FieldsFunc(code, func(r rune) bool {
+		return strings.ContainsRune(";&|()`\n", r)
+	})
+	for _, command := range commands {
+		for _, word := range strings.Fields(command) {
+			if strings.Contains(word, "=") || commandPrefixes[word] || strings.HasPrefix(word, "-") {
+				continue // Assignment, wrapper or wrapper option
+			}
+			if awkCommands[path.Base(word)] {
+				return true
+			}
+			break
+		}
+	}
+	return false
+}
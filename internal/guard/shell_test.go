@@ -0,0 +1,56 @@
+package guard
+
+import "testing"
+
+func TestShellBlocked(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		// Heredoc delimiter variants
+		{"cat << EOF > main.go\npackage main\nEOF", HeredocReason},
+		{"cat <<EOF > main.go\nEOF", HeredocReason},
+		{"cat << 'EOF' > main.go\nEOF", HeredocReason},
+		{"cat <<'EOF' > main.go\nEOF", HeredocReason},
+		{"cat << \"EOF\" > main.go\nEOF", HeredocReason},
+		{"cat <<\"EOF\" > main.go\nEOF", HeredocReason},
+		{"cat <<-EOF > main.go\n\tEOF", HeredocReason},
+		{"cat <<- 'EOF' > main.go\n\tEOF", HeredocReason},
+		{"cat <<\\EOF > main.go\nEOF", HeredocReason},
+		{"cat << 'END' > notes.md\nEND", HeredocReason},
+		{"cat << 'HEREDOC' | git commit -F -\nHEREDOC", HeredocReason},
+		{"tee out.txt <<_MARKER_\n_MARKER_", HeredocReason},
+		{"python3 - <<PY\nprint(1)\nPY", HeredocReason},
+		{`git commit -F- <<"$delim"`, HeredocReason},
+
+		// Herestrings
+		{`grep foo <<< "$text"`, HerestringReason},
+		{"read -r a b <<<'1 2'", HerestringReason},
+		{"wc -l <<<$lines", HerestringReason},
+
+		// awk in command position
+		{"awk '{print $1}' file.txt", AwkReason},
+		{"cat file.txt | awk -F, '{print $2}'", AwkReason},
+		{"ls && gawk 'BEGIN{}'", AwkReason},
+		{"/usr/bin/awk 1 x", AwkReason},
+		{"x=$(awk 'NR==1' f)", AwkReason},
+		{"LC_ALL=C sudo awk 1 f", AwkReason},
+		{"find . -name '*.go' | xargs -n1 awk 1", AwkReason},
+
+		// Allowed
+		{"go test ./...", ""},
+		{`echo "use << EOF in docs"`, ""},
+		{`git commit -m 'avoid <<< and awk'`, ""},
+		{"echo $((1 << 4))", ""},
+		{"x=$(( a<<b ))", ""},
+		{"grep -rn awk internal/", ""},
+		{"cat awk.txt", ""},
+		{"sort < input.txt > output.txt", ""},
+		{`echo it\'s "<< EOF"`, ""},
+	}
+	for _, tt := range tests {
+		if got := ShellBlocked(tt.command); got != tt.want {
+			t.Errorf("ShellBlocked(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
@@ -24,7 +24,7 @@ const coderTemplate = `You are working on: {{GOAL}}
 - Read .autoclaude/TODO.md for the task list
 - Read .autoclaude/coding-guidelines.md for language-specific coding standards
 
-Work on the highest priority incomplete item in TODO.md.
+Work on the TODO given under "Current TODO" at the end of this prompt.
 
 ## Rules
 1. Run tests after changes: ` + "`{{TEST_CMD}}`" + `
@@ -41,6 +41,7 @@ The following Bash patterns are PROGRAMMATICALLY BLOCKED and will be DENIED:
 - ` + "`<< 'EOF'`" + `, ` + "`<< \"EOF\"`" + `, ` + "`<< EOF`" + ` (heredocs)
 - ` + "`<< 'END'`" + `, ` + "`<< 'HEREDOC'`" + `, and similar delimiter variants
 - ` + "`<<<`" + ` (herestrings)
+- ` + "`awk`" + `, ` + "`gawk`" + ` and friends (they trigger an unskippable permissions check)
 
 USE THE Read, Write, AND Edit TOOLS INSTEAD. This is not optional - heredoc requests will fail.
 
@@ -128,16 +129,29 @@ ALWAYS use the Read and Write/Edit tools for file operations - NEVER use cat, ec
 AVOID using awk - it triggers an unskippable permissions check.
 
 ## Actions
-After your review, write your verdict to .autoclaude/critic_verdict.md:
+After your review, write your verdict to .autoclaude/critic_verdict.md. The file MUST start with a YAML front-matter block between two "---" lines - the orchestrator parses it, and a verdict it can't parse wastes a review:
 
-**If APPROVED** (code is correct, well-structured, tests pass, follows best practices):
 ` + "```" + `
-APPROVED
-
-Brief summary of what was reviewed and why it's good.
+---
+verdict: NEEDS_FIXES        # APPROVED, MINOR_ISSUES or NEEDS_FIXES
+summary: One or two sentences on what was reviewed and the outcome.
+issues:                     # Required for NEEDS_FIXES
+  - file: internal/foo/bar.go
+    line: 42                # Optional
+    severity: high          # high, medium or low
+    description: What is wrong and why it matters.
+suggested_todos:            # New non-blocking follow-ups, see below
+  - title: "Fix: <issue description>"
+    completion: <specific criteria>
+    priority: low
+---
+
+Free-form markdown for the fixer: test output, reproduction, how to fix.
 ` + "```" + `
 
-**If NEEDS_FIXES** (ANY of the following):
+**APPROVED**: code is correct, well-structured, tests pass, follows best practices.
+
+**NEEDS_FIXES** if ANY of the following:
 - Tests fail or code doesn't work correctly
 - Bugs or logic errors
 - Security vulnerabilities
@@ -147,55 +161,29 @@ Brief summary of what was reviewed and why it's good.
 - Race conditions or concurrency issues
 - Resource leaks (memory, file descriptors, connections)
 
-` + "```" + `
-NEEDS_FIXES
-
-## Issues
-- Issue 1: detailed description (include file:line if applicable)
-- Issue 2: detailed description (include file:line if applicable)
-
-## Test Output (if relevant)
-<paste failing test output here>
-
-## Reproduction (if you created one)
-If you wrote code/tests to reproduce the issue, include the file path here.
-DO NOT delete reproduction code - keep it for the fixer to use.
-
-## How to Fix
-Specific instructions for the fixer to fix these issues. Be clear about what needs to change.
+List every blocking problem under issues. After the front matter, include:
+- **Test Output** (if relevant): the failing test output
+- **Reproduction** (if you created one): the file path of any code/tests you wrote to reproduce the issue. DO NOT delete reproduction code - keep it for the fixer to use.
+- **How to Fix**: specific instructions for the fixer. Be clear about what needs to change.
 
 REMEMBER: You are the CRITIC, not the fixer. Describe what needs to be fixed, but DO NOT fix it yourself.
-` + "```" + `
 
-**If MINOR_ISSUES** (non-blocking improvements):
+**MINOR_ISSUES** (non-blocking improvements):
 - Naming could be clearer but isn't wrong
 - Minor code style inconsistencies
 - Small refactor opportunities that don't affect correctness
 - Documentation improvements
 - Low-priority optimizations
 
-` + "```" + `
-MINOR_ISSUES
-
-Brief summary of minor issues found.
-` + "```" + `
-
 ## CRITICAL - DO NOT SKIP THIS STEP
 
-Before adding any TODO, READ .autoclaude/TODO.md and check if a similar issue is already tracked.
-Do NOT add a new TODO if:
+Before suggesting any TODO, READ .autoclaude/TODO.md and check if a similar issue is already tracked.
+Do NOT suggest a new TODO if:
 - An existing TODO covers the same issue (even if worded differently)
 - An existing TODO would fix this issue as a side effect
 - The issue is a minor variant of something already tracked
 
-YOU MUST write each GENUINELY NEW minor issue as a TODO item to .autoclaude/TODO.md under "## Pending":
-
-` + "```" + `
-- [ ] **Fix: <issue description>** - Completion: <specific criteria>
-  - Priority: low
-` + "```" + `
-
-If you do NOT write the issue to TODO.md, it will NOT be fixed. The orchestrator only reads TODO.md to find work.
+YOU MUST list each GENUINELY NEW minor issue under suggested_todos. The orchestrator adds them to .autoclaude/TODO.md under "## Pending" - do not edit TODO.md yourself. If you do NOT list the issue, it will NOT be fixed.
 
 DO NOT attempt to fix minor issues yourself. Your role is REVIEW only - document issues and let the fixer handle them. If you start fixing things yourself, you are breaking the orchestration loop.
 
@@ -211,6 +199,7 @@ The following Bash patterns are PROGRAMMATICALLY BLOCKED and will be DENIED:
 - ` + "`<< 'EOF'`" + `, ` + "`<< \"EOF\"`" + `, ` + "`<< EOF`" + ` (heredocs)
 - ` + "`<< 'END'`" + `, ` + "`<< 'HEREDOC'`" + `, and similar delimiter variants
 - ` + "`<<<`" + ` (herestrings)
+- ` + "`awk`" + `, ` + "`gawk`" + ` and friends (they trigger an unskippable permissions check)
 
 USE THE Read, Write, AND Edit TOOLS INSTEAD. This is not optional - heredoc requests will fail.
 AVOID using awk - it triggers an unskippable permissions check.
@@ -577,6 +566,7 @@ When deduplicating:
 - Keep ALL high and medium priority TODOs (do not group or auto-complete them)
 - Preserve all completion criteria
 - Maintain dependencies between tasks
+- Leave TODOs with a "Status:" sub-bullet (blocked, deferred or skipped) and their other sub-bullets exactly as they are; a human decides what happens to them
 - Keep the structure organized with sections
 
 ## Output Format
@@ -632,6 +622,102 @@ func GenerateFixer(params PromptParams, fixInstructions string, currentTodo stri
 	return result
 }
 
+// AppendCurrentTodo adds the orchestrator-selected TODO to a coder prompt
+func AppendCurrentTodo(coderPrompt string, currentTodo string) string {
+	return coderPrompt + fmt.Sprintf(`
+## Current TODO
+The orchestrator selected this TODO based on priority and dependencies. Work on it and nothing else:
+
+%s
+`, currentTodo)
+}
+
+// GenerateTestFailureInstructions builds fixer instructions from a failing test run
+// performed by the orchestrator (used in place of critic feedback)
+func GenerateTestFailureInstructions(testCmd string, exitCode int, output string, outputPath string) string {
+	return fmt.Sprintf(`## Tests Failed
+The orchestrator ran the test command `+"`%s`"+` and it exited with code %d.
+The critic was skipped - make the tests pass first.
+
+## Test Output
+`+"```"+`
+%s
+`+"```"+`
+
+The full output is saved in %s.
+`, testCmd, exitCode, output, outputPath)
+}
+
+// GenerateLintFailureInstructions builds fixer instructions from a failing lint
+// command run by the orchestrator
+func GenerateLintFailureInstructions(lintCmd string, exitCode int, output string, outputPath string) string {
+	return fmt.Sprintf(`## Lint Failed
+The orchestrator ran the lint command `+"`%s`"+` and it exited with code %d.
+The critic was skipped - fix the reported problems first.
+
+## Lint Output
+`+"```"+`
+%s
+`+"```"+`
+
+The full output is saved in %s.
+`, lintCmd, exitCode, output, outputPath)
+}
+
+// GenerateCommitGuardInstructions builds fixer instructions from the problems
+// the commit guard found in changes the orchestrator was about to commit
+func GenerateCommitGuardInstructions(problems []string) string {
+	return fmt.Sprintf(`## Commit Refused
+The orchestrator refused to commit the changes left in the working tree:
+
+- %s
+
+Nothing was committed; the changes are still in the working tree. Secrets belong in the environment or an ignored file, not in the repository - remove them and read them from configuration instead. Delete or .gitignore generated and oversized files rather than committing them, and keep changes inside the paths the project allows. Then commit the rest of the work.
+`, strings.Join(problems, "\n- "))
+}
+
+// GenerateTimeoutInstructions builds fixer instructions for a session the
+// orchestrator stopped for running too long or stalling. previous holds the
+// fix instructions the stopped session was working on, if any.
+func GenerateTimeoutInstructions(reason string, previous string) string {
+	instructions := fmt.Sprintf(`## Session Stopped
+The orchestrator stopped the previous session: %s.
+Whatever it changed is still in the working tree - check it with git status and git diff, finish the work and commit it. Avoid commands that wait for input or never exit, such as watch modes, servers run in the foreground and interactive prompts, and work in smaller steps.
+`, reason)
+	if previous != "" {
+		instructions += "\n## Instructions the Stopped Session Was Working On\n" + previous + "\n"
+	}
+	return instructions
+}
+
+// GenerateCriticReask asks the critic to rewrite a verdict the orchestrator couldn't parse
+func GenerateCriticReask(parseErr error, verdictPath string) string {
+	return fmt.Sprintf(`You are the code critic. The orchestrator could not read your verdict in %s:
+
+    %v
+
+Rewrite %s so it starts with the YAML front-matter block below, keeping the findings of your review. If the file is missing or empty, review the most recent commits first (git log, git diff) and then write it. Do not change any other file.
+
+`+"```"+`
+---
+verdict: NEEDS_FIXES        # APPROVED, MINOR_ISSUES or NEEDS_FIXES
+summary: One or two sentences on what was reviewed and the outcome.
+issues:                     # Required for NEEDS_FIXES
+  - file: path/to/file
+    line: 42                # Optional
+    severity: high          # high, medium or low
+    description: What is wrong and why it matters.
+suggested_todos:            # Non-blocking follow-ups for TODO.md
+  - title: "Fix: <issue description>"
+    completion: <specific criteria>
+    priority: low
+---
+
+Free-form markdown for the fixer.
+`+"```"+`
+`, verdictPath, parseErr, verdictPath)
+}
+
 // GenerateCoder generates the coder prompt
 func GenerateCoder(params PromptParams) string {
 	return expandTemplate(coderTemplate, params)
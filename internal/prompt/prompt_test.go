@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"errors"
 	"os"
 	"strings"
 	"testing"
@@ -337,10 +338,73 @@ func TestCriticPromptMinorIssuesTodo(t *testing.T) {
 	params := PromptParams{Goal: "test", TestCmd: "test"}
 	content := GenerateCritic(params)
 
-	if !strings.Contains(content, "YOU MUST write each GENUINELY NEW minor issue") {
-		t.Error("critic should instruct adding minor issues as TODOs")
+	if !strings.Contains(content, "YOU MUST list each GENUINELY NEW minor issue under suggested_todos") {
+		t.Error("critic should instruct suggesting minor issues as TODOs")
 	}
-	if !strings.Contains(content, "Priority: low") {
-		t.Error("critic should show TODO format with priority")
+	if !strings.Contains(content, "priority: low") {
+		t.Error("critic should show suggested TODO format with priority")
+	}
+	if !strings.Contains(content, "---\nverdict:") {
+		t.Error("critic should show the front-matter verdict format")
+	}
+}
+
+func TestGenerateCriticReask(t *testing.T) {
+	content := GenerateCriticReask(errors.New("verdict is missing"), ".autoclaude/critic_verdict.md")
+
+	if !strings.Contains(content, "verdict is missing") {
+		t.Error("re-ask should include the parse error")
+	}
+	if !strings.Contains(content, ".autoclaude/critic_verdict.md") {
+		t.Error("re-ask should reference the verdict file")
+	}
+	if !strings.Contains(content, "severity:") {
+		t.Error("re-ask should show the front-matter format")
+	}
+}
+
+func TestAppendCurrentTodo(t *testing.T) {
+	content := AppendCurrentTodo(GenerateCoder(PromptParams{Goal: "g", TestCmd: "t"}), "**Add parser** - Completion: tests pass")
+
+	if !strings.Contains(content, "## Current TODO") {
+		t.Error("coder prompt should have a Current TODO section")
+	}
+	if !strings.HasSuffix(strings.TrimSpace(content), "**Add parser** - Completion: tests pass") {
+		t.Error("coder prompt should end with the selected TODO")
+	}
+}
+
+func TestGenerateTestFailureInstructions(t *testing.T) {
+	content := GenerateTestFailureInstructions("go test ./...", 1, "--- FAIL: TestParse", ".autoclaude/test_output.txt")
+
+	for _, want := range []string{"go test ./...", "exited with code 1", "--- FAIL: TestParse", ".autoclaude/test_output.txt"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("instructions should contain %q", want)
+		}
+	}
+
+	fixer := GenerateFixer(PromptParams{Goal: "g", TestCmd: "go test ./..."}, content, "current")
+	if !strings.Contains(fixer, "--- FAIL: TestParse") {
+		t.Error("fixer prompt should include the test output")
+	}
+}
+
+func TestGenerateCommitGuardInstructions(t *testing.T) {
+	content := GenerateCommitGuardInstructions([]string{".env: environment file should not be committed", "main.go:3: possible AWS access key"})
+
+	for _, want := range []string{"## Commit Refused", "- .env: environment file", "- main.go:3: possible AWS access key", "Nothing was committed"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("instructions should contain %q", want)
+		}
+	}
+}
+
+func TestGenerateLintFailureInstructions(t *testing.T) {
+	content := GenerateLintFailureInstructions("golangci-lint run", 2, "main.go:3: unused variable", ".autoclaude/test_output.txt")
+
+	for _, want := range []string{"## Lint Failed", "golangci-lint run", "exited with code 2", "main.go:3: unused variable"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("instructions should contain %q", want)
+		}
 	}
 }
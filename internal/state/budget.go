@@ -0,0 +1,71 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Budget caps the resources a run may use. Zero values mean no limit.
+type Budget struct {
+	MaxCostUSD  float64 `json:"maxCostUsd,omitempty"`
+	MaxTokens   int     `json:"maxTokens,omitempty"`
+	MaxSeconds  int64   `json:"maxSeconds,omitempty"` // Wall-clock time spent in the loop
+	MaxSessions int     `json:"maxSessions,omitempty"`
+}
+
+// IsZero reports whether no limit is set
+func (b *Budget) IsZero() bool {
+	return b == nil || *b == Budget{}
+}
+
+// Exceeded returns why the budget is used up, or empty if there is room for
+// another phase
+func (b *Budget) Exceeded(st *Stats) string {
+	if b.IsZero() || st == nil {
+		return ""
+	}
+	if b.MaxCostUSD > 0 && st.Usage.CostUSD >= b.MaxCostUSD {
+		return fmt.Sprintf("cost budget reached (%s of $%.2f)", st.Usage.FormatCost(), b.MaxCostUSD)
+	}
+	if b.MaxTokens > 0 && st.Usage.TotalTokens() >= b.MaxTokens {
+		return fmt.Sprintf("token budget reached (%d of %d tokens)", st.Usage.TotalTokens(), b.MaxTokens)
+	}
+	if b.MaxSeconds > 0 && st.ElapsedMs/1000 >= b.MaxSeconds {
+		return fmt.Sprintf("time budget reached (%s of %s)", st.Elapsed(), b.maxTime())
+	}
+	if b.MaxSessions > 0 && st.ClaudeRuns >= b.MaxSessions {
+		return fmt.Sprintf("session budget reached (%d of %d Claude sessions)", st.ClaudeRuns, b.MaxSessions)
+	}
+	return ""
+}
+
+// String describes the limits that are set, e.g. "$5.00, 2h0m0s"
+func (b *Budget) String() string {
+	if b.IsZero() {
+		return "none"
+	}
+	var limits []string
+	if b.MaxCostUSD > 0 {
+		limits = append(limits, fmt.Sprintf("$%.2f", b.MaxCostUSD))
+	}
+	if b.MaxTokens > 0 {
+		limits = append(limits, fmt.Sprintf("%d tokens", b.MaxTokens))
+	}
+	if b.MaxSeconds > 0 {
+		limits = append(limits, b.maxTime().String())
+	}
+	if b.MaxSessions > 0 {
+		limits = append(limits, fmt.Sprintf("%d sessions", b.MaxSessions))
+	}
+	return strings.Join(limits, ", ")
+}
+
+func (b *Budget) maxTime() time.Duration {
+	return time.Duration(b.MaxSeconds) * time.Second
+}
+
+// Elapsed returns the wall-clock time spent in the loop, rounded to seconds
+func (st *Stats) Elapsed() time.Duration {
+	return (time.Duration(st.ElapsedMs) * time.Millisecond).Round(time.Second)
+}
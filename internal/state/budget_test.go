@@ -0,0 +1,41 @@
+package state
+
+import "testing"
+
+func TestBudgetExceeded(t *testing.T) {
+	stats := &Stats{ClaudeRuns: 4, ElapsedMs: 90_000}
+	stats.RecordUsage(PhaseCoder, "", Usage{Sessions: 1, InputTokens: 1000, CostUSD: 2.5})
+
+	tests := []struct {
+		name   string
+		budget *Budget
+		want   string
+	}{
+		{"nil budget", nil, ""},
+		{"no limits", &Budget{}, ""},
+		{"under all limits", &Budget{MaxCostUSD: 5, MaxTokens: 2000, MaxSeconds: 120, MaxSessions: 5}, ""},
+		{"cost", &Budget{MaxCostUSD: 2.5}, "cost budget reached ($2.50 of $2.50)"},
+		{"tokens", &Budget{MaxTokens: 500}, "token budget reached (1000 of 500 tokens)"},
+		{"time", &Budget{MaxSeconds: 60}, "time budget reached (1m30s of 1m0s)"},
+		{"sessions", &Budget{MaxSessions: 4}, "session budget reached (4 of 4 Claude sessions)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.budget.Exceeded(stats); got != tt.want {
+				t.Errorf("Exceeded() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudgetString(t *testing.T) {
+	var none *Budget
+	if got := none.String(); got != "none" {
+		t.Errorf("expected 'none', got %q", got)
+	}
+	b := &Budget{MaxCostUSD: 5, MaxSeconds: 7200, MaxSessions: 20}
+	if got := b.String(); got != "$5.00, 2h0m0s, 20 sessions" {
+		t.Errorf("unexpected budget string %q", got)
+	}
+}
@@ -0,0 +1,244 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// frontMatterDelim opens and closes the YAML (or JSON) block at the top of critic_verdict.md
+const frontMatterDelim = "---"
+
+// Issue severities the critic may report
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// CriticReview is the parsed content of critic_verdict.md
+type CriticReview struct {
+	Verdict        CriticVerdict   `yaml:"verdict"`
+	Summary        string          `yaml:"summary,omitempty"`
+	Issues         []CriticIssue   `yaml:"issues,omitempty"`
+	SuggestedTodos []SuggestedTodo `yaml:"suggested_todos,omitempty"`
+
+	Body       string `yaml:"-"` // Markdown after the front matter, or the whole legacy file
+	Structured bool   `yaml:"-"` // Whether the review had front matter
+}
+
+// CriticIssue is a single problem found by the critic
+type CriticIssue struct {
+	File        string `yaml:"file,omitempty"`
+	Line        int    `yaml:"line,omitempty"`
+	Severity    string `yaml:"severity"`
+	Description string `yaml:"description"`
+}
+
+// SuggestedTodo is a follow-up task the critic wants added to TODO.md
+type SuggestedTodo struct {
+	Title      string   `yaml:"title"`
+	Completion string   `yaml:"completion,omitempty"`
+	Priority   Priority `yaml:"priority,omitempty"`
+}
+
+// LoadCriticReview reads and parses critic_verdict.md
+func LoadCriticReview() (*CriticReview, error) {
+	data, err := os.ReadFile(CriticVerdictPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read critic verdict: %w", err)
+	}
+	return ParseCriticReview(string(data))
+}
+
+// ParseCriticReview parses a critic verdict. The structured format is a YAML
+// or JSON front-matter block between "---" lines followed by free-form
+// markdown. Files without front matter fall back to the legacy format, where
+// the verdict keyword starts the first non-blank line.
+func ParseCriticReview(content string) (*CriticReview, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	trimmed := strings.TrimLeft(content, " \t\r\n")
+
+	if !strings.HasPrefix(trimmed, frontMatterDelim) {
+		return parseLegacyReview(content)
+	}
+
+	rest := strings.TrimPrefix(trimmed, frontMatterDelim)
+	end := strings.Index(rest, "\n"+frontMatterDelim)
+	if end < 0 {
+		return nil, fmt.Errorf("front matter is missing its closing %q line", frontMatterDelim)
+	}
+	front := rest[:end]
+	body := rest[end+len("\n"+frontMatterDelim):]
+
+	review := &CriticReview{}
+	if err := yaml.Unmarshal([]byte(front), review); err != nil {
+		return nil, fmt.Errorf("invalid front matter: %w", err)
+	}
+	review.Verdict = CriticVerdict(strings.ToUpper(strings.TrimSpace(string(review.Verdict))))
+	review.Body = strings.TrimSpace(body)
+	review.Structured = true
+
+	if err := review.Validate(); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// Validate checks that the review has a known verdict and well-formed issues
+// and suggested TODOs
+func (r *CriticReview) Validate() error {
+	var errs []error
+	switch r.Verdict {
+	case VerdictApproved, VerdictMinorIssues, VerdictNeedsFixes:
+	case "":
+		errs = append(errs, fmt.Errorf("verdict is missing"))
+	default:
+		errs = append(errs, fmt.Errorf("unknown verdict %q (want APPROVED, MINOR_ISSUES or NEEDS_FIXES)", r.Verdict))
+	}
+	if r.Verdict == VerdictNeedsFixes && len(r.Issues) == 0 {
+		errs = append(errs, fmt.Errorf("NEEDS_FIXES requires at least one issue"))
+	}
+	for i, issue := range r.Issues {
+		if strings.TrimSpace(issue.Description) == "" {
+			errs = append(errs, fmt.Errorf("issue %d has no description", i+1))
+		}
+		if !slices.Contains([]string{SeverityHigh, SeverityMedium, SeverityLow}, issue.Severity) {
+			errs = append(errs, fmt.Errorf("issue %d has severity %q (want high, medium or low)", i+1, issue.Severity))
+		}
+		if issue.Line < 0 {
+			errs = append(errs, fmt.Errorf("issue %d has a negative line number", i+1))
+		}
+	}
+	for i, todo := range r.SuggestedTodos {
+		if strings.TrimSpace(todo.Title) == "" {
+			errs = append(errs, fmt.Errorf("suggested TODO %d has no title", i+1))
+		}
+		if !slices.Contains([]Priority{PriorityNone, PriorityHigh, PriorityMedium, PriorityLow}, todo.Priority) {
+			errs = append(errs, fmt.Errorf("suggested TODO %d has priority %q (want high, medium or low)", i+1, todo.Priority))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseLegacyReview accepts the original format: a verdict keyword at the
+// start of the first non-blank line, optionally as a markdown heading or bold
+func parseLegacyReview(content string) (*CriticReview, error) {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		line = strings.TrimLeft(line, "#* \t")
+		for _, verdict := range []CriticVerdict{VerdictNeedsFixes, VerdictMinorIssues, VerdictApproved} {
+			if strings.HasPrefix(line, string(verdict)) {
+				return &CriticReview{Verdict: verdict, Body: content}, nil
+			}
+		}
+		break
+	}
+	return nil, fmt.Errorf("no verdict found: expected front matter with a verdict field, or a first line of APPROVED, MINOR_ISSUES or NEEDS_FIXES")
+}
+
+// FixInstructions renders the review as instructions for the fixer
+func (r *CriticReview) FixInstructions() string {
+	if !r.Structured {
+		return r.Body
+	}
+
+	var b strings.Builder
+	b.WriteString(string(r.Verdict) + "\n")
+	if r.Summary != "" {
+		b.WriteString("\n" + r.Summary + "\n")
+	}
+	if len(r.Issues) > 0 {
+		b.WriteString("\n## Issues\n")
+		for _, issue := range r.Issues {
+			b.WriteString("- " + issue.String() + "\n")
+		}
+	}
+	if r.Body != "" {
+		b.WriteString("\n" + r.Body + "\n")
+	}
+	return b.String()
+}
+
+// maxFeedbackLines caps the review lines attached to a blocked TODO
+const maxFeedbackLines = 5
+
+// Feedback condenses the review into single lines for TODO.md: the summary
+// and issues of a structured review, or the first lines of a legacy one
+func (r *CriticReview) Feedback() []string {
+	var lines []string
+	if r.Structured {
+		if r.Summary != "" {
+			lines = append(lines, oneLine(r.Summary))
+		}
+		for _, issue := range r.Issues {
+			lines = append(lines, oneLine(issue.String()))
+		}
+	} else {
+		// Skip the verdict line and markdown decoration
+		body := strings.Split(strings.TrimSpace(r.Body), "\n")
+		for _, line := range body[1:] {
+			line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#*-"))
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) > maxFeedbackLines {
+		lines = lines[:maxFeedbackLines]
+	}
+	return lines
+}
+
+// oneLine collapses whitespace, including newlines, into single spaces
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// String formats the issue as "[severity] file:line: description"
+func (i CriticIssue) String() string {
+	location := i.File
+	if location != "" && i.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, i.Line)
+	}
+	if location == "" {
+		return fmt.Sprintf("[%s] %s", i.Severity, i.Description)
+	}
+	return fmt.Sprintf("[%s] %s: %s", i.Severity, location, i.Description)
+}
+
+// AddSuggestedTodos adds the suggested TODOs to the "Pending" section of
+// TODO.md, skipping any whose title is already tracked. Returns the number added.
+func AddSuggestedTodos(todos []SuggestedTodo) (int, error) {
+	if len(todos) == 0 {
+		return 0, nil
+	}
+	list, err := LoadTodos()
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, s := range todos {
+		if list.Find(s.Title) != nil {
+			continue
+		}
+		priority := s.Priority
+		if priority == PriorityNone {
+			priority = PriorityLow
+		}
+		list.Add(&Todo{Title: strings.TrimSpace(s.Title), Completion: strings.TrimSpace(s.Completion), Priority: priority}, "Pending")
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	return added, list.Save()
+}
@@ -0,0 +1,187 @@
+package state
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseCriticReviewFrontMatter(t *testing.T) {
+	content := `
+---
+verdict: needs_fixes
+summary: Parser drops the last line.
+issues:
+  - file: internal/parse/parse.go
+    line: 42
+    severity: high
+    description: Off-by-one in the line loop
+  - severity: low
+    description: Missing doc comment
+suggested_todos:
+  - title: "Fix: add parser benchmarks"
+    completion: benchmark exists
+    priority: low
+---
+
+## How to Fix
+Loop to len(lines).
+`
+	review, err := ParseCriticReview(content)
+	if err != nil {
+		t.Fatalf("ParseCriticReview failed: %v", err)
+	}
+	if review.Verdict != VerdictNeedsFixes || !review.Structured {
+		t.Errorf("unexpected review %+v", review)
+	}
+	if len(review.Issues) != 2 || review.Issues[0].Line != 42 || review.Issues[0].File != "internal/parse/parse.go" {
+		t.Errorf("unexpected issues %+v", review.Issues)
+	}
+	if len(review.SuggestedTodos) != 1 || review.SuggestedTodos[0].Priority != PriorityLow {
+		t.Errorf("unexpected suggested TODOs %+v", review.SuggestedTodos)
+	}
+	if review.Body != "## How to Fix\nLoop to len(lines)." {
+		t.Errorf("unexpected body %q", review.Body)
+	}
+
+	instructions := review.FixInstructions()
+	for _, want := range []string{
+		"Parser drops the last line.",
+		"- [high] internal/parse/parse.go:42: Off-by-one in the line loop",
+		"- [low] Missing doc comment",
+		"## How to Fix",
+	} {
+		if !strings.Contains(instructions, want) {
+			t.Errorf("fix instructions missing %q:\n%s", want, instructions)
+		}
+	}
+}
+
+func TestParseCriticReviewJSON(t *testing.T) {
+	content := "---\n{\"verdict\": \"APPROVED\", \"summary\": \"ok\"}\n---\n"
+	review, err := ParseCriticReview(content)
+	if err != nil {
+		t.Fatalf("ParseCriticReview failed: %v", err)
+	}
+	if review.Verdict != VerdictApproved || review.Summary != "ok" {
+		t.Errorf("unexpected review %+v", review)
+	}
+}
+
+func TestParseCriticReviewLegacy(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected CriticVerdict
+	}{
+		{"APPROVED\n\nLooks good!", VerdictApproved},
+		{"\n\nNEEDS_FIXES\n\n## Issues\n- Bug found", VerdictNeedsFixes},
+		{"# MINOR_ISSUES\n\nSome style issues", VerdictMinorIssues},
+		{"**APPROVED**", VerdictApproved},
+		{"\ufeffAPPROVED", VerdictApproved},
+	}
+
+	for _, tt := range tests {
+		review, err := ParseCriticReview(tt.content)
+		if err != nil {
+			t.Errorf("for content %q: %v", tt.content, err)
+			continue
+		}
+		if review.Verdict != tt.expected || review.Structured {
+			t.Errorf("for content %q, expected %q, got %+v", tt.content, tt.expected, review)
+		}
+		if review.FixInstructions() != strings.TrimPrefix(tt.content, "\ufeff") {
+			t.Errorf("legacy fix instructions should be the whole file, got %q", review.FixInstructions())
+		}
+	}
+}
+
+func TestCriticReviewFeedback(t *testing.T) {
+	structured, err := ParseCriticReview(`---
+verdict: NEEDS_FIXES
+summary: |
+  Parser drops
+  trailing input.
+issues:
+  - file: parse.go
+    line: 12
+    severity: high
+    description: EOF is ignored
+---
+`)
+	if err != nil {
+		t.Fatalf("ParseCriticReview failed: %v", err)
+	}
+	legacy, _ := ParseCriticReview("## NEEDS_FIXES\n\n### Issues\n- one\n- two\n- three\n- four\n- five\n- six\n")
+
+	tests := []struct {
+		review *CriticReview
+		want   string
+	}{
+		{structured, "Parser drops trailing input.|[high] parse.go:12: EOF is ignored"},
+		{legacy, "Issues|one|two|three|four"},
+		{&CriticReview{Verdict: VerdictNeedsFixes, Body: "NEEDS_FIXES"}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.review.Feedback(), "|"); got != tt.want {
+			t.Errorf("Feedback() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestParseCriticReviewInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"empty", "", "no verdict found"},
+		{"prose", "The code looks fine.\n\nAPPROVED", "no verdict found"},
+		{"unclosed", "---\nverdict: APPROVED\n", "closing"},
+		{"bad yaml", "---\nverdict: [\n---\n", "invalid front matter"},
+		{"missing verdict", "---\nsummary: ok\n---\n", "verdict is missing"},
+		{"unknown verdict", "---\nverdict: LGTM\n---\n", "unknown verdict"},
+		{"fixes without issues", "---\nverdict: NEEDS_FIXES\n---\n", "at least one issue"},
+		{"bad severity", "---\nverdict: NEEDS_FIXES\nissues:\n  - severity: urgent\n    description: x\n---\n", "severity \"urgent\""},
+		{"issue without description", "---\nverdict: NEEDS_FIXES\nissues:\n  - severity: low\n---\n", "no description"},
+		{"todo without title", "---\nverdict: MINOR_ISSUES\nsuggested_todos:\n  - completion: x\n---\n", "no title"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCriticReview(tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAddSuggestedTodos(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	os.MkdirAll(AutoclaudeDir, 0755)
+	os.WriteFile(TodoPath(), []byte("# TODOs\n\n## Pending\n- [ ] **Existing** - Completion: done\n"), 0644)
+
+	added, err := AddSuggestedTodos([]SuggestedTodo{
+		{Title: "Existing"},
+		{Title: "Fix: naming", Completion: "renamed", Priority: PriorityMedium},
+		{Title: "Fix: docs"},
+	})
+	if err != nil {
+		t.Fatalf("AddSuggestedTodos failed: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("expected 2 added, got %d", added)
+	}
+
+	list, _ := LoadTodos()
+	if got := list.Find("Fix: naming"); got == nil || got.Priority != PriorityMedium || got.Completion != "renamed" {
+		t.Errorf("unexpected TODO %+v", got)
+	}
+	if got := list.Find("Fix: docs"); got == nil || got.Priority != PriorityLow {
+		t.Errorf("suggested TODOs should default to low priority, got %+v", got)
+	}
+}
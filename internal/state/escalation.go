@@ -0,0 +1,76 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EscalationFile is the report written when a run ends with blocked TODOs
+const EscalationFile = "ESCALATION.md"
+
+// EscalationPath returns the path to the escalation report
+func EscalationPath() string {
+	return filepath.Join(AutoclaudeDir, EscalationFile)
+}
+
+// EscalationReport describes the blocked TODOs that need a human, what they
+// hold up, and the deferred ones, as markdown
+func EscalationReport(list *TodoList) string {
+	blocked := list.WithStatus(StatusBlocked)
+
+	var b strings.Builder
+	b.WriteString("# Needs attention\n\n")
+	fmt.Fprintf(&b, "The loop stopped with %d blocked TODO(s) instead of running the evaluator. ", len(blocked))
+	b.WriteString("For each one, fix the problem yourself, re-plan it, or change its `Status:` line in TODO.md ")
+	b.WriteString("(remove it to retry, or set `deferred` or `skipped`), then run `autoclaude resume`.\n")
+
+	if len(blocked) > 0 {
+		b.WriteString("\n## Blocked\n")
+	}
+	for _, t := range blocked {
+		fmt.Fprintf(&b, "\n### %s\n\n", t.Title)
+		if t.Completion != "" {
+			fmt.Fprintf(&b, "Completion: %s\n\n", t.Completion)
+		}
+		for _, sub := range t.SubBullets {
+			fmt.Fprintf(&b, "- %s\n", sub)
+		}
+		if waiting := list.waitingFor(t); len(waiting) > 0 {
+			fmt.Fprintf(&b, "- Waiting on it: %s\n", strings.Join(waiting, ", "))
+		}
+	}
+
+	if deferred := list.WithStatus(StatusDeferred); len(deferred) > 0 {
+		b.WriteString("\n## Deferred\n\n")
+		for _, t := range deferred {
+			fmt.Fprintf(&b, "- %s\n", t.Title)
+		}
+	}
+	return b.String()
+}
+
+// waitingFor returns the titles of the open TODOs held up by t
+func (l *TodoList) waitingFor(t *Todo) []string {
+	var titles []string
+	for _, other := range l.Incomplete() {
+		if other.Status == StatusOpen && l.WaitingOn(other) == t {
+			titles = append(titles, other.Title)
+		}
+	}
+	return titles
+}
+
+// WriteEscalation writes the escalation report for the TODO list
+func WriteEscalation(list *TodoList) error {
+	if err := os.WriteFile(EscalationPath(), []byte(EscalationReport(list)), 0644); err != nil {
+		return fmt.Errorf("failed to write escalation report: %w", err)
+	}
+	return nil
+}
+
+// ClearEscalation removes a previous escalation report
+func ClearEscalation() {
+	os.Remove(EscalationPath())
+}
@@ -0,0 +1,53 @@
+package state
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEscalationReport(t *testing.T) {
+	list := ParseTodos(`- [ ] **Parser** - Completion: parses fixtures
+  - Status: blocked
+  - Blocked: not approved after 3 review attempts
+  - Critic feedback: [high] parse.go:12: EOF is ignored
+- [ ] **Printer** - Completion: done
+  - Dependencies: Parser
+- [ ] **Docs** - Completion: done
+  - Status: deferred
+- [x] **Setup** - Completion: done
+`)
+
+	report := EscalationReport(list)
+	for _, want := range []string{
+		"1 blocked TODO(s)",
+		"### Parser\n\nCompletion: parses fixtures\n\n- Blocked: not approved after 3 review attempts\n- Critic feedback: [high] parse.go:12: EOF is ignored\n- Waiting on it: Printer\n",
+		"## Deferred\n\n- Docs\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "Setup") {
+		t.Errorf("completed TODOs should not be reported:\n%s", report)
+	}
+}
+
+func TestWriteEscalation(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+	os.MkdirAll(AutoclaudeDir, 0755)
+
+	if err := WriteEscalation(ParseTodos("- [ ] **A** - Completion: done\n  - Status: blocked\n")); err != nil {
+		t.Fatalf("WriteEscalation failed: %v", err)
+	}
+	if _, err := os.Stat(EscalationPath()); err != nil {
+		t.Errorf("report should exist: %v", err)
+	}
+	ClearEscalation()
+	if _, err := os.Stat(EscalationPath()); !os.IsNotExist(err) {
+		t.Error("report should be removed")
+	}
+}
@@ -0,0 +1,201 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EventsFile is the append-only journal of what the loop and its hooks did.
+// It is written for people and tools to read; the loop's own counters and
+// usage are kept in Stats, and the journal is never read back into them.
+const EventsFile = "events.jsonl"
+
+// EventType says what an event records
+type EventType string
+
+const (
+	EventRunStarted    EventType = "run_started"
+	EventRunEnded      EventType = "run_ended" // Message is the outcome
+	EventTodoStarted   EventType = "todo_started"
+	EventTodoCompleted EventType = "todo_completed"
+	EventTodoBlocked   EventType = "todo_blocked"
+	EventPhaseStarted  EventType = "phase_started"
+	EventPhaseEnded    EventType = "phase_ended"
+	EventTimeout       EventType = "timeout"       // A session was stopped for running too long or stalling
+	EventRetry         EventType = "retry"         // A session that failed with a transient API error is run again
+	EventSessionEnded  EventType = "session_ended" // Written by the stop hook
+	EventCheck         EventType = "check"         // Test or lint command run by the loop
+	EventVerdict       EventType = "verdict"
+	EventCommitCreated EventType = "commit_created" // Claude committed during a phase
+	EventForcedCommit  EventType = "forced_commit"  // The loop committed what Claude left behind
+	EventCommitRefused EventType = "commit_refused" // The commit guard refused a forced commit
+	EventPrune         EventType = "prune"
+	EventBudgetHit     EventType = "budget_hit"
+	EventEscalated     EventType = "escalated"
+	EventToolDenied    EventType = "tool_denied" // Written by the PreToolUse hooks
+	EventError         EventType = "error"
+)
+
+// Event is one line of the journal. Fields that don't apply to the type are
+// left empty.
+type Event struct {
+	Time       time.Time     `json:"time"`
+	Type       EventType     `json:"type"`
+	Todo       string        `json:"todo,omitempty"` // ID of the TODO being worked on
+	Phase      Phase         `json:"phase,omitempty"`
+	Model      string        `json:"model,omitempty"`
+	SessionID  string        `json:"sessionId,omitempty"`
+	Before     string        `json:"before,omitempty"` // HEAD before the phase or commit
+	Commit     string        `json:"commit,omitempty"` // HEAD after it
+	DurationMs int64         `json:"durationMs,omitempty"`
+	Verdict    CriticVerdict `json:"verdict,omitempty"`
+	ExitCode   int           `json:"exitCode,omitempty"` // Of a check
+	Tokens     int           `json:"tokens,omitempty"`
+	CostUSD    float64       `json:"costUsd,omitempty"`
+	Message    string        `json:"message,omitempty"` // Outcome, reason, command or error
+}
+
+// EventsPath returns the path to the event journal
+func EventsPath() string {
+	return filepath.Join(AutoclaudeDir, EventsFile)
+}
+
+// CurrentTodoID returns the ID of the TODO being worked on, or "" if none is
+func CurrentTodoID() string {
+	title := CurrentTodoTitle()
+	if title == "" {
+		return ""
+	}
+	return (&Todo{Title: title}).ID()
+}
+
+// RecordEvent appends an event to the journal, stamping the time and the
+// current TODO if they aren't set
+func RecordEvent(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Todo == "" {
+		e.Todo = CurrentTodoID()
+	}
+	return appendEvents(EventsPath(), e)
+}
+
+// AppendEvents adds events recorded elsewhere, such as by a parallel worker,
+// to the journal as they are
+func AppendEvents(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return appendEvents(EventsPath(), events...)
+}
+
+func appendEvents(path string, events ...Event) error {
+	var b strings.Builder
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// One write per call, so lines from the loop and its hooks don't interleave
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open event journal: %w", err)
+	}
+	defer f.Close()
+	data := b.String()
+	// Start on a new line if a crash cut the last one short
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = "\n" + data
+		}
+	}
+	if _, err := f.WriteString(data); err != nil {
+		return fmt.Errorf("failed to write event journal: %w", err)
+	}
+	return nil
+}
+
+// LoadEvents reads the journal. A missing journal has no events, and lines
+// that can't be parsed, such as one cut short by a crash, are skipped.
+func LoadEvents() ([]Event, error) {
+	return LoadEventsFrom(".")
+}
+
+// LoadEventsFrom reads the journal of the project in dir
+func LoadEventsFrom(dir string) ([]Event, error) {
+	f, err := os.Open(filepath.Join(dir, EventsPath()))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if json.Unmarshal(scanner.Bytes(), &e) == nil && e.Type != "" {
+			events = append(events, e)
+		}
+	}
+	return events, scanner.Err()
+}
+
+// String formats the event as a one-line summary for status output
+func (e Event) String() string {
+	var b strings.Builder
+	b.WriteString(e.Time.Local().Format("2006-01-02 15:04:05") + " " + string(e.Type))
+	if e.Phase != "" {
+		b.WriteString(" " + string(e.Phase))
+	}
+	if e.Verdict != "" {
+		b.WriteString(" " + string(e.Verdict))
+	}
+	if e.Todo != "" {
+		b.WriteString(" [" + e.Todo + "]")
+	}
+	if e.Commit != "" {
+		b.WriteString(" @" + e.Commit)
+	}
+	if e.DurationMs > 0 {
+		b.WriteString(" " + (time.Duration(e.DurationMs) * time.Millisecond).Round(time.Second).String())
+	}
+	if e.ExitCode != 0 {
+		fmt.Fprintf(&b, " exit %d", e.ExitCode)
+	}
+	if e.Message != "" {
+		msg, _, _ := strings.Cut(e.Message, "\n")
+		b.WriteString(": " + msg)
+	}
+	return b.String()
+}
+
+// PhaseDurations sums the time spent in each phase from the journal's
+// phase_ended events
+func PhaseDurations(events []Event) map[Phase]time.Duration {
+	durations := make(map[Phase]time.Duration)
+	for _, e := range events {
+		if e.Type == EventPhaseEnded {
+			durations[e.Phase] += time.Duration(e.DurationMs) * time.Millisecond
+		}
+	}
+	return durations
+}
@@ -0,0 +1,64 @@
+package state
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndLoadEvents(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	if events, err := LoadEvents(); err != nil || events != nil {
+		t.Fatalf("expected no events without a journal, got %v (%v)", events, err)
+	}
+
+	os.MkdirAll(AutoclaudeDir, 0755)
+	SetCurrentTodo("**Add parser** - Completion: parses input")
+	if err := RecordEvent(Event{Type: EventPhaseEnded, Phase: PhaseCoder, DurationMs: 90_000, Commit: "abc1234"}); err != nil {
+		t.Fatalf("RecordEvent failed: %v", err)
+	}
+	// A line cut short by a crash is skipped
+	f, _ := os.OpenFile(EventsPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"time": "2026-01-0`)
+	f.Close()
+	ClearCurrentTodo()
+	if err := RecordEvent(Event{Type: EventVerdict, Phase: PhaseCritic, Verdict: VerdictApproved, Todo: "other"}); err != nil {
+		t.Fatalf("RecordEvent failed: %v", err)
+	}
+
+	events, err := LoadEvents()
+	if err != nil {
+		t.Fatalf("LoadEvents failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	}
+	first := events[0]
+	if first.Type != EventPhaseEnded || first.Todo != "add-parser" || first.Time.IsZero() {
+		t.Errorf("unexpected first event %+v", first)
+	}
+	if got := first.String(); !strings.Contains(got, "phase_ended coder [add-parser] @abc1234 1m30s") {
+		t.Errorf("unexpected summary %q", got)
+	}
+	if second := events[1]; second.Verdict != VerdictApproved || second.Todo != "other" {
+		t.Errorf("unexpected second event %+v", second)
+	}
+}
+
+func TestPhaseDurations(t *testing.T) {
+	events := []Event{
+		{Type: EventPhaseStarted, Phase: PhaseCoder},
+		{Type: EventPhaseEnded, Phase: PhaseCoder, DurationMs: 60_000},
+		{Type: EventPhaseEnded, Phase: PhaseCritic, DurationMs: 20_000},
+		{Type: EventPhaseEnded, Phase: PhaseCoder, DurationMs: 30_000},
+	}
+	durations := PhaseDurations(events)
+	if durations[PhaseCoder] != 90*time.Second || durations[PhaseCritic] != 20*time.Second || len(durations) != 2 {
+		t.Errorf("unexpected durations %v", durations)
+	}
+}
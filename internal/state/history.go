@@ -0,0 +1,130 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HistoryFile records every run of the loop with its outcome and stats
+const HistoryFile = "history.json"
+
+// Outcomes of a run
+const (
+	OutcomeRunning     = "running"
+	OutcomeComplete    = "complete"
+	OutcomeBudget      = "budget exceeded"
+	OutcomeEscalated   = "escalated"
+	OutcomeFailed      = "failed"
+	OutcomeInterrupted = "interrupted" // The loop was killed without recording how it ended
+)
+
+// RunRecord is one run of the loop, from `autoclaude run` through any resumes
+type RunRecord struct {
+	ID        string    `json:"id"`
+	Goal      string    `json:"goal"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt,omitzero"` // When the loop last stopped
+	Outcome   string    `json:"outcome"`
+	Stats     *Stats    `json:"stats,omitempty"`
+}
+
+// HistoryPath returns the path to the run history
+func HistoryPath() string {
+	return filepath.Join(AutoclaudeDir, HistoryFile)
+}
+
+// NewRunID returns the ID of a run started at t
+func NewRunID(t time.Time) string {
+	return t.Format("20060102-150405")
+}
+
+// StartRun gives the state a new run ID and fresh stats
+func (s *State) StartRun(now time.Time) {
+	s.RunID = NewRunID(now)
+	s.RunStartedAt = now
+	s.Stats = &Stats{}
+}
+
+// RunRecord returns the history record of the state's run
+func (s *State) RunRecord(outcome string, ended time.Time) RunRecord {
+	return RunRecord{
+		ID:        s.RunID,
+		Goal:      s.Goal,
+		StartedAt: s.RunStartedAt,
+		EndedAt:   ended,
+		Outcome:   outcome,
+		Stats:     s.Stats,
+	}
+}
+
+// LoadHistory reads the run history, oldest run first. A missing history
+// has no runs.
+func LoadHistory() ([]RunRecord, error) {
+	data, err := os.ReadFile(HistoryPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []RunRecord
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("failed to parse run history: %w", err)
+	}
+	return runs, nil
+}
+
+// SaveRun adds a run to the history, replacing the record of the same run
+// if it was saved before. Only one loop runs in a project at a time, so any
+// other run still marked running was interrupted.
+func SaveRun(r RunRecord) error {
+	runs, err := LoadHistory()
+	if err != nil {
+		return err
+	}
+	found := false
+	for i := range runs {
+		switch {
+		case runs[i].ID == r.ID:
+			runs[i] = r
+			found = true
+		case runs[i].Outcome == OutcomeRunning:
+			runs[i].Outcome = OutcomeInterrupted
+		}
+	}
+	if !found {
+		runs = append(runs, r)
+	}
+
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run history: %w", err)
+	}
+	if err := os.WriteFile(HistoryPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	return nil
+}
+
+// AcceptRate returns the share of critic reviews that accepted the work,
+// and false if there were no reviews
+func (st *Stats) AcceptRate() (float64, bool) {
+	reviews := st.CriticApprovals + st.CriticMinor + st.CriticRejections
+	if reviews == 0 {
+		return 0, false
+	}
+	return float64(st.CriticApprovals+st.CriticMinor) / float64(reviews), true
+}
+
+// FixesPerTodo returns the average number of fix attempts per TODO
+// attempted, and false if no TODO was attempted
+func (st *Stats) FixesPerTodo() (float64, bool) {
+	if st.TodosAttempted == 0 {
+		return 0, false
+	}
+	return float64(st.FixAttempts) / float64(st.TodosAttempted), true
+}
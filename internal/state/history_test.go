@@ -0,0 +1,71 @@
+package state
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSaveRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+	os.MkdirAll(AutoclaudeDir, 0755)
+
+	if runs, err := LoadHistory(); err != nil || runs != nil {
+		t.Fatalf("expected no runs without a history, got %v (%v)", runs, err)
+	}
+
+	start := time.Date(2026, 1, 5, 10, 4, 12, 0, time.Local)
+	s := NewState("goal", "go test", "", 10)
+	s.StartRun(start)
+	if s.RunID != "20260105-100412" || s.Stats == nil {
+		t.Fatalf("unexpected run %q with stats %v", s.RunID, s.Stats)
+	}
+	if err := SaveRun(s.RunRecord(OutcomeRunning, time.Time{})); err != nil {
+		t.Fatalf("SaveRun failed: %v", err)
+	}
+	s.Stats.TodosCompleted = 2
+	if err := SaveRun(s.RunRecord(OutcomeBudget, start.Add(time.Hour))); err != nil {
+		t.Fatalf("SaveRun failed: %v", err)
+	}
+
+	// A later run that was killed stays running until the next one starts
+	s.StartRun(start.Add(24 * time.Hour))
+	SaveRun(s.RunRecord(OutcomeRunning, time.Time{}))
+	s.StartRun(start.Add(48 * time.Hour))
+	SaveRun(s.RunRecord(OutcomeRunning, time.Time{}))
+
+	runs, err := LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(runs) != 3 {
+		t.Fatalf("expected 3 runs, got %+v", runs)
+	}
+	if r := runs[0]; r.Outcome != OutcomeBudget || r.Stats.TodosCompleted != 2 || !r.EndedAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("unexpected first run %+v", r)
+	}
+	if runs[1].Outcome != OutcomeInterrupted || runs[2].Outcome != OutcomeRunning {
+		t.Errorf("expected interrupted then running, got %q and %q", runs[1].Outcome, runs[2].Outcome)
+	}
+}
+
+func TestStatsRates(t *testing.T) {
+	st := &Stats{}
+	if _, ok := st.AcceptRate(); ok {
+		t.Error("expected no accept rate without reviews")
+	}
+	if _, ok := st.FixesPerTodo(); ok {
+		t.Error("expected no fixes per TODO without TODOs")
+	}
+
+	st = &Stats{CriticApprovals: 2, CriticMinor: 1, CriticRejections: 1, TodosAttempted: 4, FixAttempts: 2}
+	if rate, _ := st.AcceptRate(); rate != 0.75 {
+		t.Errorf("expected accept rate 0.75, got %v", rate)
+	}
+	if fixes, _ := st.FixesPerTodo(); fixes != 0.5 {
+		t.Errorf("expected 0.5 fixes per TODO, got %v", fixes)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Step represents the current step in the coder-critic loop
@@ -13,7 +14,9 @@ type Step string
 
 const (
 	StepCoder     Step = "coder"
+	StepTest      Step = "test"
 	StepCritic    Step = "critic"
+	StepFixer     Step = "fixer"
 	StepEvaluator Step = "evaluator"
 	StepDone      Step = "done"
 )
@@ -28,10 +31,17 @@ type State struct {
 	Constraints   string `json:"constraints,omitempty"`
 	LastCommit    string `json:"lastCommit,omitempty"`
 	RetryCount    int    `json:"retryCount,omitempty"`
+	RetryLimit    int    `json:"retryLimit,omitempty"` // Review attempts per TODO for the current run
+	TodoBranch    string `json:"todoBranch,omitempty"` // Branch the current TODO is worked on in (branch-per-TODO mode)
+	BaseBranch    string `json:"baseBranch,omitempty"` // Branch the TODO branch is merged back into
+	TodoStartCommit string `json:"todoStartCommit,omitempty"` // HEAD before the coder started on the current TODO
 	LastError     string `json:"lastError,omitempty"`
+	FixInstructions string `json:"fixInstructions,omitempty"` // Feedback for the pending fixer phase
 	Stats         *Stats `json:"stats,omitempty"`
 	LastPruneAt   int64  `json:"lastPruneAt,omitempty"`
 	TodosSincePrune int   `json:"todosSincePrune,omitempty"`
+	RunID         string    `json:"runId,omitempty"` // Run in the history that the stats belong to
+	RunStartedAt  time.Time `json:"runStartedAt,omitzero"`
 }
 
 // Stats tracks diagnostic information about the run
@@ -44,6 +54,17 @@ type Stats struct {
 	CriticMinor     int `json:"criticMinor"`     // Times critic said MINOR_ISSUES
 	FixAttempts     int `json:"fixAttempts"`     // Number of fix attempts
 	FixSuccesses    int `json:"fixSuccesses"`    // Fixes that led to approval
+	TestRuns        int `json:"testRuns"`        // Test command runs by the orchestrator
+	TestFailures    int `json:"testFailures"`    // Orchestrator test runs that failed
+	LintRuns        int `json:"lintRuns"`        // Lint command runs by the orchestrator
+	LintFailures    int `json:"lintFailures"`    // Orchestrator lint runs that failed
+	CommitsRefused  int `json:"commitsRefused"`  // Forced commits the commit guard refused
+	SessionRetries  int `json:"sessionRetries,omitempty"` // Sessions run again after a transient API error
+	ElapsedMs       int64 `json:"elapsedMs"`      // Wall-clock time spent in the loop, across resumes
+
+	Usage      Usage             `json:"usage"`                // Tokens and cost across all sessions
+	PhaseUsage map[Phase]*Usage  `json:"phaseUsage,omitempty"` // Tokens and cost by phase
+	TodoUsage  map[string]*Usage `json:"todoUsage,omitempty"`  // Tokens and cost by TODO title
 }
 
 const (
@@ -54,7 +75,6 @@ const (
 	StatusFile         = "STATUS.md"
 	CriticVerdictFile  = "critic_verdict.md"
 	CurrentTodoFile    = "current_todo.txt"
-	DefaultPruneInterval = 5 // Number of TODOs to complete before auto-pruning
 )
 
 // StateDir returns the path to the .autoclaude directory
@@ -106,16 +126,11 @@ func GetCriticVerdict() (CriticVerdict, string) {
 	}
 	content := string(data)
 
-	if len(content) >= 11 && content[:11] == "NEEDS_FIXES" {
-		return VerdictNeedsFixes, content
-	}
-	if len(content) >= 12 && content[:12] == "MINOR_ISSUES" {
-		return VerdictMinorIssues, content
-	}
-	if len(content) >= 8 && content[:8] == "APPROVED" {
-		return VerdictApproved, content
+	review, err := ParseCriticReview(content)
+	if err != nil {
+		return VerdictUnknown, content
 	}
-	return VerdictUnknown, content
+	return review.Verdict, content
 }
 
 // ClearCriticVerdict removes the critic verdict file
@@ -128,22 +143,36 @@ func CurrentTodoPath() string {
 	return filepath.Join(AutoclaudeDir, CurrentTodoFile)
 }
 
-// GetNextTodo returns the first incomplete TODO item from TODO.md
+// NextTodo loads TODO.md and selects the next TODO to work on (see TodoList.Next)
+func NextTodo() (*Todo, error) {
+	list, err := LoadTodos()
+	if err != nil {
+		return nil, err
+	}
+	return list.Next()
+}
+
+// HasIncompleteTodos checks if TODO.md has any incomplete TODOs left to work
+// on. Blocked TODOs and the ones waiting on them don't count.
+func HasIncompleteTodos() bool {
+	list, err := LoadTodos()
+	if err != nil {
+		return false
+	}
+	return len(list.Open()) > 0
+}
+
+// GetNextTodo returns the text of the next TODO to work on from TODO.md
 func GetNextTodo() string {
-	data, err := os.ReadFile(TodoPath())
+	todo, err := NextTodo()
 	if err != nil {
 		return "(unknown)"
 	}
-
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "- [ ]") {
-			// Return the TODO text without the checkbox
-			return strings.TrimSpace(strings.TrimPrefix(trimmed, "- [ ]"))
-		}
+	if todo == nil {
+		return "(no incomplete TODOs)"
 	}
-	return "(no incomplete TODOs)"
+	// Return the TODO text without the checkbox
+	return todo.Header()
 }
 
 // SetCurrentTodo saves the current TODO being worked on to a file
@@ -160,6 +189,16 @@ func GetCurrentTodo() string {
 	return strings.TrimSpace(string(data))
 }
 
+// CurrentTodoTitle returns the title of the TODO being worked on, or empty if none
+func CurrentTodoTitle() string {
+	currentTodo := GetCurrentTodo()
+	if currentTodo == "(unknown)" {
+		return ""
+	}
+	title, _ := parseTodoHeader(currentTodo)
+	return title
+}
+
 // ClearCurrentTodo removes the current todo file
 func ClearCurrentTodo() {
 	os.Remove(CurrentTodoPath())
@@ -179,7 +218,12 @@ func NewState(goal, testCmd, constraints string, maxIterations int) *State {
 
 // Load loads state from the state file
 func Load() (*State, error) {
-	data, err := os.ReadFile(StatePath())
+	return LoadFrom(".")
+}
+
+// LoadFrom loads the state of the project rooted at dir, such as a worktree
+func LoadFrom(dir string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(dir, StatePath()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read state file: %w", err)
 	}
@@ -194,7 +238,12 @@ func Load() (*State, error) {
 
 // Save saves the state to the state file
 func (s *State) Save() error {
-	if err := os.MkdirAll(AutoclaudeDir, 0755); err != nil {
+	return s.SaveTo(".")
+}
+
+// SaveTo saves the state into the project rooted at dir, such as a worktree
+func (s *State) SaveTo(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, AutoclaudeDir), 0755); err != nil {
 		return fmt.Errorf("failed to create state directory: %w", err)
 	}
 
@@ -203,7 +252,7 @@ func (s *State) Save() error {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	if err := os.WriteFile(StatePath(), data, 0644); err != nil {
+	if err := os.WriteFile(filepath.Join(dir, StatePath()), data, 0644); err != nil {
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
@@ -270,7 +319,11 @@ func (s *State) UpdateStatus(message string) error {
 
 	retryInfo := ""
 	if s.Step == StepCritic && s.RetryCount > 0 {
-		retryInfo = fmt.Sprintf(" (attempt %d/%d)", s.RetryCount+1, 3)
+		if s.RetryLimit > 0 {
+			retryInfo = fmt.Sprintf(" (attempt %d/%d)", s.RetryCount+1, s.RetryLimit)
+		} else {
+			retryInfo = fmt.Sprintf(" (attempt %d)", s.RetryCount+1)
+		}
 	}
 
 	content := fmt.Sprintf(`# Status
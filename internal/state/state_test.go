@@ -140,11 +140,19 @@ func TestCurrentTodo(t *testing.T) {
 		t.Errorf("expected 'Test TODO item', got %q", got)
 	}
 
+	SetCurrentTodo("**Add parser** - Completion: tests pass")
+	if title := CurrentTodoTitle(); title != "Add parser" {
+		t.Errorf("expected title 'Add parser', got %q", title)
+	}
+
 	ClearCurrentTodo()
 	got = GetCurrentTodo()
 	if got != "(unknown)" {
 		t.Errorf("expected '(unknown)' after clear, got %q", got)
 	}
+	if title := CurrentTodoTitle(); title != "" {
+		t.Errorf("expected empty title after clear, got %q", title)
+	}
 }
 
 func TestCriticVerdict(t *testing.T) {
@@ -162,6 +170,8 @@ func TestCriticVerdict(t *testing.T) {
 		{"APPROVED\n\nLooks good!", VerdictApproved},
 		{"NEEDS_FIXES\n\n## Issues\n- Bug found", VerdictNeedsFixes},
 		{"MINOR_ISSUES\n\nSome style issues", VerdictMinorIssues},
+		{"\n# NEEDS_FIXES\n\n- Bug found", VerdictNeedsFixes},
+		{"---\nverdict: APPROVED\n---\nLooks good!", VerdictApproved},
 		{"Something else", VerdictUnknown},
 	}
 
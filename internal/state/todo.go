@@ -0,0 +1,621 @@
+package state
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Priority is the priority of a TODO as written by the planner or critic
+type Priority string
+
+const (
+	PriorityHigh   Priority = "high"
+	PriorityMedium Priority = "medium"
+	PriorityLow    Priority = "low"
+	PriorityNone   Priority = "" // No Priority: sub-bullet present
+)
+
+// TodoStatus marks an unchecked TODO that the loop should not select
+type TodoStatus string
+
+const (
+	StatusOpen     TodoStatus = ""         // No Status: sub-bullet present
+	StatusBlocked  TodoStatus = "blocked"  // Exhausted its review attempts; needs a human
+	StatusDeferred TodoStatus = "deferred" // Postponed; TODOs that depend on it wait
+	StatusSkipped  TodoStatus = "skipped"  // Won't be done; TODOs that depend on it go ahead
+)
+
+// todoStatuses lists the valid non-open statuses
+var todoStatuses = []TodoStatus{StatusBlocked, StatusDeferred, StatusSkipped}
+
+// todoHeaderRe matches a checkbox line: indent, checkbox mark, and the rest of the line
+var todoHeaderRe = regexp.MustCompile(`^(\s*)- \[([ xX])\](.*)$`)
+
+// Todo is a single checkbox item in TODO.md. The planner writes these as
+// "- [ ] **Task name** - Completion: criteria" followed by indented
+// "Priority:", "Dependencies:" and "Status:" sub-bullets.
+type Todo struct {
+	Title        string     // Task name (without ** markers)
+	Completion   string     // Completion criteria
+	Priority     Priority   // Priority from the "Priority:" sub-bullet
+	Dependencies []string   // Task names from the "Dependencies:" sub-bullet
+	Status       TodoStatus // Status from the "Status:" sub-bullet
+	Section      string     // Enclosing "## " heading, e.g. "Pending"
+	Checked      bool       // Whether the checkbox is ticked
+	SubBullets   []string   // Other sub-bullets, without the "- " prefix
+
+	// raw holds the lines the TODO was parsed from, and parsed a copy of the
+	// fields at parse time. Unchanged TODOs are written back verbatim.
+	raw    []string
+	indent string
+	parsed *Todo
+}
+
+// TodoList is a parsed TODO.md file
+type TodoList struct {
+	Todos []*Todo
+
+	// blocks holds the file in order: either raw lines or a TODO
+	blocks []todoBlock
+}
+
+type todoBlock struct {
+	line string
+	todo *Todo
+}
+
+// ParseTodos parses TODO.md content into a TodoList
+func ParseTodos(content string) *TodoList {
+	list := &TodoList{}
+	lines := strings.Split(content, "\n")
+	section := ""
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "## ") {
+			section = strings.TrimSpace(strings.TrimPrefix(trimmed, "## "))
+		}
+
+		m := todoHeaderRe.FindStringSubmatch(line)
+		if m == nil {
+			list.blocks = append(list.blocks, todoBlock{line: line})
+			continue
+		}
+
+		todo := &Todo{
+			Section: section,
+			Checked: m[2] != " ",
+			indent:  m[1],
+			raw:     []string{line},
+		}
+		todo.Title, todo.Completion = parseTodoHeader(strings.TrimSpace(m[3]))
+
+		// Collect indented sub-bullets belonging to this TODO
+		for i+1 < len(lines) {
+			next := lines[i+1]
+			if strings.TrimSpace(next) == "" || todoHeaderRe.MatchString(next) {
+				break
+			}
+			if len(leadingSpace(next)) <= len(todo.indent) {
+				break
+			}
+			todo.raw = append(todo.raw, next)
+			todo.parseSubBullet(strings.TrimSpace(next))
+			i++
+		}
+
+		todo.parsed = todo.clone()
+		list.Todos = append(list.Todos, todo)
+		list.blocks = append(list.blocks, todoBlock{todo: todo})
+	}
+
+	return list
+}
+
+// LoadTodos reads and parses TODO.md
+func LoadTodos() (*TodoList, error) {
+	data, err := os.ReadFile(TodoPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TODO file: %w", err)
+	}
+	return ParseTodos(string(data)), nil
+}
+
+// Save writes the TODO list back to TODO.md
+func (l *TodoList) Save() error {
+	if err := os.WriteFile(TodoPath(), []byte(l.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write TODO file: %w", err)
+	}
+	return nil
+}
+
+// String renders the TODO list. Unmodified content is reproduced exactly.
+func (l *TodoList) String() string {
+	var lines []string
+	for _, b := range l.blocks {
+		if b.todo == nil {
+			lines = append(lines, b.line)
+			continue
+		}
+		lines = append(lines, b.todo.render()...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Incomplete returns the unchecked TODOs in file order
+func (l *TodoList) Incomplete() []*Todo {
+	var result []*Todo
+	for _, t := range l.Todos {
+		if !t.Checked {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// Open returns the unchecked TODOs the loop can still work on, in file order:
+// those without a status that don't wait on a blocked or deferred TODO
+func (l *TodoList) Open() []*Todo {
+	var result []*Todo
+	for _, t := range l.Incomplete() {
+		if t.Status == StatusOpen && l.WaitingOn(t) == nil {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// WithStatus returns the unchecked TODOs with the given status, in file order
+func (l *TodoList) WithStatus(status TodoStatus) []*Todo {
+	var result []*Todo
+	for _, t := range l.Incomplete() {
+		if t.Status == status {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// WaitingOn returns the blocked or deferred TODO that t depends on, directly
+// or through other unchecked TODOs, or nil if there is none
+func (l *TodoList) WaitingOn(t *Todo) *Todo {
+	return l.waitingOn(t, map[*Todo]bool{})
+}
+
+func (l *TodoList) waitingOn(t *Todo, seen map[*Todo]bool) *Todo {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+	for _, dep := range t.Dependencies {
+		d := l.Find(dep)
+		if d == nil || d.resolved() {
+			continue
+		}
+		if d.Status == StatusBlocked || d.Status == StatusDeferred {
+			return d
+		}
+		if held := l.waitingOn(d, seen); held != nil {
+			return held
+		}
+	}
+	return nil
+}
+
+// resolved reports whether TODOs that depend on t can go ahead
+func (t *Todo) resolved() bool {
+	return t.Checked || t.Status == StatusSkipped
+}
+
+// Completed returns the checked TODOs in file order
+func (l *TodoList) Completed() []*Todo {
+	var result []*Todo
+	for _, t := range l.Todos {
+		if t.Checked {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// Find returns the TODO with the given title (case-insensitive), or nil
+func (l *TodoList) Find(title string) *Todo {
+	key := normalizeTodoTitle(title)
+	for _, t := range l.Todos {
+		if normalizeTodoTitle(t.Title) == key {
+			return t
+		}
+	}
+	return nil
+}
+
+// Next selects the TODO to work on: among open TODOs whose dependencies are
+// all checked off or skipped, the highest priority one, ties broken by file
+// order. Returns nil with no error when nothing is left. Dependency cycles,
+// dependencies on unknown tasks and unknown statuses are reported as errors.
+func (l *TodoList) Next() (*Todo, error) {
+	ready, err := l.Ready()
+	if err != nil || len(ready) == 0 {
+		return nil, err
+	}
+	return ready[0], nil
+}
+
+// Ready returns the open TODOs whose dependencies are all checked off or
+// skipped, in the order Next would select them. None of them depends on another, so
+// they can be worked on in parallel.
+func (l *TodoList) Ready() ([]*Todo, error) {
+	if err := l.validateDependencies(); err != nil {
+		return nil, err
+	}
+
+	open := l.Open()
+	var ready []*Todo
+	for _, t := range open {
+		if l.dependenciesDone(t) {
+			ready = append(ready, t)
+		}
+	}
+	slices.SortStableFunc(ready, func(a, b *Todo) int {
+		return a.Priority.rank() - b.Priority.rank()
+	})
+
+	if len(ready) == 0 && len(open) > 0 {
+		// Unreachable when validation passes, but never report "done" with work left
+		return nil, fmt.Errorf("no incomplete TODO has all of its dependencies completed")
+	}
+	return ready, nil
+}
+
+// dependenciesDone reports whether every dependency of t is checked off or skipped
+func (l *TodoList) dependenciesDone(t *Todo) bool {
+	for _, dep := range t.Dependencies {
+		if d := l.Find(dep); d == nil || !d.resolved() {
+			return false
+		}
+	}
+	return true
+}
+
+// validateDependencies checks that incomplete TODOs have a known status and
+// only depend on known tasks, and that the dependency graph between
+// incomplete TODOs has no cycles
+func (l *TodoList) validateDependencies() error {
+	for _, t := range l.Incomplete() {
+		if t.Status != StatusOpen && !slices.Contains(todoStatuses, t.Status) {
+			return fmt.Errorf("TODO %q has unknown status %q (expected blocked, deferred or skipped)", t.Title, t.Status)
+		}
+		for _, dep := range t.Dependencies {
+			if l.Find(dep) == nil {
+				return fmt.Errorf("TODO %q depends on unknown task %q", t.Title, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[*Todo]int)
+	var path []string
+
+	var visit func(t *Todo) error
+	visit = func(t *Todo) error {
+		switch marks[t] {
+		case visiting:
+			return fmt.Errorf("dependency cycle in TODOs: %s -> %s", strings.Join(path, " -> "), t.Title)
+		case visited:
+			return nil
+		}
+		marks[t] = visiting
+		path = append(path, t.Title)
+		for _, dep := range t.Dependencies {
+			// Resolved dependencies are satisfied and can't be part of a blocking cycle
+			if d := l.Find(dep); d != nil && !d.resolved() {
+				if err := visit(d); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		marks[t] = visited
+		return nil
+	}
+
+	for _, t := range l.Incomplete() {
+		if err := visit(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rank orders priorities for selection; lower is more urgent. TODOs without a
+// priority are treated as medium.
+func (p Priority) rank() int {
+	switch p {
+	case PriorityHigh:
+		return 0
+	case PriorityLow:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// maxIDLength caps the length of TODO IDs, which end up in branch and directory names
+const maxIDLength = 40
+
+// ID returns a slug of the title for use in branch and directory names,
+// e.g. "Add parser (v2)" becomes "add-parser-v2". A slug that is too long is
+// cut short and ends in a hash of the whole title, so that titles sharing a
+// long prefix still get different IDs.
+func (t *Todo) ID() string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(normalizeTodoTitle(t.Title)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	id := b.String()
+	if len(id) > maxIDLength {
+		sum := sha1.Sum([]byte(normalizeTodoTitle(t.Title)))
+		hash := hex.EncodeToString(sum[:3])
+		id = strings.TrimRight(id[:maxIDLength-len(hash)-1], "-") + "-" + hash
+	}
+	if id == "" {
+		id = "todo"
+	}
+	return id
+}
+
+// Block marks the TODO as blocked so the loop stops selecting it, replacing
+// any earlier reason and feedback with the given ones
+func (t *Todo) Block(reason string, feedback []string) {
+	t.Checked = false
+	t.Status = StatusBlocked
+	t.SubBullets = slices.DeleteFunc(t.SubBullets, func(b string) bool {
+		_, isReason := cutPrefixFold(b, "Blocked:")
+		_, isFeedback := cutPrefixFold(b, "Critic feedback:")
+		return isReason || isFeedback
+	})
+	t.SubBullets = append(t.SubBullets, "Blocked: "+reason)
+	for _, line := range feedback {
+		t.SubBullets = append(t.SubBullets, "Critic feedback: "+line)
+	}
+}
+
+// Header returns the text after the checkbox, e.g. "**Task** - Completion: ..."
+func (t *Todo) Header() string {
+	if !t.modified() {
+		m := todoHeaderRe.FindStringSubmatch(t.raw[0])
+		return strings.TrimSpace(m[3])
+	}
+	return t.formatHeader()
+}
+
+// parseTodoHeader splits the text after the checkbox into title and completion criteria
+func parseTodoHeader(text string) (title, completion string) {
+	rest := ""
+	if strings.HasPrefix(text, "**") {
+		if end := strings.Index(text[2:], "**"); end >= 0 {
+			title = text[2 : end+2]
+			rest = text[end+4:]
+		} else {
+			title = strings.TrimPrefix(text, "**")
+		}
+	} else if idx := strings.Index(text, " - "); idx > 0 {
+		title = text[:idx]
+		rest = text[idx:]
+	} else {
+		title = text
+	}
+
+	rest = strings.TrimSpace(rest)
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "-"))
+	if after, ok := cutPrefixFold(rest, "Completion:"); ok {
+		completion = strings.TrimSpace(after)
+	} else {
+		completion = rest
+	}
+	return strings.TrimSpace(title), completion
+}
+
+// parseSubBullet records a sub-bullet line (already trimmed) on the TODO
+func (t *Todo) parseSubBullet(line string) {
+	text := strings.TrimSpace(strings.TrimPrefix(line, "- "))
+
+	if v, ok := cutPrefixFold(text, "Priority:"); ok {
+		t.Priority = Priority(strings.ToLower(strings.TrimSpace(v)))
+		return
+	}
+	if v, ok := cutPrefixFold(text, "Dependencies:"); ok {
+		t.Dependencies = parseDependencies(v)
+		return
+	}
+	if v, ok := cutPrefixFold(text, "Status:"); ok {
+		t.Status = TodoStatus(strings.ToLower(strings.TrimSpace(v)))
+		return
+	}
+	if v, ok := cutPrefixFold(text, "Completion:"); ok && t.Completion == "" {
+		t.Completion = strings.TrimSpace(v)
+		return
+	}
+	t.SubBullets = append(t.SubBullets, text)
+}
+
+// parseDependencies splits a comma-separated dependency list, treating "none" as empty
+func parseDependencies(v string) []string {
+	v = strings.TrimSpace(v)
+	if v == "" || strings.EqualFold(v, "none") {
+		return nil
+	}
+	var deps []string
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(strings.Trim(strings.TrimSpace(part), "*"))
+		if part != "" {
+			deps = append(deps, part)
+		}
+	}
+	return deps
+}
+
+// render returns the lines for this TODO, reusing the original text where possible
+func (t *Todo) render() []string {
+	if !t.modified() {
+		lines := append([]string(nil), t.raw...)
+		if t.Checked != t.parsed.Checked {
+			lines[0] = setCheckbox(lines[0], t.Checked)
+		}
+		return lines
+	}
+
+	lines := []string{fmt.Sprintf("%s- [%s] %s", t.indent, checkboxMark(t.Checked), t.formatHeader())}
+	sub := t.indent + "  - "
+	if t.Priority != PriorityNone {
+		lines = append(lines, sub+"Priority: "+string(t.Priority))
+	}
+	if len(t.Dependencies) > 0 {
+		lines = append(lines, sub+"Dependencies: "+strings.Join(t.Dependencies, ", "))
+	} else if t.parsed != nil && t.parsed.hasDependencyLine() {
+		lines = append(lines, sub+"Dependencies: none")
+	}
+	if t.Status != StatusOpen {
+		lines = append(lines, sub+"Status: "+string(t.Status))
+	}
+	for _, b := range t.SubBullets {
+		lines = append(lines, sub+b)
+	}
+	return lines
+}
+
+// formatHeader renders the header text in the planner's format
+func (t *Todo) formatHeader() string {
+	header := "**" + t.Title + "**"
+	if t.Completion != "" {
+		header += " - Completion: " + t.Completion
+	}
+	return header
+}
+
+// modified reports whether any field other than Checked changed since parsing
+func (t *Todo) modified() bool {
+	p := t.parsed
+	if p == nil || len(t.raw) == 0 {
+		return true
+	}
+	return t.Title != p.Title ||
+		t.Completion != p.Completion ||
+		t.Priority != p.Priority ||
+		t.Status != p.Status ||
+		!slices.Equal(t.Dependencies, p.Dependencies) ||
+		!slices.Equal(t.SubBullets, p.SubBullets)
+}
+
+// hasDependencyLine reports whether the original text had a Dependencies: sub-bullet
+func (t *Todo) hasDependencyLine() bool {
+	for _, line := range t.raw[1:] {
+		text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "- "))
+		if _, ok := cutPrefixFold(text, "Dependencies:"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Todo) clone() *Todo {
+	c := *t
+	c.Dependencies = append([]string(nil), t.Dependencies...)
+	c.SubBullets = append([]string(nil), t.SubBullets...)
+	c.parsed = nil
+	return &c
+}
+
+func checkboxMark(checked bool) string {
+	if checked {
+		return "x"
+	}
+	return " "
+}
+
+// setCheckbox replaces the checkbox mark in a header line
+func setCheckbox(line string, checked bool) string {
+	idx := strings.Index(line, "- [")
+	if idx < 0 || len(line) < idx+5 {
+		return line
+	}
+	return line[:idx+3] + checkboxMark(checked) + line[idx+4:]
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+func normalizeTodoTitle(s string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Trim(strings.TrimSpace(s), "*")))
+}
+
+// cutPrefixFold is strings.CutPrefix with case-insensitive matching
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// Add appends a TODO to the end of the given section, creating the section
+// at the end of the file if it doesn't exist
+func (l *TodoList) Add(t *Todo, section string) {
+	t.Section = section
+
+	// Insert after the section's last TODO, or right after its heading
+	insertAt := -1
+	inSection := false
+	for i, b := range l.blocks {
+		if b.todo == nil {
+			trimmed := strings.TrimSpace(b.line)
+			if strings.HasPrefix(trimmed, "## ") {
+				inSection = strings.TrimSpace(strings.TrimPrefix(trimmed, "## ")) == section
+				if inSection {
+					insertAt = i + 1
+				}
+			}
+			continue
+		}
+		if inSection {
+			insertAt = i + 1
+		}
+	}
+
+	block := todoBlock{todo: t}
+	if insertAt < 0 {
+		// Keep a trailing newline at the end of the file
+		end := len(l.blocks)
+		if end > 0 && l.blocks[end-1].todo == nil && l.blocks[end-1].line == "" {
+			end--
+		}
+		newBlocks := []todoBlock{{line: ""}, {line: "## " + section}, block}
+		if end == 0 {
+			newBlocks = newBlocks[1:]
+		}
+		l.blocks = slices.Insert(l.blocks, end, newBlocks...)
+	} else {
+		l.blocks = slices.Insert(l.blocks, insertAt, block)
+	}
+	l.Todos = append(l.Todos, t)
+}
@@ -0,0 +1,449 @@
+package state
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleTodos = `# TODOs
+
+## Pending
+- [x] **Set up project** - Completion: go build succeeds
+  - Priority: high
+  - Dependencies: none
+- [ ] **Add parser** - Completion: parser tests pass
+  - Priority: medium
+  - Dependencies: Set up project, **Add lexer**
+  - Note: see plan.md
+- [X] Legacy task - with a description
+- [ ] **Fix: typo in README** - Completion: README spelled correctly
+  - Priority: low
+
+## Later
+- [ ] Plain task
+`
+
+func TestParseTodos(t *testing.T) {
+	list := ParseTodos(sampleTodos)
+
+	if len(list.Todos) != 5 {
+		t.Fatalf("expected 5 TODOs, got %d", len(list.Todos))
+	}
+
+	parser := list.Todos[1]
+	if parser.Title != "Add parser" {
+		t.Errorf("expected title 'Add parser', got %q", parser.Title)
+	}
+	if parser.Completion != "parser tests pass" {
+		t.Errorf("expected completion 'parser tests pass', got %q", parser.Completion)
+	}
+	if parser.Priority != PriorityMedium {
+		t.Errorf("expected priority medium, got %q", parser.Priority)
+	}
+	if !reflect.DeepEqual(parser.Dependencies, []string{"Set up project", "Add lexer"}) {
+		t.Errorf("unexpected dependencies %v", parser.Dependencies)
+	}
+	if !reflect.DeepEqual(parser.SubBullets, []string{"Note: see plan.md"}) {
+		t.Errorf("unexpected sub-bullets %v", parser.SubBullets)
+	}
+	if parser.Section != "Pending" || parser.Checked {
+		t.Errorf("expected unchecked in Pending, got checked=%v section=%q", parser.Checked, parser.Section)
+	}
+
+	if len(list.Todos[0].Dependencies) != 0 {
+		t.Errorf("'none' should parse as no dependencies, got %v", list.Todos[0].Dependencies)
+	}
+
+	legacy := list.Todos[2]
+	if !legacy.Checked {
+		t.Error("[X] should count as checked")
+	}
+	if legacy.Title != "Legacy task" || legacy.Completion != "with a description" {
+		t.Errorf("unexpected legacy parse: title=%q completion=%q", legacy.Title, legacy.Completion)
+	}
+
+	plain := list.Todos[4]
+	if plain.Title != "Plain task" || plain.Section != "Later" || plain.Priority != PriorityNone {
+		t.Errorf("unexpected plain parse: %+v", plain)
+	}
+
+	if got := len(list.Incomplete()); got != 3 {
+		t.Errorf("expected 3 incomplete, got %d", got)
+	}
+	if got := len(list.Completed()); got != 2 {
+		t.Errorf("expected 2 completed, got %d", got)
+	}
+}
+
+func TestTodosRoundTrip(t *testing.T) {
+	list := ParseTodos(sampleTodos)
+	if got := list.String(); got != sampleTodos {
+		t.Errorf("round trip changed content:\n%s", got)
+	}
+}
+
+func TestTodosCheckOffPreservesFormatting(t *testing.T) {
+	list := ParseTodos(sampleTodos)
+	list.Find("add parser").Checked = true
+
+	want := ParseTodos(sampleTodos).String()
+	want = strings.Replace(want, "- [ ] **Add parser**", "- [x] **Add parser**", 1)
+	if got := list.String(); got != want {
+		t.Errorf("check-off should only change the checkbox:\n%s", got)
+	}
+}
+
+func TestTodosEditRewritesItem(t *testing.T) {
+	list := ParseTodos(sampleTodos)
+	todo := list.Find("Fix: typo in README")
+	todo.Priority = PriorityHigh
+	todo.SubBullets = append(todo.SubBullets, "Blocked: tests fail")
+
+	reparsed := ParseTodos(list.String())
+	got := reparsed.Find("Fix: typo in README")
+	if got == nil {
+		t.Fatal("edited TODO not found after reparse")
+	}
+	if got.Priority != PriorityHigh {
+		t.Errorf("expected priority high, got %q", got.Priority)
+	}
+	if got.Completion != "README spelled correctly" {
+		t.Errorf("completion lost on rewrite, got %q", got.Completion)
+	}
+	if !reflect.DeepEqual(got.SubBullets, []string{"Blocked: tests fail"}) {
+		t.Errorf("unexpected sub-bullets %v", got.SubBullets)
+	}
+	if len(reparsed.Todos) != len(list.Todos) {
+		t.Errorf("TODO count changed: %d -> %d", len(list.Todos), len(reparsed.Todos))
+	}
+}
+
+func TestLoadTodosSave(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	if _, err := LoadTodos(); err == nil {
+		t.Error("expected error when TODO.md is missing")
+	}
+
+	os.MkdirAll(AutoclaudeDir, 0755)
+	os.WriteFile(TodoPath(), []byte(sampleTodos), 0644)
+
+	list, err := LoadTodos()
+	if err != nil {
+		t.Fatalf("LoadTodos failed: %v", err)
+	}
+	list.Todos[4].Checked = true
+	if err := list.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reloaded, _ := LoadTodos()
+	if !reloaded.Todos[4].Checked {
+		t.Error("check-off should persist after Save")
+	}
+}
+
+func TestHasIncompleteTodos(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	os.MkdirAll(AutoclaudeDir, 0755)
+
+	// No file
+	if HasIncompleteTodos() {
+		t.Error("should return false when no TODO file")
+	}
+
+	// Empty file
+	os.WriteFile(TodoPath(), []byte(""), 0644)
+	if HasIncompleteTodos() {
+		t.Error("should return false for empty file")
+	}
+
+	// All complete
+	os.WriteFile(TodoPath(), []byte("- [x] Done\n- [x] Also done"), 0644)
+	if HasIncompleteTodos() {
+		t.Error("should return false when all complete")
+	}
+
+	// Has incomplete
+	os.WriteFile(TodoPath(), []byte("- [x] Done\n- [ ] Not done"), 0644)
+	if !HasIncompleteTodos() {
+		t.Error("should return true when incomplete exists")
+	}
+}
+
+func TestTodoNextPriorityAndDependencies(t *testing.T) {
+	content := `## Pending
+- [ ] **Fix: lint warning** - Completion: lint clean
+  - Priority: low
+- [ ] **Write docs** - Completion: README updated
+  - Priority: high
+  - Dependencies: Build API
+- [ ] **Build API** - Completion: endpoint works
+  - Priority: medium
+  - Dependencies: Set up project
+- [x] **Set up project** - Completion: builds
+  - Priority: high
+`
+	list := ParseTodos(content)
+
+	next, err := list.Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	// "Write docs" is high priority but blocked on "Build API"
+	if next.Title != "Build API" {
+		t.Errorf("expected 'Build API', got %q", next.Title)
+	}
+
+	list.Find("Build API").Checked = true
+	next, _ = list.Next()
+	if next.Title != "Write docs" {
+		t.Errorf("expected 'Write docs' once unblocked, got %q", next.Title)
+	}
+
+	list.Find("Write docs").Checked = true
+	next, _ = list.Next()
+	if next.Title != "Fix: lint warning" {
+		t.Errorf("expected low priority item last, got %q", next.Title)
+	}
+
+	list.Find("Fix: lint warning").Checked = true
+	next, err = list.Next()
+	if next != nil || err != nil {
+		t.Errorf("expected nil, nil when all done, got %v, %v", next, err)
+	}
+}
+
+func TestTodoNextTieBreaksByFileOrder(t *testing.T) {
+	list := ParseTodos("- [ ] First\n- [ ] Second\n")
+	next, err := list.Next()
+	if err != nil || next.Title != "First" {
+		t.Errorf("expected 'First', got %v (err %v)", next, err)
+	}
+}
+
+func TestTodoNextDependencyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "unknown dependency",
+			content: `- [ ] **A** - Completion: done
+  - Dependencies: Missing task
+`,
+			wantErr: "unknown task",
+		},
+		{
+			name: "cycle",
+			content: `- [ ] **A** - Completion: done
+  - Dependencies: B
+- [ ] **B** - Completion: done
+  - Dependencies: C
+- [ ] **C** - Completion: done
+  - Dependencies: A
+`,
+			wantErr: "cycle",
+		},
+		{
+			name: "self dependency",
+			content: `- [ ] **A** - Completion: done
+  - Dependencies: A
+`,
+			wantErr: "cycle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTodos(tt.content).Next()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTodoListAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "after last TODO in section",
+			content: "# TODOs\n\n## Pending\n- [ ] **A** - Completion: done\n  - Priority: high\n\n## Completed\n- [x] **B** - Completion: done\n",
+			want:    "# TODOs\n\n## Pending\n- [ ] **A** - Completion: done\n  - Priority: high\n- [ ] **New** - Completion: added\n  - Priority: low\n\n## Completed\n- [x] **B** - Completion: done\n",
+		},
+		{
+			name:    "empty section",
+			content: "# TODOs\n\n## Pending\n\n## Completed\n- [x] **B** - Completion: done\n",
+			want:    "# TODOs\n\n## Pending\n- [ ] **New** - Completion: added\n  - Priority: low\n\n## Completed\n- [x] **B** - Completion: done\n",
+		},
+		{
+			name:    "missing section",
+			content: "# TODOs\n\n## Completed\n- [x] **B** - Completion: done\n",
+			want:    "# TODOs\n\n## Completed\n- [x] **B** - Completion: done\n\n## Pending\n- [ ] **New** - Completion: added\n  - Priority: low\n",
+		},
+		{
+			name:    "empty file",
+			content: "",
+			want:    "## Pending\n- [ ] **New** - Completion: added\n  - Priority: low\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := ParseTodos(tt.content)
+			list.Add(&Todo{Title: "New", Completion: "added", Priority: PriorityLow}, "Pending")
+			if got := list.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if list.Find("New") == nil {
+				t.Error("added TODO should be findable")
+			}
+		})
+	}
+}
+
+func TestTodoReady(t *testing.T) {
+	list := ParseTodos(`- [x] **Done** - Completion: done
+- [ ] **Low** - Completion: done
+  - Priority: low
+- [ ] **Blocked** - Completion: done
+  - Priority: high
+  - Dependencies: Low
+- [ ] **Plain** - Completion: done
+- [ ] **Urgent** - Completion: done
+  - Priority: high
+  - Dependencies: Done
+`)
+	ready, err := list.Ready()
+	if err != nil {
+		t.Fatalf("Ready failed: %v", err)
+	}
+	var titles []string
+	for _, todo := range ready {
+		titles = append(titles, todo.Title)
+	}
+	if got := strings.Join(titles, ", "); got != "Urgent, Plain, Low" {
+		t.Errorf("got %q, want %q", got, "Urgent, Plain, Low")
+	}
+}
+
+func TestTodoID(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Add parser (v2)", "add-parser-v2"},
+		{"  Fix: **bold** title!  ", "fix-bold-title"},
+		{"Résumé support", "r-sum-support"},
+		{"!!!", "todo"},
+		{strings.Repeat("word ", 20), "word-word-word-word-word-word-wor-033581"},
+	}
+	for _, tt := range tests {
+		if got := (&Todo{Title: tt.title}).ID(); got != tt.want {
+			t.Errorf("ID(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+
+	// Long titles that only differ after the cut still get different IDs
+	a := (&Todo{Title: "Add integration tests for the user service"}).ID()
+	b := (&Todo{Title: "Add integration tests for the user service API"}).ID()
+	if a == b || len(a) > maxIDLength || len(b) > maxIDLength {
+		t.Errorf("expected distinct IDs of at most %d characters, got %q and %q", maxIDLength, a, b)
+	}
+}
+
+func TestTodoBlock(t *testing.T) {
+	list := ParseTodos(`- [x] **Stuck** - Completion: done
+  - Priority: high
+  - Critic feedback: stale
+- [ ] **After** - Completion: done
+  - Dependencies: Stuck
+- [ ] **Later** - Completion: done
+  - Dependencies: After
+- [ ] **Free** - Completion: done
+`)
+	list.Find("Stuck").Block("not approved", []string{"[high] a.go:3: broken"})
+
+	want := `- [ ] **Stuck** - Completion: done
+  - Priority: high
+  - Status: blocked
+  - Blocked: not approved
+  - Critic feedback: [high] a.go:3: broken`
+	if got := list.String(); !strings.HasPrefix(got, want) {
+		t.Errorf("got:\n%s\nwant prefix:\n%s", got, want)
+	}
+
+	// Blocked TODOs and everything waiting on them are skipped
+	list = ParseTodos(list.String())
+	if stuck := list.Find("Stuck"); stuck.Status != StatusBlocked || stuck.Checked {
+		t.Errorf("status should round-trip, got %+v", stuck)
+	}
+	var open []string
+	for _, todo := range list.Open() {
+		open = append(open, todo.Title)
+	}
+	if got := strings.Join(open, ", "); got != "Free" {
+		t.Errorf("Open() = %q, want %q", got, "Free")
+	}
+	if next, err := list.Next(); err != nil || next.Title != "Free" {
+		t.Errorf("Next() = %v, %v", next, err)
+	}
+
+	list.Find("Free").Checked = true
+	if next, err := list.Next(); err != nil || next != nil {
+		t.Errorf("nothing should be left when only blocked work remains, got %v, %v", next, err)
+	}
+
+	// Blocking again replaces the earlier reason and feedback
+	stuck := list.Find("Stuck")
+	stuck.Block("again", nil)
+	if got := strings.Join(stuck.SubBullets, "; "); got != "Blocked: again" {
+		t.Errorf("SubBullets = %q", got)
+	}
+}
+
+func TestTodoStatuses(t *testing.T) {
+	list := ParseTodos(`- [ ] **Later** - Completion: done
+  - Status: Deferred
+- [ ] **Dropped** - Completion: done
+  - Status: skipped
+- [ ] **Needs later** - Completion: done
+  - Dependencies: Later
+- [ ] **Needs dropped** - Completion: done
+  - Dependencies: Dropped
+`)
+	if got := list.Find("Later").Status; got != StatusDeferred {
+		t.Errorf("status should be parsed case-insensitively, got %q", got)
+	}
+
+	// Deferred TODOs hold up their dependents; skipped ones don't
+	if held := list.WaitingOn(list.Find("Needs later")); held == nil || held.Title != "Later" {
+		t.Errorf("WaitingOn = %v, want Later", held)
+	}
+	ready, err := list.Ready()
+	if err != nil || len(ready) != 1 || ready[0].Title != "Needs dropped" {
+		t.Errorf("Ready() = %v, %v", ready, err)
+	}
+	if got := len(list.WithStatus(StatusSkipped)); got != 1 {
+		t.Errorf("expected 1 skipped TODO, got %d", got)
+	}
+
+	list = ParseTodos("- [ ] **Typo** - Completion: done\n  - Status: blokced\n")
+	if _, err := list.Next(); err == nil || !strings.Contains(err.Error(), "unknown status") {
+		t.Errorf("expected unknown status error, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LastSessionFile records the transcript of the most recent interactive session
+const LastSessionFile = "last_session.json"
+
+// Phase identifies the kind of Claude session
+type Phase string
+
+const (
+	PhasePlanner   Phase = "planner"
+	PhaseCoder     Phase = "coder"
+	PhaseCritic    Phase = "critic"
+	PhaseFixer     Phase = "fixer"
+	PhaseEvaluator Phase = "evaluator"
+	PhasePruner    Phase = "pruner"
+)
+
+// Phases lists all phases in loop order
+var Phases = []Phase{PhasePlanner, PhaseCoder, PhaseCritic, PhaseFixer, PhaseEvaluator, PhasePruner}
+
+// Usage tracks tokens and cost across one or more Claude sessions
+type Usage struct {
+	Sessions            int     `json:"sessions"`
+	InputTokens         int     `json:"inputTokens"`
+	OutputTokens        int     `json:"outputTokens"`
+	CacheCreationTokens int     `json:"cacheCreationTokens"`
+	CacheReadTokens     int     `json:"cacheReadTokens"`
+	CostUSD             float64 `json:"costUsd"`
+	Estimated           bool    `json:"estimated,omitempty"` // Cost includes estimates from transcripts
+}
+
+// Add accumulates other into u
+func (u *Usage) Add(other Usage) {
+	u.Sessions += other.Sessions
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheCreationTokens += other.CacheCreationTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.CostUSD += other.CostUSD
+	u.Estimated = u.Estimated || other.Estimated
+}
+
+// TotalTokens returns all tokens, including cache reads and writes
+func (u Usage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationTokens + u.CacheReadTokens
+}
+
+// FormatCost formats the cost in USD, marking estimates with "~"
+func (u Usage) FormatCost() string {
+	if u.Estimated {
+		return fmt.Sprintf("~$%.2f", u.CostUSD)
+	}
+	return fmt.Sprintf("$%.2f", u.CostUSD)
+}
+
+// RecordUsage adds one session's usage to the totals for its phase and TODO.
+// todo is the TODO title, or empty for sessions outside a TODO (e.g. the evaluator).
+func (st *Stats) RecordUsage(phase Phase, todo string, u Usage) {
+	st.Usage.Add(u)
+
+	if st.PhaseUsage == nil {
+		st.PhaseUsage = make(map[Phase]*Usage)
+	}
+	if st.PhaseUsage[phase] == nil {
+		st.PhaseUsage[phase] = &Usage{}
+	}
+	st.PhaseUsage[phase].Add(u)
+
+	if todo == "" {
+		return
+	}
+	if st.TodoUsage == nil {
+		st.TodoUsage = make(map[string]*Usage)
+	}
+	if st.TodoUsage[todo] == nil {
+		st.TodoUsage[todo] = &Usage{}
+	}
+	st.TodoUsage[todo].Add(u)
+}
+
+// Merge adds the counters and usage of other, e.g. from a parallel worker.
+// Elapsed time isn't added since workers run concurrently with the loop.
+func (st *Stats) Merge(other *Stats) {
+	if other == nil {
+		return
+	}
+	st.ClaudeRuns += other.ClaudeRuns
+	st.TodosCompleted += other.TodosCompleted
+	st.TodosAttempted += other.TodosAttempted
+	st.CriticApprovals += other.CriticApprovals
+	st.CriticRejections += other.CriticRejections
+	st.CriticMinor += other.CriticMinor
+	st.FixAttempts += other.FixAttempts
+	st.FixSuccesses += other.FixSuccesses
+	st.TestRuns += other.TestRuns
+	st.TestFailures += other.TestFailures
+	st.LintRuns += other.LintRuns
+	st.LintFailures += other.LintFailures
+	st.CommitsRefused += other.CommitsRefused
+	st.SessionRetries += other.SessionRetries
+
+	st.Usage.Add(other.Usage)
+	for phase, u := range other.PhaseUsage {
+		if st.PhaseUsage == nil {
+			st.PhaseUsage = make(map[Phase]*Usage)
+		}
+		if st.PhaseUsage[phase] == nil {
+			st.PhaseUsage[phase] = &Usage{}
+		}
+		st.PhaseUsage[phase].Add(*u)
+	}
+	for todo, u := range other.TodoUsage {
+		if st.TodoUsage == nil {
+			st.TodoUsage = make(map[string]*Usage)
+		}
+		if st.TodoUsage[todo] == nil {
+			st.TodoUsage[todo] = &Usage{}
+		}
+		st.TodoUsage[todo].Add(*u)
+	}
+}
+
+// SessionInfo identifies an interactive Claude session, as reported to the stop hook
+type SessionInfo struct {
+	SessionID      string `json:"sessionId"`
+	TranscriptPath string `json:"transcriptPath"`
+}
+
+// LastSessionPath returns the path to the last_session.json file
+func LastSessionPath() string {
+	return filepath.Join(AutoclaudeDir, LastSessionFile)
+}
+
+// SaveLastSession records the session the stop hook was called for
+func SaveLastSession(info SessionInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session info: %w", err)
+	}
+	if err := os.WriteFile(LastSessionPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write session info: %w", err)
+	}
+	return nil
+}
+
+// LoadLastSession reads the session recorded by the stop hook
+func LoadLastSession() (*SessionInfo, error) {
+	data, err := os.ReadFile(LastSessionPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session info: %w", err)
+	}
+	var info SessionInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse session info: %w", err)
+	}
+	return &info, nil
+}
+
+// ClearLastSession removes the last_session.json file
+func ClearLastSession() {
+	os.Remove(LastSessionPath())
+}
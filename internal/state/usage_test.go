@@ -0,0 +1,88 @@
+package state
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRecordUsage(t *testing.T) {
+	st := &Stats{}
+	st.RecordUsage(PhaseCoder, "First", Usage{Sessions: 1, InputTokens: 100, OutputTokens: 10, CostUSD: 0.5})
+	st.RecordUsage(PhaseCritic, "First", Usage{Sessions: 1, CacheReadTokens: 1000, CostUSD: 0.25, Estimated: true})
+	st.RecordUsage(PhaseEvaluator, "", Usage{Sessions: 1, InputTokens: 50, CostUSD: 1})
+
+	if st.Usage.Sessions != 3 || st.Usage.CostUSD != 1.75 || st.Usage.TotalTokens() != 1160 {
+		t.Errorf("unexpected totals %+v", st.Usage)
+	}
+	if !st.Usage.Estimated {
+		t.Error("totals should be marked estimated when any session was")
+	}
+	if st.PhaseUsage[PhaseCoder].InputTokens != 100 || st.PhaseUsage[PhaseCoder].Estimated {
+		t.Errorf("unexpected coder usage %+v", st.PhaseUsage[PhaseCoder])
+	}
+	if got := st.TodoUsage["First"]; got == nil || got.Sessions != 2 || got.CostUSD != 0.75 {
+		t.Errorf("unexpected TODO usage %+v", got)
+	}
+	if len(st.TodoUsage) != 1 {
+		t.Errorf("sessions outside a TODO should not be tracked per TODO, got %v", st.TodoUsage)
+	}
+	if got := st.Usage.FormatCost(); got != "~$1.75" {
+		t.Errorf("FormatCost = %q, want ~$1.75", got)
+	}
+}
+
+func TestStatsMerge(t *testing.T) {
+	st := &Stats{ClaudeRuns: 2, TodosCompleted: 1, ElapsedMs: 1000}
+	st.RecordUsage(PhaseCoder, "First", Usage{Sessions: 1, CostUSD: 0.5})
+
+	worker := &Stats{ClaudeRuns: 3, TodosCompleted: 1, CriticApprovals: 1, ElapsedMs: 5000}
+	worker.RecordUsage(PhaseCoder, "Second", Usage{Sessions: 1, CostUSD: 0.25})
+	worker.RecordUsage(PhaseCritic, "Second", Usage{Sessions: 1, CostUSD: 0.25})
+	st.Merge(worker)
+	st.Merge(nil)
+
+	if st.ClaudeRuns != 5 || st.TodosCompleted != 2 || st.CriticApprovals != 1 {
+		t.Errorf("unexpected counters %+v", st)
+	}
+	if st.ElapsedMs != 1000 {
+		t.Errorf("elapsed time should not be merged, got %d", st.ElapsedMs)
+	}
+	if st.Usage.Sessions != 3 || st.Usage.CostUSD != 1 {
+		t.Errorf("unexpected totals %+v", st.Usage)
+	}
+	if st.PhaseUsage[PhaseCoder].Sessions != 2 || st.PhaseUsage[PhaseCritic].Sessions != 1 {
+		t.Errorf("unexpected phase usage %+v", st.PhaseUsage)
+	}
+	if got := st.TodoUsage["Second"]; got == nil || got.Sessions != 2 {
+		t.Errorf("unexpected TODO usage %+v", got)
+	}
+}
+
+func TestLastSession(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	os.MkdirAll(AutoclaudeDir, 0755)
+
+	if _, err := LoadLastSession(); err == nil {
+		t.Error("expected error when no session was recorded")
+	}
+
+	if err := SaveLastSession(SessionInfo{SessionID: "abc", TranscriptPath: "/tmp/t.jsonl"}); err != nil {
+		t.Fatalf("SaveLastSession failed: %v", err)
+	}
+	info, err := LoadLastSession()
+	if err != nil {
+		t.Fatalf("LoadLastSession failed: %v", err)
+	}
+	if info.SessionID != "abc" || info.TranscriptPath != "/tmp/t.jsonl" {
+		t.Errorf("unexpected session info %+v", info)
+	}
+
+	ClearLastSession()
+	if _, err := LoadLastSession(); err == nil {
+		t.Error("expected error after ClearLastSession")
+	}
+}
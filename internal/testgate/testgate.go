@@ -0,0 +1,134 @@
+package testgate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/state"
+)
+
+const (
+	OutputFile = "test_output.txt"
+	ResultFile = "test_result.json"
+
+	// tailLines is how much of the output is handed to the fixer
+	tailLines = 200
+
+	// waitDelay is how long output is still read after the command was
+	// killed, in case something outside its process group holds the pipe
+	waitDelay = 5 * time.Second
+)
+
+// Result is the outcome of one orchestrator-run test command
+type Result struct {
+	Command    string    `json:"command"`
+	ExitCode   int       `json:"exitCode"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+	TimedOut   bool      `json:"timedOut,omitempty"` // Killed for running longer than its timeout
+	Output     string    `json:"-"`                  // Combined stdout/stderr, saved to OutputFile
+}
+
+// OutputPath returns the path to the saved test output
+func OutputPath() string {
+	return filepath.Join(state.AutoclaudeDir, OutputFile)
+}
+
+// ResultPath returns the path to the saved test result
+func ResultPath() string {
+	return filepath.Join(state.AutoclaudeDir, ResultFile)
+}
+
+// Run runs the test command through the shell and captures its output.
+// A failing test command is not an error; check Passed on the result. One
+// that runs longer than timeout (if not 0) is killed and fails. The command
+// runs in its own process group, which is killed as a whole, so that servers
+// or test binaries it started don't outlive it. An error is returned if the
+// command could not be run at all or ctx was cancelled.
+func Run(ctx context.Context, command string, timeout time.Duration) (*Result, error) {
+	r := &Result{Command: command, StartedAt: time.Now()}
+
+	runCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, "sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = waitDelay
+	output, err := cmd.CombinedOutput()
+	r.DurationMs = time.Since(r.StartedAt).Milliseconds()
+	r.Output = string(output)
+
+	// The group may outlive the shell if the command backgrounded something
+	if cmd.Process != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("test command stopped: %w", ctx.Err())
+	}
+	if runCtx.Err() != nil {
+		r.TimedOut = true
+		r.ExitCode = -1
+		r.Output += fmt.Sprintf("\n(killed by autoclaude after running longer than %s)\n", timeout)
+		return r, nil
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("failed to run test command: %w", err)
+		}
+		r.ExitCode = exitErr.ExitCode()
+	}
+
+	return r, nil
+}
+
+// Passed reports whether the test command exited successfully
+func (r *Result) Passed() bool {
+	return r.ExitCode == 0
+}
+
+// Tail returns the last lines of the output, for use in prompts
+func (r *Result) Tail() string {
+	lines := strings.Split(strings.TrimRight(r.Output, "\n"), "\n")
+	if len(lines) <= tailLines {
+		return strings.Join(lines, "\n")
+	}
+	omitted := len(lines) - tailLines
+	return fmt.Sprintf("... (%d lines omitted, see %s)\n%s", omitted, OutputPath(), strings.Join(lines[omitted:], "\n"))
+}
+
+// Save writes the output and result to the .autoclaude directory
+func (r *Result) Save() error {
+	if err := os.MkdirAll(state.AutoclaudeDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	if err := os.WriteFile(OutputPath(), []byte(r.Output), 0644); err != nil {
+		return fmt.Errorf("failed to write test output: %w", err)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal test result: %w", err)
+	}
+	if err := os.WriteFile(ResultPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write test result: %w", err)
+	}
+
+	return nil
+}
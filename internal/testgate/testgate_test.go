@@ -0,0 +1,123 @@
+package testgate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPassing(t *testing.T) {
+	r, err := Run(context.Background(), "echo hello", 0)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !r.Passed() {
+		t.Errorf("expected pass, got exit code %d", r.ExitCode)
+	}
+	if strings.TrimSpace(r.Output) != "hello" {
+		t.Errorf("expected output 'hello', got %q", r.Output)
+	}
+}
+
+func TestRunFailing(t *testing.T) {
+	r, err := Run(context.Background(), "echo out; echo err >&2; exit 3", 0)
+	if err != nil {
+		t.Fatalf("a failing command should not be an error: %v", err)
+	}
+	if r.Passed() {
+		t.Error("expected failure")
+	}
+	if r.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", r.ExitCode)
+	}
+	if !strings.Contains(r.Output, "out") || !strings.Contains(r.Output, "err") {
+		t.Errorf("output should contain stdout and stderr, got %q", r.Output)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	// The backgrounded child would touch the file if it outlived the timeout
+	marker := filepath.Join(t.TempDir(), "survived")
+	r, err := Run(context.Background(), "(sleep 1; touch "+marker+") & echo started; sleep 30", 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("a timed out command should not be an error: %v", err)
+	}
+	if !r.TimedOut || r.Passed() {
+		t.Errorf("expected a timed out failure, got %+v", r)
+	}
+	if !strings.Contains(r.Output, "started") || !strings.Contains(r.Output, "longer than 200ms") {
+		t.Errorf("output should keep what ran and note the timeout, got %q", r.Output)
+	}
+	if r.DurationMs > 10000 {
+		t.Errorf("command ran %dms despite the timeout", r.DurationMs)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the command's children should have been killed with it")
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	if _, err := Run(ctx, "sleep 30", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the run to stop with the context, got %v", err)
+	}
+}
+
+func TestTail(t *testing.T) {
+	var b strings.Builder
+	for i := 1; i <= tailLines+50; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	r := &Result{Output: b.String()}
+
+	tail := r.Tail()
+	if !strings.Contains(tail, "50 lines omitted") {
+		t.Error("tail should note omitted lines")
+	}
+	if strings.Contains(tail, "line 50\n") {
+		t.Error("tail should not contain early lines")
+	}
+	if !strings.HasSuffix(tail, fmt.Sprintf("line %d", tailLines+50)) {
+		t.Error("tail should end with the last line")
+	}
+
+	short := &Result{Output: "a\nb\n"}
+	if short.Tail() != "a\nb" {
+		t.Errorf("short output should be returned whole, got %q", short.Tail())
+	}
+}
+
+func TestSave(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+
+	r, _ := Run(context.Background(), "echo boom; exit 1", 0)
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	output, _ := os.ReadFile(OutputPath())
+	if strings.TrimSpace(string(output)) != "boom" {
+		t.Errorf("unexpected saved output %q", output)
+	}
+
+	data, _ := os.ReadFile(ResultPath())
+	var saved Result
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("failed to parse saved result: %v", err)
+	}
+	if saved.ExitCode != 1 || saved.Command != "echo boom; exit 1" {
+		t.Errorf("unexpected saved result %+v", saved)
+	}
+}
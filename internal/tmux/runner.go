@@ -0,0 +1,157 @@
+package tmux
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+)
+
+// pollInterval is how often a detached session is checked for having ended
+const pollInterval = 500 * time.Millisecond
+
+// Runner runs each Claude session in a detached tmux session, so the loop
+// can run without a terminal while you attach to watch or help. The tmux
+// session ends when the CLI exits.
+type Runner struct {
+	Command string // Agent CLI to run instead of claude
+}
+
+// Start starts the CLI in a new detached tmux session
+func (r Runner) Start(ctx context.Context, prompt string, opts claude.SessionOptions) (claude.Session, error) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return nil, fmt.Errorf("tmux not found: %w", err)
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	// The prompt is read from a file to avoid quoting it for the shell. The
+	// CLI is started by an inner shell that execs it after writing its PID,
+	// so it can be recorded for the stop hooks, and the outer one records how
+	// it exited.
+	dir, err := os.MkdirTemp("", "autoclaude-tmux-")
+	if err != nil {
+		return nil, err
+	}
+	promptFile := filepath.Join(dir, "prompt.md")
+	statusFile := filepath.Join(dir, "status")
+	if err := os.WriteFile(promptFile, []byte(prompt), 0644); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to write prompt: %w", err)
+	}
+	command := r.Command
+	if command == "" {
+		command = claude.Binary()
+	}
+	words := []string{shellQuote(filepath.Join(dir, "pid")), shellQuote(command)}
+	for _, arg := range opts.Args() {
+		words = append(words, shellQuote(arg))
+	}
+	script := fmt.Sprintf("#!/bin/sh\nsh -c 'echo $$ > \"$0\"; exec \"$@\"' %s -- \"$(cat %s)\"\necho $? > %s\n",
+		strings.Join(words, " "), shellQuote(promptFile), shellQuote(statusFile))
+	scriptPath := filepath.Join(dir, "run_claude.sh")
+	if err := os.WriteFile(scriptPath, []byte(script), 0755); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to write runner script: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%d", SessionName, os.Getpid())
+	exec.Command("tmux", "kill-session", "-t", name).Run()
+	if out, err := exec.Command("tmux", "new-session", "-d", "-s", name, "-c", workDir, "sh "+shellQuote(scriptPath)).CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to start tmux session: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	s := &session{name: name, dir: dir, ended: make(chan struct{}), started: time.Now()}
+	if err := s.recordPid(); err != nil {
+		s.Cancel()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	fmt.Printf("  Claude is running in tmux session %s (attach with: tmux attach -t %s)\n", name, name)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Cancel()
+		case <-s.ended:
+		}
+	}()
+	return s, nil
+}
+
+// session is a Claude session running in tmux
+type session struct {
+	name    string
+	dir     string        // Holds the prompt, the script, the CLI's PID and its exit status
+	ended   chan struct{} // Closed once Wait returns
+	started time.Time
+}
+
+// LastActivity returns when a file in the working tree or the transcript last changed
+func (s *session) LastActivity() time.Time {
+	return claude.WorkActivity(s.started)
+}
+
+// recordPid waits for the inner shell to report the CLI's PID and records
+// it in claude.PidFile
+func (s *session) recordPid() error {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, err := os.ReadFile(filepath.Join(s.dir, "pid")); err == nil && len(data) > 0 && data[len(data)-1] == '\n' {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				return fmt.Errorf("invalid PID from tmux session: %q", data)
+			}
+			err = claude.WritePidFile(pid)
+			if _, statErr := os.Stat(filepath.Join(s.dir, "status")); err != nil && statErr == nil {
+				return nil // It has already exited
+			}
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("claude did not start in tmux session %s", s.name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Wait polls until the tmux session has ended and returns how the CLI exited
+func (s *session) Wait() (*claude.SessionResult, error) {
+	defer os.RemoveAll(s.dir)
+	defer os.Remove(claude.PidFile)
+	defer close(s.ended)
+	for exec.Command("tmux", "has-session", "-t", s.name).Run() == nil {
+		time.Sleep(pollInterval)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, "status"))
+	if err != nil {
+		return &claude.SessionResult{}, fmt.Errorf("tmux session %s ended before claude exited", s.name)
+	}
+	if code, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && code != 0 {
+		return &claude.SessionResult{}, fmt.Errorf("claude exited with status %d in tmux", code)
+	}
+	return &claude.SessionResult{}, nil
+}
+
+// Cancel stops the CLI, forcibly if it doesn't exit in time, then kills the
+// tmux session
+func (s *session) Cancel() error {
+	if rec, err := claude.ReadPidFile(); err == nil && rec.Running() {
+		rec.Stop()
+	}
+	return exec.Command("tmux", "kill-session", "-t", s.name).Run()
+}
+
+// shellQuote quotes a word for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,51 @@
+package tmux
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.coldcutz.net/autoclaude/internal/claude"
+)
+
+func TestRunner(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	tmpDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldDir)
+	os.MkdirAll(".autoclaude", 0755)
+	// Keep the test's tmux server apart from any the user is running
+	t.Setenv("TMUX_TMPDIR", tmpDir)
+	t.Setenv("TMUX", "")
+	defer exec.Command("tmux", "kill-server").Run()
+
+	// A stand-in CLI that records its arguments and PID, then fails
+	fake := filepath.Join(tmpDir, "agent")
+	os.WriteFile(fake, []byte("#!/bin/sh\necho \"$$ $*\" > args.txt\nexit 3\n"), 0755)
+
+	r := Runner{Command: fake}
+	session, err := r.Start(t.Context(), "it's a prompt", claude.SessionOptions{Model: "opus"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := session.Wait(); err == nil || !strings.Contains(err.Error(), "status 3") {
+		t.Errorf("expected the exit status to be reported, got %v", err)
+	}
+
+	data, _ := os.ReadFile("args.txt")
+	pid, args, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	if args != "--model opus -- it's a prompt" {
+		t.Errorf("unexpected args %q", args)
+	}
+	if pid == "" {
+		t.Error("expected the CLI's PID")
+	}
+	if _, err := os.Stat(claude.PidFile); !os.IsNotExist(err) {
+		t.Error("expected the PID file to be removed once the session ended")
+	}
+}